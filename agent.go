@@ -73,6 +73,98 @@ type (
 		EdgeInactivityTimeout string
 		EdgeInsecurePoll      bool
 		EdgeTunnel            bool
+		EdgeClientRefresh     time.Duration
+		EdgePollFrequency     string
+		EdgeHeartbeatInterval string
+		EdgeStatsdAddr        string
+		EdgeFastRetryCodes    string
+		EdgeTunnelReadiness   bool
+		EdgeSlowPollThreshold time.Duration
+		EdgeLogsMinDiskFree   uint64
+		EdgeLogsMinMemoryFree uint64
+		EdgeDiagnosticsAddr   string
+		EdgeProfiling         bool
+		EdgeTunnelPathPrefix  string
+		EdgeTunnelHeaders     string
+		EdgeConfigProfile     string
+		// EdgeExplicitOptions holds the environment variable names of the options explicitly set by the user,
+		// they take precedence over the configuration profile
+		EdgeExplicitOptions   map[string]bool
+		EdgePollEncoding      string
+		EdgeClientInitRetries int
+		EdgePollReplicas      string
+		EdgeReplicaSelection  string
+		EdgeTunnelStateSource string
+		EdgeTunnelIdleProbe   bool
+		EdgeTunnelPortRange   string
+		EdgeStatusReport      bool
+		EdgeReportResync      time.Duration
+		EdgeErrorLogLevels    string
+		EdgeUnexpectedCreds   string
+		EdgeTunnelMaxStagger  time.Duration
+		EdgeIDFile            string
+		EdgeMaxConnLifetime   time.Duration
+		EdgeScheduleCheck     string
+		EdgeContainerCount    bool
+		EdgeRedirectPolicy    string
+		EdgeMaxRedirects      int
+		EdgeFingerprintMode   string
+		EdgeFingerprintList   string
+		EdgeReconcileDeadline time.Duration
+		EdgeLivenessFailures  int
+		EdgePollHistoryFile   string
+		EdgePollHistorySize   int
+		EdgePollHistoryFiles  int
+		EdgeInsecureFallback  bool
+		EdgeTriggerBackoff    string
+		EdgeDiagnosticsSocket string
+		EdgeDiagSocketMode    string
+		EdgeSteadyStateAfter  time.Duration
+		EdgeSteadyStateEvery  time.Duration
+		EdgeDecodeRetry       bool
+		EdgeStackStrategy     string
+		EdgeReportActions     bool
+		EdgePollOverlap       string
+		EdgeNetworkCheck      bool
+		EdgeTunnelServerCheck time.Duration
+		EdgeDeregistration    string
+		EdgeDeregisterMatch   string
+		EdgeSignReports       bool
+		EdgeOpenTunnelFactor  float64
+		EdgeTunnelSessions    int
+		EdgePollWarmup        time.Duration
+		EdgeLogsCondition     string
+		EdgeLogsSlowAfter     time.Duration
+		EdgePollTransport     string
+		EdgeResponseMaxAge    time.Duration
+		EdgeClockSkew         time.Duration
+		EdgeDiagnosticsGRPC   string
+		EdgeTunnelOutage      time.Duration
+		EdgeResetRestarts     bool
+		EdgeSystemTimeCheck   string
+		LogThrottleWindow     time.Duration
+		EdgeTLSSessionCache   int
+		EdgeTLSRenegotiation  string
+		OperationMode         string
+		EdgeStackConcurrency  int
+		EdgeKubernetesEvents  bool
+		EdgeStackBatchSize    int
+		EdgePollJitter        float64
+		EdgePollBackoffMax    time.Duration
+		EdgeAsyncInterval     time.Duration
+		EdgePollCompression   bool
+		EdgePollCAFiles       string
+		EdgeClientCert        string
+		EdgeClientKey         string
+		EdgeClientCertPass    string
+		EdgeClientCertReload  bool
+		EdgeProxyURL          string
+		EdgeSOCKS5Addr        string
+		EdgeSOCKS5Username    string
+		EdgeSOCKS5Password    string
+		EdgeFailbackInterval  time.Duration
+		EdgeReportQueueSize   int
+		EdgeResponseKeyFile   string
 		LogLevel              string
 	}
 
@@ -104,6 +196,9 @@ type (
 		Script         string
 		Version        int
 		CollectLogs    bool
+		// LogsCollectionWindow optionally restricts the collection of the logs to a daily time window
+		// expressed in local time using the HH:MM-HH:MM format (e.g. 22:00-06:00).
+		LogsCollectionWindow string
 	}
 
 	// TunnelConfig contains all the required information for the agent to establish
@@ -111,9 +206,25 @@ type (
 	TunnelConfig struct {
 		ServerAddr       string
 		ServerFingerpint string
+		ServerPathPrefix string
+		ServerHeaders    map[string]string
 		RemotePort       string
 		LocalAddr        string
 		Credentials      string
+		// TrackActivity enables the collection of the tunnel traffic statistics
+		TrackActivity bool
+		// ClientCert and ClientKey are the paths to the PEM client certificate presented to the tunnel server
+		ClientCert string
+		ClientKey  string
+		// Proxy is the URL of the proxy used to reach the tunnel server
+		Proxy string
+	}
+
+	// TunnelStats contains the traffic statistics of a reverse tunnel
+	TunnelStats struct {
+		BytesIn      uint64
+		BytesOut     uint64
+		LastActivity time.Time
 	}
 
 	// ClusterService is used to manage a cluster of agents.
@@ -139,6 +250,51 @@ type (
 		GetRuntimeConfigurationFromDockerEngine() (*RuntimeConfiguration, error)
 		GetContainerIpFromDockerEngine(containerName string, ignoreNonSwarmNetworks bool) (string, error)
 		GetServiceNameFromDockerEngine(containerName string) (string, error)
+		ContainerCountProvider
+		SnapshotProducer
+	}
+
+	// ContainerCountProvider is used to retrieve the number of containers managed by the container platform
+	ContainerCountProvider interface {
+		ContainerCount(ctx context.Context) (int, error)
+	}
+
+	// SnapshotProducer is used to capture a snapshot of the resources managed by the container platform
+	SnapshotProducer interface {
+		Snapshot(ctx context.Context) (*PlatformSnapshot, error)
+	}
+
+	// PlatformSnapshot is a summary of the resources managed by the container platform
+	PlatformSnapshot struct {
+		Containers []SnapshotContainer
+		Images     []SnapshotImage
+		Volumes    []SnapshotVolume
+	}
+
+	// SnapshotContainer is a container in a platform snapshot
+	SnapshotContainer struct {
+		ID    string
+		Name  string
+		Image string
+		State string
+	}
+
+	// SnapshotImage is an image in a platform snapshot
+	SnapshotImage struct {
+		ID   string
+		Tags []string
+		Size int64
+	}
+
+	// SnapshotVolume is a volume in a platform snapshot
+	SnapshotVolume struct {
+		Name   string
+		Driver string
+	}
+
+	// EventRecorder is used to record the events of the agent on the container platform
+	EventRecorder interface {
+		RecordEvent(reason, eventType, message string, timestamp time.Time) error
 	}
 
 	Deployer interface {
@@ -162,11 +318,13 @@ type (
 		CreateTunnel(config TunnelConfig) error
 		CloseTunnel() error
 		IsTunnelOpen() bool
+		Stats() TunnelStats
 	}
 
 	// Scheduler is used to manage schedules
 	Scheduler interface {
-		Schedule(schedules []Schedule) error
+		Schedule(ctx context.Context, schedules []Schedule) error
+		Schedules() []Schedule
 	}
 
 	// SystemService is used to get info about the host
@@ -176,6 +334,15 @@ type (
 	}
 )
 
+// The build information is set at build time with -ldflags "-X github.com/portainer/agent.GitCommit=...", it is
+// empty when the agent is built without it.
+var (
+	// GitCommit is the git commit from which the agent was built.
+	GitCommit string
+	// BuildDate is the date at which the agent was built.
+	BuildDate string
+)
+
 const (
 	// Version represents the version of the agent.
 	Version = "2.12.0"
@@ -197,8 +364,55 @@ const (
 	DefaultEdgeServerPort = "80"
 	// DefaultEdgePollInterval is the default interval used to poll Edge information from a Portainer instance.
 	DefaultEdgePollInterval = "5s"
+	// DefaultEdgeHeartbeatInterval is the default interval used to send heartbeats to a Portainer instance. Heartbeats are disabled by default.
+	DefaultEdgeHeartbeatInterval = "0s"
 	// DefaultEdgeSleepInterval is the default interval after which the agent will close the tunnel if no activity.
 	DefaultEdgeSleepInterval = "5m"
+	// DefaultEdgePollEncoding is the default encoding requested for the responses of the Edge poll requests.
+	DefaultEdgePollEncoding = "json"
+	// DefaultEdgeClientInitRetries is the default number of attempts made to create the HTTP client used to poll a Portainer instance.
+	DefaultEdgeClientInitRetries = 5
+	// DefaultEdgeFullReportInterval is the default interval at which a full status report is sent to a Portainer instance.
+	DefaultEdgeFullReportInterval = 10 * time.Minute
+	// DefaultEdgeFastRetryCodes is the default list of status codes that trigger a fast retry of a failed poll request,
+	// every server error status code.
+	DefaultEdgeFastRetryCodes = "5xx"
+	// DefaultEdgeRedirectPolicy is the default policy applied to the redirects of the poll requests.
+	DefaultEdgeRedirectPolicy = "error"
+	// DefaultEdgeMaxRedirects is the default maximum number of redirects followed by the limited redirect policy.
+	DefaultEdgeMaxRedirects = 3
+	// DefaultEdgeReplicaSelection is the default strategy used to select the Portainer instance replica of each poll request.
+	DefaultEdgeReplicaSelection = "round-robin"
+	// DefaultEdgeTunnelStateSource is the default source trusted when the tracked tunnel state differs from the tunnel client.
+	DefaultEdgeTunnelStateSource = "agent"
+	// DefaultEdgePollHistorySizeMB is the default maximum size of a poll history file, in megabytes.
+	DefaultEdgePollHistorySizeMB = 10
+	// DefaultEdgePollHistoryFiles is the default number of rotated poll history files kept.
+	DefaultEdgePollHistoryFiles = 3
+	// DefaultEdgeSteadyStateInterval is the default poll interval used once the Edge endpoint is in a steady state.
+	DefaultEdgeSteadyStateInterval = time.Hour
+	// DefaultEdgeTunnelSessionHistory is the default number of tunnel sessions kept in the status.
+	DefaultEdgeTunnelSessionHistory = 10
+	// DefaultEdgeLogsSlowThreshold is the default duration after which a log collection is reported as slow.
+	DefaultEdgeLogsSlowThreshold = 5 * time.Minute
+	// DefaultEdgeResponseClockSkew is the default clock skew tolerated when checking the freshness of a poll response.
+	DefaultEdgeResponseClockSkew = 30 * time.Second
+	// DefaultEdgeSignedResponseMaxAge is the maximum age of a poll response when a response signing key is set and
+	// no maximum age is specified, a signed response cannot be replayed once it is older.
+	DefaultEdgeSignedResponseMaxAge = 5 * time.Minute
+	// DefaultEdgeTLSSessionCacheSize is the default number of TLS sessions cached to resume the poll connections.
+	DefaultEdgeTLSSessionCacheSize = 64
+	// DefaultEdgeFingerprintMode is the default behavior when the Portainer instance sends a new tunnel server fingerprint.
+	DefaultEdgeFingerprintMode = "disabled"
+	// DefaultOperationMode is the default overall posture of the Edge agent.
+	DefaultOperationMode = "full"
+	// DefaultEdgePollCompression is the default compression setting of the request bodies sent to the Portainer instance.
+	DefaultEdgePollCompression = false
+	// DefaultEdgeFailbackInterval is the default interval at which a failed preferred Portainer instance is probed.
+	DefaultEdgeFailbackInterval = 5 * time.Minute
+	// DefaultEdgeReportQueueSizeMB is the default maximum size of the reports queued while the Portainer instance is
+	// unreachable, the queue is disabled by default.
+	DefaultEdgeReportQueueSizeMB = 0
 	// DefaultConfigCheckInterval is the default interval used to check if node config changed
 	DefaultConfigCheckInterval = "5s"
 	// SupportedDockerAPIVersion is the minimum Docker API version supported by the agent.
@@ -212,6 +426,62 @@ const (
 	// HTTPEdgeIdentifierHeaderName is the name of the header used to specify the Docker identifier associated to
 	// an Edge agent.
 	HTTPEdgeIdentifierHeaderName = "X-PortainerAgent-EdgeID"
+	// HTTPEdgeInstanceHeaderName is the name of the header used to specify the identifier of the agent process
+	// polling a Portainer instance. The identifier changes each time the agent is restarted.
+	HTTPEdgeInstanceHeaderName = "X-PortainerAgent-Instance"
+	// HTTPEdgeStartTimeHeaderName is the name of the header used to specify the start time of the agent process
+	// polling a Portainer instance.
+	HTTPEdgeStartTimeHeaderName = "X-PortainerAgent-StartTime"
+	// HTTPEdgeSchemaVersionHeaderName is the name of the header used by a Portainer instance to specify the version
+	// of the schema used in the poll responses.
+	HTTPEdgeSchemaVersionHeaderName = "X-Portainer-Edge-Schema-Version"
+	// HTTPEdgeCapabilitiesHeaderName is the name of the header used by the agent and the Portainer instance to
+	// advertise the optional protocol features they support.
+	HTTPEdgeCapabilitiesHeaderName = "X-PortainerAgent-Capabilities"
+	// HTTPEdgeReportHeaderName is the name of the header used to send the status report of the agent (base64 encoded JSON).
+	HTTPEdgeReportHeaderName = "X-PortainerAgent-Report"
+	// HTTPEdgeReportTypeHeaderName is the name of the header used to specify whether the status report is a full or a delta report.
+	HTTPEdgeReportTypeHeaderName = "X-PortainerAgent-Report-Type"
+	// HTTPEdgeContainerCountHeaderName is the name of the header used to specify the number of containers managed
+	// by the container platform.
+	HTTPEdgeContainerCountHeaderName = "X-PortainerAgent-ContainerCount"
+	// HTTPEdgeIdempotencyKeyHeaderName is the name of the header used to identify a poll cycle, the key is reused
+	// when a poll request is retried so that the Portainer instance can deduplicate the reported data.
+	HTTPEdgeIdempotencyKeyHeaderName = "Idempotency-Key"
+	// HTTPEdgeAgentInfoHeaderName is the name of the header used to send the static information of the agent
+	// (base64 encoded JSON), only sent when the information changed since it was last received by the Portainer instance.
+	HTTPEdgeAgentInfoHeaderName = "X-PortainerAgent-Info"
+	// HTTPEdgeAgentInfoTypeHeaderName is the name of the header used to specify whether the static information
+	// of the agent is sent in full or omitted because the Portainer instance already knows it.
+	HTTPEdgeAgentInfoTypeHeaderName = "X-PortainerAgent-Info-Type"
+	// HTTPEdgeActionsHeaderName is the name of the header used to report the outcome of the actions performed
+	// by the agent in response to the previous polls (base64 encoded JSON).
+	HTTPEdgeActionsHeaderName = "X-PortainerAgent-Previous-Actions"
+	// HTTPEdgePollIntervalHeaderName is the name of the header used to specify the interval, in seconds, at which
+	// the agent actually polls the Portainer instance once the client side modifiers are applied.
+	HTTPEdgePollIntervalHeaderName = "X-PortainerAgent-Poll-Interval"
+	// HTTPEdgeSignatureHeaderName is the name of the header used to send the signature of the data reported by the agent.
+	HTTPEdgeSignatureHeaderName = "X-PortainerAgent-Signature"
+	// HTTPEdgeSignatureKeyHeaderName is the name of the header used to send the public key verifying the signature
+	// of the data reported by the agent.
+	HTTPEdgeSignatureKeyHeaderName = "X-PortainerAgent-Signature-Key"
+	// HTTPEdgeSignatureTimestampHeaderName is the name of the header used to send the timestamp (Unix time) covered
+	// by the signature of the data reported by the agent.
+	HTTPEdgeSignatureTimestampHeaderName = "X-PortainerAgent-Signature-Timestamp"
+	// HTTPEdgeResponseSignatureHeaderName is the name of the header used by the Portainer instance to send the
+	// signature of the poll response.
+	HTTPEdgeResponseSignatureHeaderName = "X-Portainer-Edge-Signature"
+	// HTTPEdgeRestartCountHeaderName is the name of the header used to specify the number of times the agent restarted.
+	HTTPEdgeRestartCountHeaderName = "X-PortainerAgent-Restart-Count"
+	// HTTPEdgeLastExitReasonHeaderName is the name of the header used to specify the reason of the last exit of the agent,
+	// either shutdown or unclean.
+	HTTPEdgeLastExitReasonHeaderName = "X-PortainerAgent-Last-Exit-Reason"
+	// HTTPEdgeUnhonoredRequestsHeaderName is the name of the header used to report the requests of the Portainer
+	// instance that the agent could not honor (base64 encoded JSON).
+	HTTPEdgeUnhonoredRequestsHeaderName = "X-PortainerAgent-Unhonored-Requests"
+	// HTTPEdgeBuildHeaderName is the name of the header used to send the build information of the agent,
+	// in the commit=<commit>;date=<date>;go=<version> format.
+	HTTPEdgeBuildHeaderName = "X-PortainerAgent-Build"
 	// HTTPManagerOperationHeaderName is the name of the header used to specify that
 	// a request must target a manager node.
 	HTTPManagerOperationHeaderName = "X-PortainerAgent-ManagerOperation"
@@ -249,6 +519,16 @@ const (
 	ScheduleScriptDirectory = "/opt/portainer/scripts"
 	// EdgeKeyFile is the name of the file used to persist the Edge key associated to the agent.
 	EdgeKeyFile = "agent_edge_key"
+	// EdgeRestartStateFile is the name of the file used to persist the restart count of the agent.
+	EdgeRestartStateFile = "agent_edge_restarts"
+	// EdgeSigningKeyFile is the name of the file used to persist the private key signing the reports of the agent.
+	EdgeSigningKeyFile = "agent_edge_signing_key"
+	// EdgeTunnelClientCertFile and EdgeTunnelClientKeyFile are the names of the files used to present a PKCS#12 client
+	// certificate to the tunnel server, which only supports PEM files.
+	EdgeTunnelClientCertFile = "agent_edge_tunnel_client_cert.pem"
+	EdgeTunnelClientKeyFile  = "agent_edge_tunnel_client_key.pem"
+	// EdgeReportQueueFolder is the name of the folder used to persist the reports queued while the Portainer instance is unreachable.
+	EdgeReportQueueFolder = "agent_edge_report_queue"
 	// DefaultAssetsPath is the default path of the binaries
 	DefaultAssetsPath = "/app"
 	// EdgeStackFilesPath is the path where edge stack files are saved
@@ -259,6 +539,10 @@ const (
 	KubernetesServiceHost = "KUBERNETES_SERVICE_HOST"
 	// KubernetesServicePortHttps is the environment variable of the kubernetes API server https port
 	KubernetesServicePortHttps = "KUBERNETES_SERVICE_PORT_HTTPS"
+	// KubernetesPodName is the environment variable containing the name of the agent pod, set through the downward API
+	KubernetesPodName = "KUBERNETES_POD_NAME"
+	// KubernetesPodNamespace is the environment variable containing the namespace of the agent pod, set through the downward API
+	KubernetesPodNamespace = "KUBERNETES_POD_NAMESPACE"
 )
 
 const (
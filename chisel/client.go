@@ -4,6 +4,8 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"net/http"
+	"strings"
 	"sync"
 	"time"
 
@@ -17,6 +19,7 @@ const tunnelClientTimeout = 10 * time.Second
 // Client is used to create a reverse proxy tunnel connected to a Portainer instance.
 type Client struct {
 	chiselClient *chclient.Client
+	relay        *activityRelay
 	tunnelOpen   bool
 	mu           sync.Mutex
 }
@@ -30,19 +33,50 @@ func NewClient() *Client {
 
 // CreateTunnel will create a reverse tunnel
 func (client *Client) CreateTunnel(tunnelConfig agent.TunnelConfig) error {
-	remote := fmt.Sprintf("R:%s:%s", tunnelConfig.RemotePort, tunnelConfig.LocalAddr)
+	localAddr := tunnelConfig.LocalAddr
+	if tunnelConfig.TrackActivity {
+		relay, err := newActivityRelay(tunnelConfig.LocalAddr)
+		if err != nil {
+			return err
+		}
+
+		client.mu.Lock()
+		client.relay = relay
+		client.mu.Unlock()
+
+		localAddr = relay.addr()
+	}
+
+	remote := fmt.Sprintf("R:%s:%s", tunnelConfig.RemotePort, localAddr)
 
 	log.Printf("[DEBUG] [chisel] [remote_port: %s] [local_addr: %s] [server: %s] [server_fingerprint: %s] [message: Creating reverse tunnel client]", tunnelConfig.RemotePort, tunnelConfig.LocalAddr, tunnelConfig.ServerAddr, tunnelConfig.ServerFingerpint)
 
+	serverAddr := tunnelConfig.ServerAddr
+	if tunnelConfig.ServerPathPrefix != "" {
+		serverAddr = strings.TrimSuffix(serverAddr, "/") + "/" + strings.TrimPrefix(tunnelConfig.ServerPathPrefix, "/")
+	}
+
+	headers := http.Header{}
+	for name, value := range tunnelConfig.ServerHeaders {
+		headers.Set(name, value)
+	}
+
 	config := &chclient.Config{
-		Server:      tunnelConfig.ServerAddr,
+		Server:      serverAddr,
 		Remotes:     []string{remote},
 		Fingerprint: tunnelConfig.ServerFingerpint,
 		Auth:        tunnelConfig.Credentials,
+		Headers:     headers,
+		Proxy:       tunnelConfig.Proxy,
+		TLS: chclient.TLSConfig{
+			Cert: tunnelConfig.ClientCert,
+			Key:  tunnelConfig.ClientKey,
+		},
 	}
 
 	chiselClient, err := chclient.NewClient(config)
 	if err != nil {
+		client.closeRelay()
 		return err
 	}
 
@@ -50,6 +84,7 @@ func (client *Client) CreateTunnel(tunnelConfig agent.TunnelConfig) error {
 
 	err = chiselClient.Start(context.Background())
 	if err != nil {
+		client.closeRelay()
 		return err
 	}
 
@@ -66,6 +101,8 @@ func (client *Client) CloseTunnel() error {
 	client.tunnelOpen = false
 	client.mu.Unlock()
 
+	client.closeRelay()
+
 	return client.chiselClient.Close()
 }
 
@@ -76,3 +113,32 @@ func (client *Client) IsTunnelOpen() bool {
 
 	return client.tunnelOpen
 }
+
+// Stats returns the traffic statistics of the tunnel, statistics are only available when
+// the tunnel was created with activity tracking enabled.
+func (client *Client) Stats() agent.TunnelStats {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+
+	if client.relay == nil {
+		return agent.TunnelStats{}
+	}
+
+	return client.relay.stats()
+}
+
+func (client *Client) closeRelay() {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+
+	if client.relay == nil {
+		return
+	}
+
+	err := client.relay.close()
+	if err != nil {
+		log.Printf("[DEBUG] [chisel] [message: unable to close the tunnel activity relay] [error: %s]", err)
+	}
+
+	client.relay = nil
+}
@@ -0,0 +1,121 @@
+package chisel
+
+import (
+	"io"
+	"log"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/portainer/agent"
+)
+
+// activityRelay is a local TCP relay placed between the reverse tunnel and its local address.
+// It records the amount of bytes transferred through the tunnel and the time of the last transfer.
+type activityRelay struct {
+	listener     net.Listener
+	targetAddr   string
+	bytesIn      uint64
+	bytesOut     uint64
+	lastActivity int64
+}
+
+func newActivityRelay(targetAddr string) (*activityRelay, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+
+	relay := &activityRelay{
+		listener:   listener,
+		targetAddr: targetAddr,
+	}
+
+	go relay.serve()
+
+	return relay, nil
+}
+
+// addr returns the address that must be used as the local end of the reverse tunnel.
+func (relay *activityRelay) addr() string {
+	return relay.listener.Addr().String()
+}
+
+func (relay *activityRelay) close() error {
+	return relay.listener.Close()
+}
+
+func (relay *activityRelay) stats() agent.TunnelStats {
+	stats := agent.TunnelStats{
+		BytesIn:  atomic.LoadUint64(&relay.bytesIn),
+		BytesOut: atomic.LoadUint64(&relay.bytesOut),
+	}
+
+	lastActivity := atomic.LoadInt64(&relay.lastActivity)
+	if lastActivity != 0 {
+		stats.LastActivity = time.Unix(0, lastActivity)
+	}
+
+	return stats
+}
+
+func (relay *activityRelay) serve() {
+	for {
+		conn, err := relay.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		go relay.handle(conn)
+	}
+}
+
+func (relay *activityRelay) handle(conn net.Conn) {
+	defer conn.Close()
+
+	target, err := net.Dial("tcp", relay.targetAddr)
+	if err != nil {
+		log.Printf("[ERROR] [chisel] [local_addr: %s] [message: unable to reach the tunnel local address] [error: %s]", relay.targetAddr, err)
+		return
+	}
+	defer target.Close()
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+
+	go func() {
+		defer wg.Done()
+		relay.copy(target, conn, &relay.bytesIn)
+		target.Close()
+	}()
+
+	go func() {
+		defer wg.Done()
+		relay.copy(conn, target, &relay.bytesOut)
+		conn.Close()
+	}()
+
+	wg.Wait()
+}
+
+func (relay *activityRelay) copy(dst io.Writer, src io.Reader, counter *uint64) {
+	buf := make([]byte, 32*1024)
+
+	for {
+		n, err := src.Read(buf)
+		if n > 0 {
+			atomic.AddUint64(counter, uint64(n))
+			atomic.StoreInt64(&relay.lastActivity, time.Now().UnixNano())
+
+			_, writeErr := dst.Write(buf[:n])
+			if writeErr != nil {
+				return
+			}
+		}
+
+		if err != nil {
+			return
+		}
+	}
+}
@@ -7,6 +7,8 @@ import (
 	gohttp "net/http"
 	goos "os"
 	"os/signal"
+	"strconv"
+	"strings"
 	"syscall"
 	"time"
 
@@ -15,6 +17,7 @@ import (
 	"github.com/portainer/agent/docker"
 	"github.com/portainer/agent/edge"
 	httpEdge "github.com/portainer/agent/edge/http"
+	rpcEdge "github.com/portainer/agent/edge/rpc"
 	"github.com/portainer/agent/exec"
 	"github.com/portainer/agent/ghw"
 	"github.com/portainer/agent/http"
@@ -34,6 +37,7 @@ func main() {
 	}
 
 	logutils.SetupLogger(options.LogLevel)
+	logutils.SetThrottleWindow(options.LogThrottleWindow)
 
 	systemService := ghw.NewSystemService(agent.HostRoot)
 	containerPlatform := os.DetermineContainerPlatform()
@@ -176,6 +180,19 @@ func main() {
 	// Edge
 	var edgeManager *edge.Manager
 	if options.EdgeMode {
+		if options.EdgeIDFile != "" {
+			if options.EdgeID != "" {
+				log.Fatal("[ERROR] [main] [message: The Edge identifier and the Edge identifier file cannot be specified at the same time]")
+			}
+
+			edgeID, err := edge.LoadEdgeID(options.EdgeIDFile)
+			if err != nil {
+				log.Fatalf("[ERROR] [main] [message: Unable to load the Edge identifier] [error: %s]", err)
+			}
+
+			options.EdgeID = edgeID
+		}
+
 		edgeManagerParameters := &edge.ManagerParameters{
 			Options:           options,
 			AdvertiseAddr:     advertiseAddr,
@@ -183,6 +200,24 @@ func main() {
 			DockerInfoService: dockerInfoService,
 			ContainerPlatform: containerPlatform,
 		}
+
+		if dockerInfoService != nil {
+			edgeManagerParameters.ContainerCounter = dockerInfoService
+			edgeManagerParameters.SnapshotProducer = dockerInfoService
+		} else if kubeClient != nil {
+			edgeManagerParameters.ContainerCounter = kubeClient
+			edgeManagerParameters.SnapshotProducer = kubeClient
+		}
+
+		if kubeClient != nil && options.EdgeKubernetesEvents {
+			eventRecorder, err := kubernetes.NewPodEventRecorder(kubeClient)
+			if err != nil {
+				log.Printf("[WARN] [main] [message: Unable to create the Kubernetes event recorder] [error: %s]", err)
+			} else {
+				edgeManagerParameters.EventRecorder = eventRecorder
+			}
+		}
+
 		edgeManager = edge.NewManager(edgeManagerParameters)
 
 		edgeKey, err := edgeManager.RetrieveEdgeKey(options.EdgeKey, clusterService)
@@ -208,6 +243,20 @@ func main() {
 
 			serveEdgeUI(edgeManager, options.EdgeServerAddr, options.EdgeServerPort)
 		}
+
+		if options.EdgeDiagnosticsSocket != "" {
+			if options.EdgeDiagnosticsAddr != "" {
+				log.Printf("[WARN] [main] [socket_path: %s] [server_address: %s] [message: Edge diagnostics socket specified, the diagnostics server will not listen on the TCP address]", options.EdgeDiagnosticsSocket, options.EdgeDiagnosticsAddr)
+			}
+
+			serveEdgeDiagnosticsOnSocket(edgeManager, options.EdgeDiagnosticsSocket, options.EdgeDiagSocketMode, options.DataPath, options.EdgeProfiling)
+		} else if options.EdgeDiagnosticsAddr != "" {
+			serveEdgeDiagnostics(edgeManager, options.EdgeDiagnosticsAddr, options.DataPath, options.EdgeProfiling)
+		}
+
+		if options.EdgeDiagnosticsGRPC != "" {
+			serveEdgeDiagnosticsGRPC(edgeManager, options.EdgeDiagnosticsGRPC, options.EdgeDiagSocketMode, options.EdgeDiagnosticsSocket != "")
+		}
 	}
 
 	// !Edge
@@ -232,6 +281,21 @@ func main() {
 		config.Addr = advertiseAddr
 	}
 
+	sigs := make(chan goos.Signal, 1)
+	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+
+	go func() {
+		s := <-sigs
+
+		fmt.Printf("[DEBUG] [main] [message: shutting down] [signal: %s]", s)
+
+		if edgeManager != nil {
+			edgeManager.Shutdown()
+		}
+
+		goos.Exit(0)
+	}()
+
 	err = startAPIServer(config, options.EdgeMode)
 	if err != nil && !errors.Is(err, gohttp.ErrServerClosed) {
 		log.Fatalf("[ERROR] [main] [message: Unable to start Agent API server] [error: %s]", err)
@@ -239,11 +303,8 @@ func main() {
 
 	// !API
 
-	sigs := make(chan goos.Signal, 1)
-	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
-	s := <-sigs
-
-	fmt.Printf("[DEBUG] [main] [message: shutting down] [signal: %s]", s)
+	// Wait for the shutdown signal
+	select {}
 }
 
 func startAPIServer(config *http.APIServerConfig, edgeMode bool) error {
@@ -285,3 +346,63 @@ func serveEdgeUI(edgeManager *edge.Manager, serverAddr, serverPort string) {
 		}
 	}()
 }
+
+func serveEdgeDiagnostics(edgeManager *edge.Manager, addr, dataPath string, profilingEnabled bool) {
+	diagnosticsServer := httpEdge.NewDiagnosticsServer(edgeManager, dataPath, profilingEnabled)
+
+	go func() {
+		log.Printf("[INFO] [main] [server_address: %s] [profiling_enabled: %t] [message: Starting Edge diagnostics server]", addr, profilingEnabled)
+
+		err := diagnosticsServer.Start(addr)
+		if err != nil {
+			log.Printf("[ERROR] [main] [message: Unable to start Edge diagnostics server] [error: %s]", err)
+		}
+	}()
+}
+
+func serveEdgeDiagnosticsGRPC(edgeManager *edge.Manager, addr, socketMode string, socketOnly bool) {
+	socketPath := strings.TrimPrefix(addr, "unix://")
+	if socketPath == addr && socketOnly {
+		log.Printf("[WARN] [main] [server_address: %s] [message: Edge diagnostics socket specified, the diagnostics gRPC server will not listen on the TCP address, use a unix:// address instead]", addr)
+		return
+	}
+
+	mode, err := strconv.ParseUint(socketMode, 8, 32)
+	if err != nil || mode > 0777 {
+		log.Fatalf("[ERROR] [main] [socket_mode: %s] [message: Invalid Edge diagnostics socket mode, expected octal file permissions]", socketMode)
+	}
+
+	diagnosticsServer := rpcEdge.NewDiagnosticsServer(edgeManager)
+
+	go func() {
+		log.Printf("[INFO] [main] [server_address: %s] [message: Starting Edge diagnostics gRPC server]", addr)
+
+		var err error
+		if socketPath != addr {
+			err = diagnosticsServer.StartOnSocket(socketPath, goos.FileMode(mode))
+		} else {
+			err = diagnosticsServer.Start(addr)
+		}
+		if err != nil {
+			log.Printf("[ERROR] [main] [message: Unable to start Edge diagnostics gRPC server] [error: %s]", err)
+		}
+	}()
+}
+
+func serveEdgeDiagnosticsOnSocket(edgeManager *edge.Manager, socketPath, socketMode, dataPath string, profilingEnabled bool) {
+	mode, err := strconv.ParseUint(socketMode, 8, 32)
+	if err != nil || mode > 0777 {
+		log.Fatalf("[ERROR] [main] [socket_mode: %s] [message: Invalid Edge diagnostics socket mode, expected octal file permissions]", socketMode)
+	}
+
+	diagnosticsServer := httpEdge.NewDiagnosticsServer(edgeManager, dataPath, profilingEnabled)
+
+	go func() {
+		log.Printf("[INFO] [main] [socket_path: %s] [socket_mode: %s] [profiling_enabled: %t] [message: Starting Edge diagnostics server]", socketPath, socketMode, profilingEnabled)
+
+		err := diagnosticsServer.StartOnSocket(socketPath, goos.FileMode(mode))
+		if err != nil {
+			log.Printf("[ERROR] [main] [message: Unable to start Edge diagnostics server] [error: %s]", err)
+		}
+	}()
+}
@@ -13,7 +13,8 @@ function compile() {
     mkdir -p $TARGET_DIST
 
     cd cmd/agent || exit 1
-    GOOS="linux" GOARCH="$(go env GOARCH)" CGO_ENABLED=0 go build --installsuffix cgo --ldflags '-s'
+    local BUILD_LDFLAGS="-X github.com/portainer/agent.GitCommit=$(git rev-parse --short HEAD 2>/dev/null) -X github.com/portainer/agent.BuildDate=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+    GOOS="linux" GOARCH="$(go env GOARCH)" CGO_ENABLED=0 go build --installsuffix cgo --ldflags "-s ${BUILD_LDFLAGS}"
     rc=$?
     if [[ $rc != 0 ]]; then exit $rc; fi
     cd ../..
@@ -136,3 +136,19 @@ func getSwarmConfiguration(config *agent.RuntimeConfiguration, dockerInfo types.
 
 	return nil
 }
+
+// ContainerCount returns the number of containers available on the Docker engine.
+func (service *InfoService) ContainerCount(ctx context.Context) (int, error) {
+	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithVersion(agent.SupportedDockerAPIVersion))
+	if err != nil {
+		return 0, err
+	}
+	defer cli.Close()
+
+	dockerInfo, err := cli.Info(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	return dockerInfo.Containers, nil
+}
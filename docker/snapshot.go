@@ -0,0 +1,68 @@
+package docker
+
+import (
+	"context"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/client"
+	"github.com/portainer/agent"
+)
+
+// Snapshot returns a summary of the containers, images and volumes available on the Docker engine.
+func (service *InfoService) Snapshot(ctx context.Context) (*agent.PlatformSnapshot, error) {
+	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithVersion(agent.SupportedDockerAPIVersion))
+	if err != nil {
+		return nil, err
+	}
+	defer cli.Close()
+
+	snapshot := &agent.PlatformSnapshot{}
+
+	containers, err := cli.ContainerList(ctx, types.ContainerListOptions{All: true})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, container := range containers {
+		name := ""
+		if len(container.Names) > 0 {
+			name = strings.TrimPrefix(container.Names[0], "/")
+		}
+
+		snapshot.Containers = append(snapshot.Containers, agent.SnapshotContainer{
+			ID:    container.ID,
+			Name:  name,
+			Image: container.Image,
+			State: container.State,
+		})
+	}
+
+	images, err := cli.ImageList(ctx, types.ImageListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, image := range images {
+		snapshot.Images = append(snapshot.Images, agent.SnapshotImage{
+			ID:   image.ID,
+			Tags: image.RepoTags,
+			Size: image.Size,
+		})
+	}
+
+	volumes, err := cli.VolumeList(ctx, filters.Args{})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, volume := range volumes.Volumes {
+		snapshot.Volumes = append(snapshot.Volumes, agent.SnapshotVolume{
+			Name:   volume.Name,
+			Driver: volume.Driver,
+		})
+	}
+
+	return snapshot, nil
+}
@@ -0,0 +1,45 @@
+package edge
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/portainer/agent"
+)
+
+// maxPendingActions is the maximum number of actions waiting to be reported, the oldest actions are
+// dropped when the Portainer instance cannot be reached for a while.
+const maxPendingActions = 50
+
+// queuePollActions queues the actions performed during the current poll so that their outcome is reported
+// to the Portainer instance with the next poll request.
+func (service *PollService) queuePollActions() {
+	if !service.reportActions || len(service.pollSummary.Actions) == 0 {
+		return
+	}
+
+	service.pendingActions = append(service.pendingActions, service.pollSummary.Actions...)
+	if len(service.pendingActions) > maxPendingActions {
+		service.pendingActions = service.pendingActions[len(service.pendingActions)-maxPendingActions:]
+	}
+}
+
+// setActionsHeader adds the outcome of the actions performed during the previous polls to the poll request.
+// It returns the number of reported actions, to be removed from the queue once the poll request succeeded.
+func (service *PollService) setActionsHeader(req *http.Request) int {
+	if !service.reportActions || len(service.pendingActions) == 0 {
+		return 0
+	}
+
+	data, err := json.Marshal(service.pendingActions)
+	if err != nil {
+		log.Printf("[WARN] [edge] [message: unable to encode the outcome of the previous actions, they will be reported with the next poll] [error: %s]", err)
+		return 0
+	}
+
+	req.Header.Set(agent.HTTPEdgeActionsHeaderName, base64.StdEncoding.EncodeToString(data))
+
+	return len(service.pendingActions)
+}
@@ -0,0 +1,69 @@
+package edge
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"runtime"
+
+	"github.com/portainer/agent"
+)
+
+const (
+	agentInfoTypeFull        = "full"
+	agentInfoTypeIncremental = "incremental"
+)
+
+// agentInfo contains the information of the agent that rarely changes, it is only sent to the Portainer instance
+// with the first poll after the agent started or reconnected and whenever it changes.
+type agentInfo struct {
+	Version  string   `json:"version"`
+	Platform int      `json:"platform"`
+	OS       string   `json:"os"`
+	Arch     string   `json:"arch"`
+	Features []string `json:"features"`
+}
+
+func (service *PollService) currentAgentInfo() agentInfo {
+	features := []string{}
+	if service.tunnelClient != nil {
+		features = append(features, "tunnel")
+	}
+	if service.reportBuilder != nil {
+		features = append(features, "status-report")
+	}
+	if service.containerCount != nil {
+		features = append(features, "container-count")
+	}
+	if service.pollEncoding == pollEncodingMsgpack {
+		features = append(features, "msgpack")
+	}
+
+	return agentInfo{
+		Version:  agent.Version,
+		Platform: int(service.containerPlatform),
+		OS:       runtime.GOOS,
+		Arch:     runtime.GOARCH,
+		Features: features,
+	}
+}
+
+// setAgentInfoHeaders adds the agent information to the poll request unless the Portainer instance already received
+// the same information. It returns the encoded information, to be recorded once the poll request succeeded.
+func (service *PollService) setAgentInfoHeaders(req *http.Request) (string, error) {
+	data, err := json.Marshal(service.currentAgentInfo())
+	if err != nil {
+		return "", err
+	}
+
+	encoded := base64.StdEncoding.EncodeToString(data)
+	if encoded == service.agentInfoSent {
+		req.Header.Set(agent.HTTPEdgeAgentInfoTypeHeaderName, agentInfoTypeIncremental)
+		return encoded, nil
+	}
+
+	req.Header.Set(agent.HTTPEdgeAgentInfoHeaderName, encoded)
+	req.Header.Set(agent.HTTPEdgeAgentInfoTypeHeaderName, agentInfoTypeFull)
+
+	return encoded, nil
+}
@@ -0,0 +1,190 @@
+package edge
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/portainer/agent"
+)
+
+const (
+	// asyncCommandEdgeStacks replaces the Edge stacks with the stacks of the command
+	asyncCommandEdgeStacks = "edgeStacks"
+	// asyncCommandSchedules replaces the schedules with the schedules of the command
+	asyncCommandSchedules = "schedules"
+
+	asyncSnapshotTimeout = 30 * time.Second
+)
+
+type asyncCommand struct {
+	ID      int             `json:"id"`
+	Type    string          `json:"type"`
+	Payload json.RawMessage `json:"payload"`
+}
+
+type asyncCommandResult struct {
+	ID      int    `json:"id"`
+	Success bool   `json:"success"`
+	Error   string `json:"error,omitempty"`
+}
+
+type asyncRequest struct {
+	Snapshot       *agent.PlatformSnapshot `json:"snapshot,omitempty"`
+	CommandResults []asyncCommandResult    `json:"commandResults"`
+}
+
+type asyncResponse struct {
+	Commands []asyncCommand `json:"commands"`
+}
+
+// asyncService implements the async Edge mode. At each exchange, a snapshot of the resources managed by the
+// container platform is pushed to the Portainer instance, which answers with a batch of commands. The results of
+// the commands are reported during the next exchange. When the async mode is enabled, the schedules and the Edge
+// stacks are only managed through the commands, the short poll is still used for the tunnel. The exchanges run in
+// the poll loop and share the poll HTTP client, so that they follow its settings as they are updated.
+type asyncService struct {
+	service  *PollService
+	producer agent.SnapshotProducer
+	ticker   *time.Ticker
+	results  []asyncCommandResult
+}
+
+func newAsyncService(service *PollService, producer agent.SnapshotProducer, interval time.Duration) *asyncService {
+	return &asyncService{
+		service:  service,
+		producer: producer,
+		ticker:   time.NewTicker(interval),
+	}
+}
+
+// exchange pushes a snapshot and the results of the previous commands to the Portainer instance and executes
+// the commands received in return. The results are kept and sent again when the exchange fails.
+func (async *asyncService) exchange() error {
+	request := asyncRequest{
+		Snapshot:       async.snapshot(),
+		CommandResults: async.results,
+	}
+
+	body, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+
+	asyncURL := fmt.Sprintf("%s/api/endpoints/%s/edge/async", async.service.replicas.activeURL(), async.service.endpointID)
+	req, err := http.NewRequest(http.MethodPost, asyncURL, nil)
+	if err != nil {
+		return err
+	}
+
+	err = async.service.newRequestBody(req, body)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(agent.HTTPEdgeIdentifierHeaderName, async.service.edgeID)
+	setInstanceHeaders(req)
+
+	resp, err := async.service.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		log.Printf("[DEBUG] [edge] [response_code: %d] [message: Async request failure]", resp.StatusCode)
+		return errors.New("async request failed")
+	}
+
+	// The commands are validated as a poll response before being executed
+	err = async.service.validatePollResponse(resp, time.Now())
+	if err != nil {
+		return err
+	}
+
+	var response asyncResponse
+	err = json.NewDecoder(resp.Body).Decode(&response)
+	if err != nil {
+		return err
+	}
+
+	async.results = nil
+	for _, command := range response.Commands {
+		result := asyncCommandResult{ID: command.ID, Success: true}
+
+		err := async.execute(command)
+		if err != nil {
+			log.Printf("[ERROR] [edge] [command_id: %d] [command_type: %s] [message: unable to execute async command] [error: %s]", command.ID, command.Type, err)
+			result.Success = false
+			result.Error = err.Error()
+		}
+
+		async.results = append(async.results, result)
+	}
+
+	log.Printf("[DEBUG] [edge] [command_count: %d] [message: async exchange completed]", len(response.Commands))
+
+	return nil
+}
+
+// snapshot captures a snapshot of the container platform, it returns nil when no snapshot can be captured.
+func (async *asyncService) snapshot() *agent.PlatformSnapshot {
+	if async.producer == nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), asyncSnapshotTimeout)
+	defer cancel()
+
+	snapshot, err := async.producer.Snapshot(ctx)
+	if err != nil {
+		log.Printf("[WARN] [edge] [message: unable to capture the platform snapshot, it will not be sent] [error: %s]", err)
+		return nil
+	}
+
+	return snapshot
+}
+
+func (async *asyncService) execute(command asyncCommand) error {
+	service := async.service
+
+	switch command.Type {
+	case asyncCommandEdgeStacks:
+		if !service.operationMode.stacks {
+			return fmt.Errorf("the Edge stacks are not managed in the %s operation mode", service.operationMode.name)
+		}
+
+		var stacks []stackStatus
+		err := json.Unmarshal(command.Payload, &stacks)
+		if err != nil {
+			return err
+		}
+
+		return service.reconcileStacks(context.Background(), stacks)
+	case asyncCommandSchedules:
+		if !service.operationMode.schedules {
+			return fmt.Errorf("the schedules are not managed in the %s operation mode", service.operationMode.name)
+		}
+
+		var received []agent.Schedule
+		err := json.Unmarshal(command.Payload, &received)
+		if err != nil {
+			return err
+		}
+
+		schedules := service.reconcileSchedules(context.Background(), received)
+		if len(received) > 0 && schedules == nil {
+			return errors.New("the schedules were rejected")
+		}
+
+		service.reconcileLogs(schedules)
+		return nil
+	}
+
+	return fmt.Errorf("unsupported command type %q", command.Type)
+}
@@ -0,0 +1,116 @@
+package edge
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// triggerBackoffRespect skips the immediate polls triggered while the poll service is backing off
+	triggerBackoffRespect = "respect"
+	// triggerBackoffIgnore executes the immediate polls triggered while the poll service is backing off
+	triggerBackoffIgnore = "ignore"
+)
+
+func parseTriggerBackoffMode(value string) (string, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "", triggerBackoffRespect:
+		return triggerBackoffRespect, nil
+	case triggerBackoffIgnore:
+		return triggerBackoffIgnore, nil
+	}
+
+	return "", fmt.Errorf("invalid trigger backoff mode %q, expected %s or %s", value, triggerBackoffRespect, triggerBackoffIgnore)
+}
+
+// parseRetryAfter returns the delay requested by the Retry-After header of a response, expressed either
+// in seconds or as an HTTP date. It returns 0 when the header is missing or invalid.
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+
+	seconds, err := strconv.Atoi(value)
+	if err == nil {
+		if seconds <= 0 {
+			return 0
+		}
+
+		return time.Duration(seconds) * time.Second
+	}
+
+	date, err := http.ParseTime(value)
+	if err != nil || !date.After(now) {
+		return 0
+	}
+
+	return date.Sub(now)
+}
+
+// updateBackoff starts a backoff period when the Portainer instance asked the agent to retry later,
+// the regular polls and the fast retries are skipped until the end of the period.
+func (service *PollService) updateBackoff(err error, now time.Time) {
+	retryAfter := retryAfterDelay(err)
+	if retryAfter <= 0 {
+		return
+	}
+
+	service.backoffUntil = now.Add(retryAfter)
+
+	log.Printf("[DEBUG] [edge] [backoff_seconds: %f] [message: the Portainer instance asked to retry later, backing off]", retryAfter.Seconds())
+}
+
+func (service *PollService) inBackoff(now time.Time) bool {
+	return now.Before(service.backoffUntil)
+}
+
+// shouldPollOnTrigger returns true when an immediate poll must be executed. When an immediate poll is triggered
+// during a backoff period, the backoff wins unless the trigger backoff mode is set to ignore.
+func (service *PollService) shouldPollOnTrigger(now time.Time) bool {
+	return !service.inBackoff(now) || service.triggerBackoffMode == triggerBackoffIgnore
+}
+
+// applyFailureBackoff increases the poll interval exponentially with the number of consecutive poll failures,
+// up to the configured maximum, so that an unreachable Portainer instance is not polled at full rate.
+func (service *PollService) applyFailureBackoff() {
+	if service.maxPollBackoff <= 0 || service.consecutivePollFailures == 0 {
+		return
+	}
+
+	interval := time.Duration(service.pollIntervalInSeconds * float64(time.Second))
+
+	delay := interval
+	for i := 0; i < service.consecutivePollFailures && delay < service.maxPollBackoff; i++ {
+		delay *= 2
+	}
+
+	if delay > service.maxPollBackoff {
+		delay = service.maxPollBackoff
+	}
+
+	if delay <= interval || delay == service.pollBackoff {
+		return
+	}
+
+	log.Printf("[DEBUG] [edge] [consecutive_failures: %d] [poll_interval_seconds: %f] [message: poll failing, increasing the poll interval]", service.consecutivePollFailures, delay.Seconds())
+
+	service.pollBackoff = delay
+	service.pollTicker.Reset(delay)
+}
+
+// resetFailureBackoff restores the regular poll interval after a successful poll.
+func (service *PollService) resetFailureBackoff() {
+	if service.pollBackoff == 0 {
+		return
+	}
+
+	log.Printf("[DEBUG] [edge] [poll_interval_seconds: %f] [message: poll succeeded, restoring the poll interval]", service.pollIntervalInSeconds)
+
+	service.pollBackoff = 0
+	service.pollTicker.Reset(service.jitteredPollInterval())
+}
@@ -0,0 +1,97 @@
+package edge
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestShouldPollOnTrigger(t *testing.T) {
+	now := time.Now()
+
+	tests := []struct {
+		mode         string
+		backoffUntil time.Time
+		expected     bool
+	}{
+		{mode: triggerBackoffRespect, backoffUntil: now.Add(time.Minute), expected: false},
+		{mode: triggerBackoffRespect, backoffUntil: now.Add(-time.Minute), expected: true},
+		{mode: triggerBackoffRespect, backoffUntil: time.Time{}, expected: true},
+		{mode: triggerBackoffIgnore, backoffUntil: now.Add(time.Minute), expected: true},
+		{mode: triggerBackoffIgnore, backoffUntil: time.Time{}, expected: true},
+	}
+
+	for _, test := range tests {
+		service := &PollService{triggerBackoffMode: test.mode, backoffUntil: test.backoffUntil}
+
+		result := service.shouldPollOnTrigger(now)
+		if result != test.expected {
+			t.Errorf("shouldPollOnTrigger() with mode %s and backoff until %s = %t, expected %t", test.mode, test.backoffUntil, result, test.expected)
+		}
+	}
+}
+
+func TestUpdateBackoff(t *testing.T) {
+	now := time.Now()
+	service := &PollService{triggerBackoffMode: triggerBackoffRespect}
+
+	service.updateBackoff(errors.New("connection refused"), now)
+	if service.inBackoff(now) {
+		t.Error("expected no backoff for an error without a retry delay")
+	}
+
+	service.updateBackoff(&pollStatusError{StatusCode: http.StatusTooManyRequests, RetryAfter: 30 * time.Second}, now)
+	if !service.inBackoff(now.Add(29 * time.Second)) {
+		t.Error("expected a backoff during the retry delay")
+	}
+
+	if service.inBackoff(now.Add(31 * time.Second)) {
+		t.Error("expected no backoff after the retry delay")
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2022, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		value    string
+		expected time.Duration
+	}{
+		{value: "", expected: 0},
+		{value: "120", expected: 2 * time.Minute},
+		{value: "-5", expected: 0},
+		{value: "Tue, 01 Mar 2022 12:01:00 GMT", expected: time.Minute},
+		{value: "Tue, 01 Mar 2022 11:59:00 GMT", expected: 0},
+		{value: "soon", expected: 0},
+	}
+
+	for _, test := range tests {
+		result := parseRetryAfter(test.value, now)
+		if result != test.expected {
+			t.Errorf("parseRetryAfter(%q) = %s, expected %s", test.value, result, test.expected)
+		}
+	}
+}
+
+func TestFailedFastRetryIsCountedOnce(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	service := newTestPollService(t, server.URL, WithPollBackoff(time.Hour))
+
+	service.guardedPoll(true)
+	service.guardedPoll(false)
+
+	if service.consecutivePollFailures != 1 {
+		t.Errorf("expected the failed poll cycle to be counted once, got %d consecutive failures", service.consecutivePollFailures)
+	}
+
+	expectedBackoff := 2 * time.Duration(service.pollIntervalInSeconds*float64(time.Second))
+	if service.pollBackoff != expectedBackoff {
+		t.Errorf("expected the poll interval to be increased once to %s, got %s", expectedBackoff, service.pollBackoff)
+	}
+}
@@ -0,0 +1,32 @@
+package edge
+
+import (
+	"fmt"
+	"net/http"
+	"runtime"
+
+	"github.com/portainer/agent"
+)
+
+const unknownBuildInfo = "unknown"
+
+// buildInfo returns the build information of the agent, the values that were not set at build time are reported
+// as unknown.
+func buildInfo() string {
+	commit := agent.GitCommit
+	if commit == "" {
+		commit = unknownBuildInfo
+	}
+
+	date := agent.BuildDate
+	if date == "" {
+		date = unknownBuildInfo
+	}
+
+	return fmt.Sprintf("commit=%s;date=%s;go=%s", commit, date, runtime.Version())
+}
+
+// setBuildHeader adds the build information of the agent to a request sent to the Portainer instance.
+func setBuildHeader(req *http.Request) {
+	req.Header.Set(agent.HTTPEdgeBuildHeaderName, buildInfo())
+}
@@ -0,0 +1,20 @@
+package edge
+
+// minScaledCheckinInterval is the minimum checkin interval, in seconds, once the open tunnel factor is applied
+const minScaledCheckinInterval = 1.0
+
+// effectiveCheckinInterval returns the interval, in seconds, between two polls. The checkin interval sent by the
+// Portainer instance is honored while the tunnel is closed, it is scaled by the open tunnel factor while the
+// tunnel is open.
+func (service *PollService) effectiveCheckinInterval(serverInterval float64) float64 {
+	if service.openTunnelFactor == 1 || !service.isTunnelOpen() {
+		return serverInterval
+	}
+
+	interval := serverInterval * service.openTunnelFactor
+	if interval < minScaledCheckinInterval {
+		return minScaledCheckinInterval
+	}
+
+	return interval
+}
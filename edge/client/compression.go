@@ -0,0 +1,24 @@
+package client
+
+import (
+	"bytes"
+	"compress/gzip"
+)
+
+// GzipBody compresses a request body sent to the Portainer instance with gzip.
+func GzipBody(data []byte) ([]byte, error) {
+	var buffer bytes.Buffer
+	writer := gzip.NewWriter(&buffer)
+
+	_, err := writer.Write(data)
+	if err != nil {
+		return nil, err
+	}
+
+	err = writer.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	return buffer.Bytes(), nil
+}
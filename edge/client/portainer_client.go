@@ -1,7 +1,6 @@
 package client
 
 import (
-	"bytes"
 	"crypto/tls"
 	"encoding/json"
 	"errors"
@@ -16,10 +15,14 @@ import (
 
 // PortainerClient is used to execute HTTP requests against the Portainer API
 type PortainerClient struct {
-	httpClient    *http.Client
-	serverAddress string
+	httpClient *http.Client
+	// serverAddress returns the URL of the Portainer instance the requests are sent to
+	serverAddress func() string
 	endpointID    string
 	edgeID        string
+	reportQueue   *ReportQueue
+	// compressRequests returns true when the report bodies must be compressed with gzip
+	compressRequests func() bool
 }
 
 // NewPortainerClient returns a pointer to a new PortainerClient instance
@@ -28,22 +31,68 @@ func NewPortainerClient(serverAddress, endpointID, edgeID string, insecurePoll b
 		Timeout: 10 * time.Second,
 	}
 
+	// The transport is replaced by the transport of the poll service, the default transport still honors the
+	// proxy environment variables
 	if insecurePoll {
-		httpCli.Transport = &http.Transport{
-			TLSClientConfig: &tls.Config{
-				InsecureSkipVerify: true,
-			},
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.TLSClientConfig = &tls.Config{
+			InsecureSkipVerify: true,
 		}
+		httpCli.Transport = transport
 	}
 
 	return &PortainerClient{
-		serverAddress: serverAddress,
+		serverAddress: func() string { return serverAddress },
 		endpointID:    endpointID,
 		edgeID:        edgeID,
 		httpClient:    httpCli,
 	}
 }
 
+// SetReportQueue sets the queue buffering the reports that cannot be sent while the Portainer instance is unreachable.
+func (client *PortainerClient) SetReportQueue(queue *ReportQueue) {
+	client.reportQueue = queue
+}
+
+// SetTransport sets the transport of the requests sent to the Portainer instance, so that they share the TLS, proxy
+// and dial settings of the poll requests. It must be called before any request is sent.
+func (client *PortainerClient) SetTransport(transport http.RoundTripper) {
+	client.httpClient.Transport = transport
+}
+
+// SetServerAddress sets the function returning the URL of the Portainer instance the requests are sent to, so that
+// they follow the Portainer instance used by the poll service. It must be called before any request is sent.
+func (client *PortainerClient) SetServerAddress(serverAddress func() string) {
+	client.serverAddress = serverAddress
+}
+
+// SetRequestCompression sets the function telling whether the report bodies must be compressed with gzip, it must
+// only return true once the Portainer instance advertised support for compressed request bodies.
+func (client *PortainerClient) SetRequestCompression(enabled func() bool) {
+	client.compressRequests = enabled
+}
+
+// sendReport sends a report to the Portainer instance, through the report queue when one is set. The path is
+// relative to the URL of the Portainer instance.
+func (client *PortainerClient) sendReport(operation, method, path string, data []byte) error {
+	report := queuedReport{
+		Operation: operation,
+		Method:    method,
+		Path:      path,
+		EdgeID:    client.edgeID,
+		Body:      data,
+	}
+
+	compress := client.compressRequests != nil && client.compressRequests()
+
+	if client.reportQueue != nil {
+		return client.reportQueue.send(client.httpClient, client.serverAddress(), report, compress)
+	}
+
+	_, err := sendReport(client.httpClient, client.serverAddress(), report, compress)
+	return err
+}
+
 type stackConfigResponse struct {
 	Name             string
 	StackFileContent string
@@ -51,7 +100,7 @@ type stackConfigResponse struct {
 
 // GetEdgeStackConfig retrieves the configuration associated to an Edge stack
 func (client *PortainerClient) GetEdgeStackConfig(edgeStackID int) (*agent.EdgeStackConfig, error) {
-	requestURL := fmt.Sprintf("%s/api/endpoints/%s/edge/stacks/%d", client.serverAddress, client.endpointID, edgeStackID)
+	requestURL := fmt.Sprintf("%s/api/endpoints/%s/edge/stacks/%d", client.serverAddress(), client.endpointID, edgeStackID)
 
 	req, err := http.NewRequest(http.MethodGet, requestURL, nil)
 	if err != nil {
@@ -104,28 +153,9 @@ func (client *PortainerClient) SetEdgeStackStatus(edgeStackID, edgeStackStatus i
 		return err
 	}
 
-	requestURL := fmt.Sprintf("%s/api/edge_stacks/%d/status", client.serverAddress, edgeStackID)
-
-	req, err := http.NewRequest(http.MethodPut, requestURL, bytes.NewReader(data))
-	if err != nil {
-		return err
-	}
-
-	req.Header.Set(agent.HTTPEdgeIdentifierHeaderName, client.edgeID)
-
-	resp, err := client.httpClient.Do(req)
-	if err != nil {
-		return err
-	}
-
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		log.Printf("[ERROR] [http,client] [response_code: %d] [message: SetEdgeStackStatus operation failed]", resp.StatusCode)
-		return errors.New("SetEdgeStackStatus operation failed")
-	}
+	path := fmt.Sprintf("/api/edge_stacks/%d/status", edgeStackID)
 
-	return nil
+	return client.sendReport("SetEdgeStackStatus", http.MethodPut, path, data)
 }
 
 type logFilePayload struct {
@@ -143,27 +173,7 @@ func (client *PortainerClient) SendJobLogFile(jobID int, fileContent []byte) err
 		return err
 	}
 
-	requestURL := fmt.Sprintf("%s/api/endpoints/%s/edge/jobs/%d/logs", client.serverAddress, client.endpointID, jobID)
-
-	req, err := http.NewRequest(http.MethodPost, requestURL, bytes.NewReader(data))
-	if err != nil {
-		return err
-	}
-
-	req.Header.Set(agent.HTTPEdgeIdentifierHeaderName, client.edgeID)
-
-	resp, err := client.httpClient.Do(req)
-	if err != nil {
-		return err
-	}
-
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		log.Printf("[ERROR] [http,client] [response_code: %d] [message: SendJobLogFile operation failed]", resp.StatusCode)
-		return errors.New("SendJobLogFile operation failed")
-	}
-
-	return nil
+	path := fmt.Sprintf("/api/endpoints/%s/edge/jobs/%d/logs", client.endpointID, jobID)
 
+	return client.sendReport("SendJobLogFile", http.MethodPost, path, data)
 }
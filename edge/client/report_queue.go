@@ -0,0 +1,301 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/portainer/agent"
+	"github.com/portainer/agent/filesystem"
+)
+
+const queuedReportExtension = ".json"
+
+// queuedReport is a report that could not be sent to the Portainer instance, it is replayed to the Portainer
+// instance in use at the time of the replay. The body is kept uncompressed, it is only compressed when sent to a
+// Portainer instance supporting compressed request bodies.
+type queuedReport struct {
+	Operation string    `json:"operation"`
+	Method    string    `json:"method"`
+	Path      string    `json:"path"`
+	EdgeID    string    `json:"edgeId"`
+	Body      []byte    `json:"body"`
+	QueuedAt  time.Time `json:"queuedAt"`
+}
+
+// queuedReportFile is the file persisting a queued report.
+type queuedReportFile struct {
+	name string
+	size int64
+}
+
+// ReportQueue buffers on disk the reports sent to the Portainer instance, such as the Edge stack status updates
+// and the schedule logs, while the Portainer instance cannot be reached. Each report is persisted in its own file
+// and the reports are replayed in order.
+type ReportQueue struct {
+	folder    string
+	maxSize   int64
+	files     []queuedReportFile
+	size      int64
+	sequence  uint64
+	replaying bool
+	mu        sync.Mutex
+	// sendMu serializes the deliveries of the reports so that a report is never delivered before an older one
+	sendMu sync.Mutex
+}
+
+// NewReportQueue returns a queue persisted in the specified folder holding at most maxSize bytes of reports, the
+// oldest reports are dropped when the queue is full. The reports persisted by a previous agent process are loaded.
+func NewReportQueue(folder string, maxSize int64) (*ReportQueue, error) {
+	if maxSize <= 0 {
+		return nil, fmt.Errorf("invalid report queue size %d, must be positive", maxSize)
+	}
+
+	queue := &ReportQueue{
+		folder:  filepath.Join(folder, agent.EdgeReportQueueFolder),
+		maxSize: maxSize,
+	}
+
+	err := os.MkdirAll(queue.folder, 0700)
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := filesystem.ListFilesInsideDirectory(queue.folder)
+	if err != nil {
+		return nil, err
+	}
+
+	// The files are listed by name, which is the sequence number of the report
+	for _, file := range files {
+		sequence, err := strconv.ParseUint(strings.TrimSuffix(file.Name, queuedReportExtension), 10, 64)
+		if err != nil || file.Dir {
+			continue
+		}
+
+		queue.files = append(queue.files, queuedReportFile{name: file.Name, size: file.Size})
+		queue.size += file.Size
+		queue.sequence = sequence
+	}
+
+	queue.mu.Lock()
+	queue.dropOldest()
+	queue.mu.Unlock()
+
+	if len(queue.files) > 0 {
+		log.Printf("[INFO] [http,client] [queued_reports: %d] [message: reports queued by a previous agent process will be replayed]", len(queue.files))
+	}
+
+	return queue, nil
+}
+
+// Len returns the number of queued reports.
+func (queue *ReportQueue) Len() int {
+	queue.mu.Lock()
+	defer queue.mu.Unlock()
+
+	return len(queue.files)
+}
+
+// Replay sends the queued reports in order to the Portainer instance with the specified HTTP client. It stops
+// at the first report that cannot be delivered, so that it is retried on the next call. Reports rejected by the
+// Portainer instance are dropped. The report bodies are compressed with gzip when compress is set, it must only
+// be set when the Portainer instance supports compressed request bodies.
+func (queue *ReportQueue) Replay(httpClient *http.Client, serverAddress string, compress bool) error {
+	queue.mu.Lock()
+	if queue.replaying || len(queue.files) == 0 {
+		queue.mu.Unlock()
+		return nil
+	}
+	queue.replaying = true
+	queue.mu.Unlock()
+
+	defer func() {
+		queue.mu.Lock()
+		queue.replaying = false
+		queue.mu.Unlock()
+	}()
+
+	replayed := 0
+	for {
+		queue.sendMu.Lock()
+		sent, err := queue.replayOldest(httpClient, serverAddress, compress)
+		queue.sendMu.Unlock()
+
+		if err != nil {
+			log.Printf("[DEBUG] [http,client] [replayed_reports: %d] [message: unable to replay the queued reports] [error: %s]", replayed, err)
+			return err
+		}
+
+		if !sent {
+			break
+		}
+		replayed++
+	}
+
+	log.Printf("[INFO] [http,client] [replayed_reports: %d] [message: queued reports replayed]", replayed)
+
+	return nil
+}
+
+// replayOldest sends the oldest queued report and removes it from the queue once it was delivered or rejected.
+// It returns false when the queue is empty. It must be called with the send lock held.
+func (queue *ReportQueue) replayOldest(httpClient *http.Client, serverAddress string, compress bool) (bool, error) {
+	queue.mu.Lock()
+	if len(queue.files) == 0 {
+		queue.mu.Unlock()
+		return false, nil
+	}
+	file := queue.files[0]
+	queue.mu.Unlock()
+
+	report, err := queue.read(file)
+	if err != nil {
+		log.Printf("[WARN] [http,client] [file: %s] [message: invalid queued report, dropping it] [error: %s]", file.name, err)
+	} else {
+		var retry bool
+		retry, err = sendReport(httpClient, serverAddress, report, compress)
+		if err != nil && retry {
+			return false, err
+		}
+
+		if err != nil {
+			log.Printf("[WARN] [http,client] [operation: %s] [queued_at: %s] [message: queued report rejected by the Portainer instance, dropping it] [error: %s]", report.Operation, report.QueuedAt.Format(time.RFC3339), err)
+		}
+	}
+
+	queue.mu.Lock()
+	defer queue.mu.Unlock()
+
+	// The report may have been dropped in the meantime when the queue was full
+	if len(queue.files) > 0 && queue.files[0] == file {
+		queue.remove()
+	}
+
+	return true, nil
+}
+
+// send delivers the report unless older reports are still queued, in which case it is queued behind them to
+// preserve the order. The report is also queued when it cannot be delivered.
+func (queue *ReportQueue) send(httpClient *http.Client, serverAddress string, report queuedReport, compress bool) error {
+	queue.sendMu.Lock()
+	defer queue.sendMu.Unlock()
+
+	if queue.Len() == 0 {
+		retry, err := sendReport(httpClient, serverAddress, report, compress)
+		if err == nil || !retry {
+			return err
+		}
+
+		log.Printf("[WARN] [http,client] [operation: %s] [message: unable to reach the Portainer instance, the report is queued] [error: %s]", report.Operation, err)
+	}
+
+	return queue.enqueue(report)
+}
+
+func (queue *ReportQueue) enqueue(report queuedReport) error {
+	report.QueuedAt = time.Now()
+
+	data, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+
+	queue.mu.Lock()
+	defer queue.mu.Unlock()
+
+	queue.sequence++
+	file := queuedReportFile{
+		name: fmt.Sprintf("%020d%s", queue.sequence, queuedReportExtension),
+		size: int64(len(data)),
+	}
+
+	err = filesystem.WriteFile(queue.folder, file.name, data, 0600)
+	if err != nil {
+		return err
+	}
+
+	queue.files = append(queue.files, file)
+	queue.size += file.size
+	queue.dropOldest()
+
+	return nil
+}
+
+// dropOldest drops the oldest reports until the queue fits in its maximum size, it must be called with the lock held.
+func (queue *ReportQueue) dropOldest() {
+	for queue.size > queue.maxSize && len(queue.files) > 0 {
+		log.Printf("[WARN] [http,client] [file: %s] [message: report queue full, dropping the oldest report]", queue.files[0].name)
+		queue.remove()
+	}
+}
+
+// remove removes the oldest report, it must be called with the lock held.
+func (queue *ReportQueue) remove() {
+	file := queue.files[0]
+	queue.files = queue.files[1:]
+	queue.size -= file.size
+
+	err := filesystem.RemoveFile(filepath.Join(queue.folder, file.name))
+	if err != nil && !os.IsNotExist(err) {
+		log.Printf("[WARN] [http,client] [file: %s] [message: unable to remove the queued report] [error: %s]", file.name, err)
+	}
+}
+
+func (queue *ReportQueue) read(file queuedReportFile) (queuedReport, error) {
+	var report queuedReport
+
+	data, err := filesystem.ReadFromFile(filepath.Join(queue.folder, file.name))
+	if err != nil {
+		return report, err
+	}
+
+	err = json.Unmarshal(data, &report)
+	return report, err
+}
+
+// sendReport sends a report to the Portainer instance at the specified URL, its body is compressed with gzip when
+// compress is set. It returns true when the report could not be delivered because of a network or server error,
+// the report can then be sent again later.
+func sendReport(httpClient *http.Client, serverAddress string, report queuedReport, compress bool) (bool, error) {
+	body := report.Body
+	if compress {
+		compressed, err := GzipBody(body)
+		if err != nil {
+			return false, err
+		}
+		body = compressed
+	}
+
+	req, err := http.NewRequest(report.Method, serverAddress+report.Path, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+
+	req.Header.Set(agent.HTTPEdgeIdentifierHeaderName, report.EdgeID)
+	if compress {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		log.Printf("[ERROR] [http,client] [response_code: %d] [message: %s operation failed]", resp.StatusCode, report.Operation)
+		return resp.StatusCode >= http.StatusInternalServerError, errors.New(report.Operation + " operation failed")
+	}
+
+	return false, nil
+}
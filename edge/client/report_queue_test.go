@@ -0,0 +1,210 @@
+package client
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+// reportServer records the paths of the reports it receives, it answers with a server error while it is down.
+type reportServer struct {
+	*httptest.Server
+	mu       sync.Mutex
+	down     bool
+	received []string
+}
+
+func newReportServer() *reportServer {
+	server := &reportServer{}
+	server.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		server.mu.Lock()
+		defer server.mu.Unlock()
+
+		if server.down {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		server.received = append(server.received, r.URL.Path)
+	}))
+
+	return server
+}
+
+func (server *reportServer) setDown(down bool) {
+	server.mu.Lock()
+	server.down = down
+	server.mu.Unlock()
+}
+
+func newTestReport(path string) queuedReport {
+	return queuedReport{Operation: "Test", Method: http.MethodPost, Path: path, Body: []byte("{}")}
+}
+
+func TestReportQueueReplaysInOrder(t *testing.T) {
+	server := newReportServer()
+	defer server.Close()
+
+	queue, err := NewReportQueue(t.TempDir(), 1024*1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server.setDown(true)
+	for _, path := range []string{"/1", "/2", "/3"} {
+		err := queue.send(server.Client(), server.URL, newTestReport(path), false)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if queue.Len() != 3 {
+		t.Fatalf("expected 3 queued reports, got %d", queue.Len())
+	}
+
+	server.setDown(false)
+
+	// A new report is queued behind the older reports
+	err = queue.send(server.Client(), server.URL, newTestReport("/4"), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = queue.Replay(server.Client(), server.URL, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"/1", "/2", "/3", "/4"}
+	if len(server.received) != len(expected) {
+		t.Fatalf("expected the reports %v, got %v", expected, server.received)
+	}
+	for i := range expected {
+		if server.received[i] != expected[i] {
+			t.Errorf("expected the reports %v, got %v", expected, server.received)
+			break
+		}
+	}
+
+	if queue.Len() != 0 {
+		t.Errorf("expected an empty queue, got %d reports", queue.Len())
+	}
+}
+
+func TestReportQueueDropsTheOldestReportsWhenFull(t *testing.T) {
+	folder := t.TempDir()
+
+	queue, err := NewReportQueue(folder, 1024*1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = queue.enqueue(newTestReport("/1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Room for two reports
+	queue.maxSize = 2*queue.size + queue.size/2
+
+	for _, path := range []string{"/2", "/3"} {
+		err := queue.enqueue(newTestReport(path))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if queue.Len() != 2 {
+		t.Fatalf("expected 2 queued reports, got %d", queue.Len())
+	}
+
+	report, err := queue.read(queue.files[0])
+	if err != nil || report.Path != "/2" {
+		t.Errorf("expected the oldest report to be dropped, the oldest queued report is %q (%v)", report.Path, err)
+	}
+
+	files, _ := ioutil.ReadDir(queue.folder)
+	if len(files) != 2 {
+		t.Errorf("expected the file of the dropped report to be removed, got %d files", len(files))
+	}
+}
+
+func TestReportQueueIsPersisted(t *testing.T) {
+	folder := t.TempDir()
+
+	queue, err := NewReportQueue(folder, 1024*1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, path := range []string{"/1", "/2"} {
+		err := queue.enqueue(newTestReport(path))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	reloaded, err := NewReportQueue(folder, 1024*1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if reloaded.Len() != 2 {
+		t.Fatalf("expected the 2 reports to be reloaded, got %d", reloaded.Len())
+	}
+
+	err = reloaded.enqueue(newTestReport("/3"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := newReportServer()
+	defer server.Close()
+
+	err = reloaded.Replay(server.Client(), server.URL, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(server.received) != 3 || server.received[0] != "/1" || server.received[2] != "/3" {
+		t.Errorf("expected the reloaded reports to be replayed first, got %v", server.received)
+	}
+}
+
+func TestQueuedReportIsCompressedForTheReplayTarget(t *testing.T) {
+	var contentEncoding, body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := ioutil.ReadAll(r.Body)
+		contentEncoding = r.Header.Get("Content-Encoding")
+		body = string(data)
+	}))
+	defer server.Close()
+
+	queue, err := NewReportQueue(t.TempDir(), 1024*1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The report is queued while the Portainer instance supporting compressed request bodies is unreachable
+	err = queue.send(server.Client(), "http://127.0.0.1:0", newTestReport("/1"), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := queue.read(queue.files[0])
+	if err != nil || string(report.Body) != "{}" {
+		t.Fatalf("expected the queued report to be stored uncompressed, got %q (%v)", report.Body, err)
+	}
+
+	// The report is replayed to a Portainer instance that does not support compressed request bodies
+	err = queue.Replay(server.Client(), server.URL, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if contentEncoding != "" || body != "{}" {
+		t.Errorf("expected the report to be replayed uncompressed, got %q encoded body %q", contentEncoding, body)
+	}
+}
@@ -0,0 +1,274 @@
+package edge
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/pkcs12"
+
+	"github.com/portainer/agent"
+	"github.com/portainer/agent/filesystem"
+)
+
+// clientCertificate is the client certificate presented to the Portainer instance and to the tunnel server when
+// they request one. The certificate is either a PEM certificate and key pair or a PKCS#12 bundle. When the reload is
+// enabled, the files are loaded again before a handshake once they were modified on disk.
+type clientCertificate struct {
+	certFile string
+	keyFile  string
+	password string
+	reload   bool
+	// folder is where the PEM copy of a PKCS#12 bundle presented to the tunnel server is written
+	folder string
+
+	mu      sync.Mutex
+	cert    *tls.Certificate
+	modTime time.Time
+	// tunnelModTime is the modification time of the certificate written for the tunnel server
+	tunnelModTime time.Time
+}
+
+func newClientCertificate(certFile, keyFile, password string, reload bool, folder string) (*clientCertificate, error) {
+	if certFile == "" {
+		if keyFile != "" {
+			return nil, errors.New("a client key was specified without a client certificate")
+		}
+
+		return nil, nil
+	}
+
+	clientCert := &clientCertificate{
+		certFile: certFile,
+		keyFile:  keyFile,
+		password: password,
+		reload:   reload,
+		folder:   folder,
+	}
+
+	if !clientCert.isPKCS12() && keyFile == "" {
+		return nil, fmt.Errorf("the client certificate %s requires a client key", certFile)
+	}
+
+	cert, modTime, err := clientCert.load()
+	if err != nil {
+		return nil, err
+	}
+
+	clientCert.cert = cert
+	clientCert.modTime = modTime
+
+	return clientCert, nil
+}
+
+// isPKCS12 returns true when the certificate file is a PKCS#12 bundle, based on its extension.
+func (clientCert *clientCertificate) isPKCS12() bool {
+	switch strings.ToLower(filepath.Ext(clientCert.certFile)) {
+	case ".p12", ".pfx":
+		return true
+	}
+
+	return false
+}
+
+// tunnelFiles returns the PEM certificate and key files presented to the tunnel server, they are empty when no
+// client certificate is set. The tunnel client only supports PEM files, the current certificate of a PKCS#12
+// bundle is therefore written as PEM files in the data folder.
+func (clientCert *clientCertificate) tunnelFiles() (string, string, error) {
+	if clientCert == nil {
+		return "", "", nil
+	}
+
+	if !clientCert.isPKCS12() {
+		return clientCert.certFile, clientCert.keyFile, nil
+	}
+
+	if clientCert.folder == "" {
+		return "", "", errors.New("a data folder is required to present a PKCS#12 client certificate to the tunnel server")
+	}
+
+	// Reload the bundle if it was modified on disk
+	cert, err := clientCert.getClientCertificate(nil)
+	if err != nil {
+		return "", "", err
+	}
+
+	certPath := filepath.Join(clientCert.folder, agent.EdgeTunnelClientCertFile)
+	keyPath := filepath.Join(clientCert.folder, agent.EdgeTunnelClientKeyFile)
+
+	clientCert.mu.Lock()
+	defer clientCert.mu.Unlock()
+
+	if !clientCert.tunnelModTime.IsZero() && !clientCert.modTime.After(clientCert.tunnelModTime) {
+		return certPath, keyPath, nil
+	}
+
+	var certPEM bytes.Buffer
+	for _, der := range cert.Certificate {
+		pem.Encode(&certPEM, &pem.Block{Type: "CERTIFICATE", Bytes: der})
+	}
+
+	keyDER, err := x509.MarshalPKCS8PrivateKey(cert.PrivateKey)
+	if err != nil {
+		return "", "", err
+	}
+
+	err = filesystem.WriteFile(clientCert.folder, agent.EdgeTunnelClientKeyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}), 0600)
+	if err != nil {
+		return "", "", err
+	}
+
+	err = filesystem.WriteFile(clientCert.folder, agent.EdgeTunnelClientCertFile, certPEM.Bytes(), 0600)
+	if err != nil {
+		return "", "", err
+	}
+
+	clientCert.tunnelModTime = clientCert.modTime
+
+	return certPath, keyPath, nil
+}
+
+func (clientCert *clientCertificate) load() (*tls.Certificate, time.Time, error) {
+	modTime, err := clientCert.lastModification()
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	if !clientCert.isPKCS12() {
+		cert, err := tls.LoadX509KeyPair(clientCert.certFile, clientCert.keyFile)
+		if err != nil {
+			return nil, time.Time{}, fmt.Errorf("unable to load the client certificate %s: %w", clientCert.certFile, err)
+		}
+
+		return &cert, modTime, nil
+	}
+
+	data, err := ioutil.ReadFile(clientCert.certFile)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	cert, err := decodePKCS12Chain(data, clientCert.password)
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("unable to decode the PKCS#12 client certificate %s: %w", clientCert.certFile, err)
+	}
+
+	return cert, modTime, nil
+}
+
+// decodePKCS12Chain decodes a PKCS#12 bundle holding a private key, the matching certificate and optionally the
+// intermediate certificates, which are presented after the certificate of the key.
+func decodePKCS12Chain(data []byte, password string) (*tls.Certificate, error) {
+	blocks, err := pkcs12.ToPEM(data, password)
+	if err != nil {
+		return nil, err
+	}
+
+	cert := &tls.Certificate{}
+	var certs []*x509.Certificate
+	for _, block := range blocks {
+		switch block.Type {
+		case "PRIVATE KEY":
+			cert.PrivateKey, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+			if err != nil {
+				cert.PrivateKey, err = x509.ParseECPrivateKey(block.Bytes)
+			}
+			if err != nil {
+				return nil, errors.New("unsupported private key")
+			}
+		case "CERTIFICATE":
+			parsed, err := x509.ParseCertificate(block.Bytes)
+			if err != nil {
+				return nil, err
+			}
+			certs = append(certs, parsed)
+		}
+	}
+
+	signer, ok := cert.PrivateKey.(crypto.Signer)
+	if !ok {
+		return nil, errors.New("no private key found")
+	}
+
+	public, ok := signer.Public().(interface{ Equal(crypto.PublicKey) bool })
+	if !ok {
+		return nil, errors.New("unsupported private key")
+	}
+
+	for _, parsed := range certs {
+		if public.Equal(parsed.PublicKey) {
+			cert.Leaf = parsed
+			cert.Certificate = append([][]byte{parsed.Raw}, cert.Certificate...)
+		} else {
+			cert.Certificate = append(cert.Certificate, parsed.Raw)
+		}
+	}
+
+	if cert.Leaf == nil {
+		return nil, errors.New("no certificate matching the private key found")
+	}
+
+	return cert, nil
+}
+
+// lastModification returns the most recent modification time of the certificate and key files.
+func (clientCert *clientCertificate) lastModification() (time.Time, error) {
+	var modTime time.Time
+
+	for _, file := range []string{clientCert.certFile, clientCert.keyFile} {
+		if file == "" {
+			continue
+		}
+
+		info, err := os.Stat(file)
+		if err != nil {
+			return time.Time{}, err
+		}
+
+		if info.ModTime().After(modTime) {
+			modTime = info.ModTime()
+		}
+	}
+
+	return modTime, nil
+}
+
+// getClientCertificate is used as the GetClientCertificate callback of the TLS configuration. When the certificate
+// cannot be reloaded, the previous certificate is presented.
+func (clientCert *clientCertificate) getClientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	clientCert.mu.Lock()
+	defer clientCert.mu.Unlock()
+
+	if !clientCert.reload {
+		return clientCert.cert, nil
+	}
+
+	modTime, err := clientCert.lastModification()
+	if err != nil || !modTime.After(clientCert.modTime) {
+		return clientCert.cert, nil
+	}
+
+	cert, modTime, err := clientCert.load()
+	if err != nil {
+		log.Printf("[WARN] [edge] [cert_file: %s] [message: unable to reload the client certificate, the previous certificate is used] [error: %s]", clientCert.certFile, err)
+		return clientCert.cert, nil
+	}
+
+	log.Printf("[INFO] [edge] [cert_file: %s] [message: client certificate reloaded]", clientCert.certFile)
+
+	clientCert.cert = cert
+	clientCert.modTime = modTime
+
+	return clientCert.cert, nil
+}
@@ -0,0 +1,93 @@
+package edge
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/portainer/agent/edge/client"
+)
+
+func writeClientCertificate(t *testing.T, folder string) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "edge-agent"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certFile := filepath.Join(folder, "client.crt")
+	keyFile := filepath.Join(folder, "client.key")
+	ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600)
+	ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600)
+
+	return certFile, keyFile
+}
+
+func TestPortainerClientPresentsTheClientCertificate(t *testing.T) {
+	folder := t.TempDir()
+	certFile, keyFile := writeClientCertificate(t, folder)
+
+	clientCert, err := newClientCertificate(certFile, keyFile, "", false, folder)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	server.StartTLS()
+	defer server.Close()
+
+	rootCAs := x509.NewCertPool()
+	rootCAs.AddCert(server.Certificate())
+	service := &PollService{rootCAs: rootCAs, clientCert: clientCert}
+
+	portainerClient := client.NewPortainerClient(server.URL, "1", "edge-id", false)
+	portainerClient.SetTransport(service.newTransport(false))
+
+	err = portainerClient.SendJobLogFile(1, []byte("logs"))
+	if err != nil {
+		t.Errorf("expected the client certificate to be presented, got %s", err)
+	}
+
+	tunnelCert, tunnelKey, err := clientCert.tunnelFiles()
+	if err != nil || tunnelCert != certFile || tunnelKey != keyFile {
+		t.Errorf("expected the PEM files to be presented to the tunnel server, got %q and %q (%v)", tunnelCert, tunnelKey, err)
+	}
+}
+
+func TestTunnelFilesRequireADataFolderForPKCS12(t *testing.T) {
+	clientCert := &clientCertificate{certFile: "client.p12"}
+
+	_, _, err := clientCert.tunnelFiles()
+	if err == nil {
+		t.Error("expected an error without a data folder")
+	}
+}
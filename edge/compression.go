@@ -0,0 +1,50 @@
+package edge
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"sync/atomic"
+
+	"github.com/portainer/agent/edge/client"
+)
+
+// capabilityRequestCompression is advertised by the Portainer instance when it accepts request bodies compressed
+// with gzip.
+const capabilityRequestCompression = "request-gzip"
+
+// setRequestCompressionSupported records whether the Portainer instance advertised support for compressed request
+// bodies in its last poll response.
+func (service *PollService) setRequestCompressionSupported(supported bool) {
+	var value int32
+	if supported {
+		value = 1
+	}
+
+	atomic.StoreInt32(&service.requestCompression, value)
+}
+
+// compressRequests returns true when the request bodies sent to the Portainer instance must be compressed, the
+// compression must be enabled and supported by the Portainer instance.
+func (service *PollService) compressRequests() bool {
+	return service.compression && atomic.LoadInt32(&service.requestCompression) == 1
+}
+
+// newRequestBody sets the body of a request sent to the Portainer instance, the body is compressed with gzip
+// when the compression is enabled and supported by the Portainer instance.
+func (service *PollService) newRequestBody(req *http.Request, body []byte) error {
+	if service.compressRequests() {
+		compressed, err := client.GzipBody(body)
+		if err != nil {
+			return err
+		}
+
+		body = compressed
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+
+	req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	req.ContentLength = int64(len(body))
+
+	return nil
+}
@@ -0,0 +1,34 @@
+package edge
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestRequestBodyIsOnlyCompressedOnceSupported(t *testing.T) {
+	tests := []struct {
+		name      string
+		enabled   bool
+		supported bool
+		want      string
+	}{
+		{name: "disabled", supported: true},
+		{name: "not advertised", enabled: true},
+		{name: "enabled and advertised", enabled: true, supported: true, want: "gzip"},
+	}
+
+	for _, test := range tests {
+		service := &PollService{compression: test.enabled}
+		service.setRequestCompressionSupported(test.supported)
+
+		req, _ := http.NewRequest(http.MethodPost, "https://portainer.example.com/api", nil)
+		err := service.newRequestBody(req, []byte(`{"commandResults":[]}`))
+		if err != nil {
+			t.Fatalf("%s: unexpected error %s", test.name, err)
+		}
+
+		if encoding := req.Header.Get("Content-Encoding"); encoding != test.want {
+			t.Errorf("%s: expected the %q content encoding, got %q", test.name, test.want, encoding)
+		}
+	}
+}
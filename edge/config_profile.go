@@ -0,0 +1,118 @@
+package edge
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/portainer/agent/os"
+)
+
+// configProfile bundles a set of poll service settings adapted to a specific kind of environment. The settings
+// populated by a profile are identified by the environment variable of their option.
+type configProfile struct {
+	PollFrequency        string
+	InactivityTimeout    string
+	FastRetryStatusCodes string
+	SlowPollThreshold    time.Duration
+	TLSSessionCacheSize  int
+	TLSRenegotiation     string
+	TunnelReadinessCheck bool
+	TunnelIdleProbe      bool
+	NetworkCheck         bool
+	DecodeRetry          bool
+	ReportActions        bool
+}
+
+var configProfiles = map[string]configProfile{
+	"dev": {
+		PollFrequency:     "2s",
+		InactivityTimeout: "1m",
+		SlowPollThreshold: 5 * time.Second,
+		DecodeRetry:       true,
+		ReportActions:     true,
+	},
+	"staging": {
+		PollFrequency:        "5s",
+		InactivityTimeout:    "5m",
+		FastRetryStatusCodes: "503",
+		TLSSessionCacheSize:  64,
+		TLSRenegotiation:     tlsRenegotiationNever,
+		TunnelReadinessCheck: true,
+		TunnelIdleProbe:      true,
+		NetworkCheck:         true,
+		DecodeRetry:          true,
+		ReportActions:        true,
+	},
+	"prod": {
+		PollFrequency:        "10s",
+		InactivityTimeout:    "5m",
+		FastRetryStatusCodes: "502,503,504",
+		TLSSessionCacheSize:  256,
+		TLSRenegotiation:     tlsRenegotiationNever,
+		TunnelReadinessCheck: true,
+		TunnelIdleProbe:      true,
+		NetworkCheck:         true,
+		DecodeRetry:          true,
+	},
+}
+
+// applyConfigProfile populates the poll service configuration with the settings of the named profile.
+// Settings that were explicitly configured take precedence over the profile, even when they are set to their
+// default value. A profile never disables the TLS verification.
+func applyConfigProfile(config *pollServiceConfig, profileName string, explicit map[string]bool) error {
+	if profileName == "" {
+		return nil
+	}
+
+	profile, ok := configProfiles[profileName]
+	if !ok {
+		return fmt.Errorf("unknown configuration profile %q", profileName)
+	}
+
+	if !explicit[os.EnvKeyEdgePollFrequency] && profile.PollFrequency != "" {
+		config.PollFrequency = profile.PollFrequency
+	}
+
+	if !explicit[os.EnvKeyEdgeInactivityTimeout] && profile.InactivityTimeout != "" {
+		config.InactivityTimeout = profile.InactivityTimeout
+	}
+
+	if !explicit[os.EnvKeyEdgeFastRetryCodes] && profile.FastRetryStatusCodes != "" {
+		config.FastRetryStatusCodes = profile.FastRetryStatusCodes
+	}
+
+	if !explicit[os.EnvKeyEdgeSlowPollThreshold] && profile.SlowPollThreshold != 0 {
+		config.SlowPollThreshold = profile.SlowPollThreshold
+	}
+
+	if !explicit[os.EnvKeyEdgeTLSSessionCache] && profile.TLSSessionCacheSize != 0 {
+		config.TLSSessionCacheSize = profile.TLSSessionCacheSize
+	}
+
+	if !explicit[os.EnvKeyEdgeTLSRenegotiation] && profile.TLSRenegotiation != "" {
+		config.TLSRenegotiation = profile.TLSRenegotiation
+	}
+
+	// The feature flags of the profile replace the defaults, a flag left out of the profile is disabled
+	if !explicit[os.EnvKeyEdgeTunnelReadiness] {
+		config.TunnelReadinessCheck = profile.TunnelReadinessCheck
+	}
+
+	if !explicit[os.EnvKeyEdgeTunnelIdleProbe] {
+		config.TunnelIdleProbe = profile.TunnelIdleProbe
+	}
+
+	if !explicit[os.EnvKeyEdgeNetworkCheck] {
+		config.NetworkCheck = profile.NetworkCheck
+	}
+
+	if !explicit[os.EnvKeyEdgeDecodeRetry] {
+		config.DecodeRetry = profile.DecodeRetry
+	}
+
+	if !explicit[os.EnvKeyEdgeReportActions] {
+		config.ReportActions = profile.ReportActions
+	}
+
+	return nil
+}
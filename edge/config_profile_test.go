@@ -0,0 +1,63 @@
+package edge
+
+import (
+	"testing"
+
+	"github.com/portainer/agent"
+	"github.com/portainer/agent/os"
+)
+
+func TestApplyConfigProfileKeepsExplicitSettings(t *testing.T) {
+	config := &pollServiceConfig{
+		PollFrequency:        agent.DefaultEdgePollInterval,
+		InactivityTimeout:    agent.DefaultEdgeSleepInterval,
+		TunnelReadinessCheck: false,
+		TLSSessionCacheSize:  agent.DefaultEdgeTLSSessionCacheSize,
+	}
+
+	explicit := map[string]bool{
+		os.EnvKeyEdgePollFrequency:   true,
+		os.EnvKeyEdgeTunnelReadiness: true,
+		os.EnvKeyEdgeTLSSessionCache: true,
+	}
+
+	err := applyConfigProfile(config, "prod", explicit)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if config.PollFrequency != agent.DefaultEdgePollInterval {
+		t.Errorf("expected the explicit poll frequency to be kept, got %s", config.PollFrequency)
+	}
+
+	if config.TunnelReadinessCheck {
+		t.Error("expected the explicitly disabled tunnel readiness check to be kept")
+	}
+
+	if config.TLSSessionCacheSize != agent.DefaultEdgeTLSSessionCacheSize {
+		t.Errorf("expected the explicit TLS session cache size to be kept, got %d", config.TLSSessionCacheSize)
+	}
+
+	if config.InactivityTimeout != configProfiles["prod"].InactivityTimeout {
+		t.Errorf("expected the inactivity timeout of the profile, got %s", config.InactivityTimeout)
+	}
+
+	if config.TLSRenegotiation != configProfiles["prod"].TLSRenegotiation || !config.NetworkCheck {
+		t.Errorf("expected the TLS settings and the feature flags of the profile, got renegotiation %q and network check %t", config.TLSRenegotiation, config.NetworkCheck)
+	}
+}
+
+func TestApplyConfigProfileNeverDisablesTLSVerification(t *testing.T) {
+	for name := range configProfiles {
+		config := &pollServiceConfig{}
+
+		err := applyConfigProfile(config, name, nil)
+		if err != nil {
+			t.Fatalf("unexpected error for the %s profile: %s", name, err)
+		}
+
+		if config.InsecurePoll {
+			t.Errorf("the %s profile must not disable the TLS verification", name)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package edge
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+)
+
+// connLifetimeTracker tracks the connections opened by the poll HTTP client so that connections
+// can be recycled once they reach a maximum lifetime, even if they never stay idle long enough to be
+// closed by the transport.
+type connLifetimeTracker struct {
+	maxLifetime time.Duration
+	mu          sync.Mutex
+	conns       map[*trackedConn]time.Time
+}
+
+type trackedConn struct {
+	net.Conn
+	tracker   *connLifetimeTracker
+	closeOnce sync.Once
+}
+
+func newConnLifetimeTracker(maxLifetime time.Duration) *connLifetimeTracker {
+	return &connLifetimeTracker{
+		maxLifetime: maxLifetime,
+		conns:       map[*trackedConn]time.Time{},
+	}
+}
+
+// dialContext returns a dial function that tracks the connections created by the dialer.
+func (tracker *connLifetimeTracker) dialContext(dial dialContextFunc) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := dial(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+
+		tracked := &trackedConn{Conn: conn, tracker: tracker}
+
+		tracker.mu.Lock()
+		tracker.conns[tracked] = time.Now()
+		tracker.mu.Unlock()
+
+		return tracked, nil
+	}
+}
+
+// hasExpiredConns returns true when at least one of the open connections exceeded the maximum lifetime.
+func (tracker *connLifetimeTracker) hasExpiredConns() bool {
+	tracker.mu.Lock()
+	defer tracker.mu.Unlock()
+
+	for _, createdAt := range tracker.conns {
+		if time.Since(createdAt) > tracker.maxLifetime {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (conn *trackedConn) Close() error {
+	conn.closeOnce.Do(func() {
+		conn.tracker.mu.Lock()
+		delete(conn.tracker.conns, conn)
+		conn.tracker.mu.Unlock()
+	})
+
+	return conn.Conn.Close()
+}
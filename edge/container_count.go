@@ -0,0 +1,53 @@
+package edge
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/portainer/agent"
+)
+
+const (
+	containerCountTimeout  = 2 * time.Second
+	containerCountCacheTTL = 30 * time.Second
+)
+
+// containerCountCache caches the number of managed containers so that the container platform
+// is not queried on every poll request.
+type containerCountCache struct {
+	provider  agent.ContainerCountProvider
+	mu        sync.Mutex
+	count     int
+	updatedAt time.Time
+}
+
+func newContainerCountCache(provider agent.ContainerCountProvider) *containerCountCache {
+	return &containerCountCache{
+		provider: provider,
+	}
+}
+
+// get returns the number of managed containers, the container platform is only queried
+// when the cached value is expired.
+func (cache *containerCountCache) get() (int, error) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	if !cache.updatedAt.IsZero() && time.Since(cache.updatedAt) < containerCountCacheTTL {
+		return cache.count, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), containerCountTimeout)
+	defer cancel()
+
+	count, err := cache.provider.ContainerCount(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	cache.count = count
+	cache.updatedAt = time.Now()
+
+	return count, nil
+}
@@ -0,0 +1,115 @@
+package edge
+
+import (
+	"errors"
+	"net"
+	"net/url"
+	"time"
+)
+
+const (
+	selfTestDialTimeout = 5 * time.Second
+
+	selfTestCheckNetwork      = "network"
+	selfTestCheckPortainer    = "portainer"
+	selfTestCheckTunnelServer = "tunnel_server"
+)
+
+var errManagerNotStarted = errors.New("Edge manager is not started")
+
+// SelfTestCheck is the result of one of the checks executed by a self test.
+type SelfTestCheck struct {
+	Name    string
+	Success bool
+	Error   string
+}
+
+// TriggerPoll requests an immediate poll of the Portainer instance.
+func (manager *Manager) TriggerPoll() error {
+	if manager.pollService == nil {
+		return errManagerNotStarted
+	}
+
+	manager.pollService.triggerPoll()
+	return nil
+}
+
+// CloseTunnels closes the reverse tunnel if it is open, it is opened again on the next poll if still required.
+func (manager *Manager) CloseTunnels() error {
+	if manager.pollService == nil {
+		return errManagerNotStarted
+	}
+
+	if !manager.pollService.isTunnelOpen() {
+		return nil
+	}
+
+	return manager.pollService.closeTunnel(tunnelCloseReasonManual)
+}
+
+// SelfTest checks that the network is available and that the Portainer instance and the tunnel server accept
+// TCP connections.
+func (manager *Manager) SelfTest() ([]SelfTestCheck, error) {
+	if manager.pollService == nil {
+		return nil, errManagerNotStarted
+	}
+
+	return manager.pollService.selfTest(), nil
+}
+
+func (service *PollService) selfTest() []SelfTestCheck {
+	checks := []SelfTestCheck{}
+
+	network := SelfTestCheck{Name: selfTestCheckNetwork, Success: networkAvailable()}
+	if !network.Success {
+		network.Error = "no active network interface or default route"
+	}
+	checks = append(checks, network)
+
+	portainerAddr, err := dialAddr(service.replicas.activeURL())
+	checks = append(checks, service.selfTestDial(selfTestCheckPortainer, portainerAddr, err))
+
+	if service.tunnelClient != nil {
+		checks = append(checks, service.selfTestDial(selfTestCheckTunnelServer, service.tunnelServerAddr, nil))
+	}
+
+	return checks
+}
+
+func (service *PollService) selfTestDial(name, addr string, err error) SelfTestCheck {
+	check := SelfTestCheck{Name: name}
+
+	if err == nil {
+		var conn net.Conn
+		conn, err = service.dialTimeout(addr, selfTestDialTimeout)
+		if err == nil {
+			conn.Close()
+		}
+	}
+
+	if err != nil {
+		check.Error = err.Error()
+		return check
+	}
+
+	check.Success = true
+	return check
+}
+
+// dialAddr returns the HOST:PORT address of a URL, using the default port of the scheme when none is specified.
+func dialAddr(rawURL string) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+
+	port := u.Port()
+	if port == "" {
+		port = "443"
+		if u.Scheme == "http" {
+			port = "80"
+		}
+	}
+
+	return net.JoinHostPort(u.Hostname(), port), nil
+}
@@ -0,0 +1,136 @@
+package edge
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/portainer/libcrypto"
+)
+
+const (
+	// unexpectedCredentialsIgnore ignores the credentials sent with a status other than REQUIRED.
+	unexpectedCredentialsIgnore = "ignore"
+	// unexpectedCredentialsWarn logs a warning when credentials are sent with a status other than REQUIRED.
+	unexpectedCredentialsWarn = "warn"
+	// unexpectedCredentialsCache decrypts and caches the credentials sent with a status other than REQUIRED
+	// so that they can be used to open the tunnel faster when the REQUIRED status is received.
+	unexpectedCredentialsCache = "cache"
+
+	credentialsCacheTTL = 2 * time.Minute
+
+	maxCredentialsLength = 512
+)
+
+// errInvalidDecryptedCredentials is returned when the tunnel credentials can be decrypted but do not have
+// the expected user:password structure, which usually means that the Edge ID does not match the Edge key.
+var errInvalidDecryptedCredentials = errors.New("decryption produced invalid credentials, make sure that the Edge ID matches the Edge key")
+
+// errMissingTunnelCredentials is returned when the Portainer instance requires a tunnel without sending the
+// credentials needed to open it, which usually means that the Portainer instance is misconfigured.
+var errMissingTunnelCredentials = errors.New("the Portainer instance requested a tunnel but provided no credentials, check the configuration of the Portainer instance")
+
+type cachedCredentials struct {
+	encoded   string
+	decrypted string
+	expiresAt time.Time
+}
+
+func parseUnexpectedCredentialsMode(mode string) (string, error) {
+	switch mode {
+	case "":
+		return unexpectedCredentialsIgnore, nil
+	case unexpectedCredentialsIgnore, unexpectedCredentialsWarn, unexpectedCredentialsCache:
+		return mode, nil
+	}
+
+	return "", fmt.Errorf("unsupported unexpected credentials mode %q, expected %s, %s or %s", mode, unexpectedCredentialsIgnore, unexpectedCredentialsWarn, unexpectedCredentialsCache)
+}
+
+// handleUnexpectedCredentials is called when the Portainer instance sends tunnel credentials
+// with a status that does not require a tunnel.
+func (service *PollService) handleUnexpectedCredentials(responseData *pollStatusResponse) {
+	switch service.unexpectedCredentials {
+	case unexpectedCredentialsWarn:
+		log.Printf("[WARN] [edge] [status: %s] [message: tunnel credentials received while the tunnel is not required, ignoring them]", responseData.Status)
+	case unexpectedCredentialsCache:
+		if service.credentialsCache != nil && service.credentialsCache.encoded == responseData.Credentials && time.Now().Before(service.credentialsCache.expiresAt) {
+			return
+		}
+
+		decrypted, err := service.decryptCredentials(responseData.Credentials)
+		if err != nil {
+			log.Printf("[WARN] [edge] [status: %s] [message: unable to decrypt the tunnel credentials received while the tunnel is not required] [error: %s]", responseData.Status, err)
+			return
+		}
+
+		log.Printf("[DEBUG] [edge] [status: %s] [ttl_seconds: %f] [message: caching tunnel credentials received while the tunnel is not required]", responseData.Status, credentialsCacheTTL.Seconds())
+
+		service.credentialsCache = &cachedCredentials{
+			encoded:   responseData.Credentials,
+			decrypted: decrypted,
+			expiresAt: time.Now().Add(credentialsCacheTTL),
+		}
+	}
+}
+
+// hasCachedCredentials returns true when credentials received with a previous status can be used to open the tunnel.
+func (service *PollService) hasCachedCredentials(now time.Time) bool {
+	return service.credentialsCache != nil && now.Before(service.credentialsCache.expiresAt)
+}
+
+// tunnelCredentials returns the decrypted tunnel credentials, the cached credentials are used when
+// they match the encoded credentials (or when no credentials were sent) and did not expire yet.
+func (service *PollService) tunnelCredentials(encodedCredentials string) (string, error) {
+	cache := service.credentialsCache
+	service.credentialsCache = nil
+
+	if cache != nil && time.Now().Before(cache.expiresAt) && (encodedCredentials == "" || encodedCredentials == cache.encoded) {
+		log.Println("[DEBUG] [edge] [message: using cached tunnel credentials]")
+		return cache.decrypted, nil
+	}
+
+	return service.decryptCredentials(encodedCredentials)
+}
+
+func (service *PollService) decryptCredentials(encodedCredentials string) (string, error) {
+	decodedCredentials, err := base64.RawStdEncoding.DecodeString(encodedCredentials)
+	if err != nil {
+		return "", err
+	}
+
+	credentials, err := libcrypto.Decrypt(decodedCredentials, []byte(service.edgeID))
+	if err != nil {
+		return "", err
+	}
+
+	err = validateCredentials(string(credentials))
+	if err != nil {
+		return "", err
+	}
+
+	return string(credentials), nil
+}
+
+// validateCredentials ensures that the decrypted tunnel credentials are printable and in the user:password format.
+func validateCredentials(credentials string) error {
+	if len(credentials) == 0 || len(credentials) > maxCredentialsLength {
+		return errInvalidDecryptedCredentials
+	}
+
+	for _, c := range credentials {
+		if c < 0x21 || c > 0x7e {
+			return errInvalidDecryptedCredentials
+		}
+	}
+
+	parts := strings.SplitN(credentials, ":", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return errInvalidDecryptedCredentials
+	}
+
+	return nil
+}
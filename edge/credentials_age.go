@@ -0,0 +1,57 @@
+package edge
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"time"
+)
+
+const eventReasonCredentialsRotated = "CredentialsRotated"
+
+// TunnelCredentialsInfo describes the age and the rotations of the tunnel credentials sent by the Portainer
+// instance, the credentials themselves are never exposed.
+type TunnelCredentialsInfo struct {
+	ReceivedAt   time.Time
+	Age          time.Duration
+	Rotations    int
+	LastRotation time.Time
+}
+
+// trackCredentials records when the tunnel credentials sent by the Portainer instance were first received, so that
+// their age can be reported, and emits an event when they differ from the previously received credentials.
+// Only a hash of the credentials is kept to detect the rotations.
+func (service *PollService) trackCredentials(encodedCredentials string, now time.Time) {
+	if encodedCredentials == "" {
+		return
+	}
+
+	sum := sha256.Sum256([]byte(encodedCredentials))
+	hash := hex.EncodeToString(sum[:])
+
+	service.statusMu.Lock()
+	info := &service.status.TunnelCredentials
+	age := now.Sub(info.ReceivedAt)
+
+	if hash == service.credentialsHash {
+		service.statusMu.Unlock()
+		service.metrics.Gauge(metricTunnelCredentialsAge, age.Seconds())
+		return
+	}
+
+	rotated := service.credentialsHash != ""
+	if rotated {
+		info.Rotations++
+		info.LastRotation = now
+	}
+
+	service.credentialsHash = hash
+	info.ReceivedAt = now
+	service.statusMu.Unlock()
+
+	service.metrics.Gauge(metricTunnelCredentialsAge, 0)
+
+	if rotated {
+		service.emitEvent(eventReasonCredentialsRotated, eventSeverityNormal, fmt.Sprintf("tunnel credentials rotated by the Portainer instance, the previous credentials were used for %s", age.Round(time.Second)))
+	}
+}
@@ -0,0 +1,86 @@
+package edge
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+)
+
+const (
+	// deregistrationIgnore keeps polling when the Portainer instance rejects the Edge ID
+	deregistrationIgnore = "ignore"
+	// deregistrationStop stops polling when the Portainer instance rejects the Edge ID, the agent
+	// must be provisioned again
+	deregistrationStop = "stop"
+
+	deregisteredPauseReason = "deregistered, the Edge ID was rejected by the Portainer instance"
+	eventReasonDeregistered = "Deregistered"
+
+	// maxErrorBodySize is the maximum number of bytes of an error response read to detect a deregistration
+	maxErrorBodySize = 1024
+)
+
+func parseDeregistrationMode(value string) (string, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "", deregistrationIgnore:
+		return deregistrationIgnore, nil
+	case deregistrationStop:
+		return deregistrationStop, nil
+	}
+
+	return "", fmt.Errorf("invalid deregistration mode %q, expected %s or %s", value, deregistrationIgnore, deregistrationStop)
+}
+
+// readErrorBody returns the beginning of the body of an error response.
+func readErrorBody(resp *http.Response) string {
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxErrorBodySize))
+	if err != nil {
+		return ""
+	}
+
+	return string(body)
+}
+
+// isDeregistration returns true when the poll was rejected because the Edge ID is unknown or was revoked:
+// the Portainer instance answered with 401 or 403 and, when a match is configured, the body of the response
+// contains it.
+func (service *PollService) isDeregistration(err error) bool {
+	var statusErr *pollStatusError
+	if !errors.As(err, &statusErr) {
+		return false
+	}
+
+	if statusErr.StatusCode != http.StatusUnauthorized && statusErr.StatusCode != http.StatusForbidden {
+		return false
+	}
+
+	return service.deregistrationMatch == "" || strings.Contains(statusErr.Body, service.deregistrationMatch)
+}
+
+// handleDeregistration stops polling once the Portainer instance rejected the Edge ID, retrying cannot succeed
+// until the agent is provisioned again.
+func (service *PollService) handleDeregistration(err error) {
+	if service.deregistrationMode != deregistrationStop || !service.isDeregistration(err) {
+		return
+	}
+
+	service.statusMu.Lock()
+	service.status.Deregistered = true
+	service.statusMu.Unlock()
+
+	log.Printf("[ERROR] [edge] [message: the Edge ID was rejected by the Portainer instance, polling is stopped until the agent is provisioned again] [error: %s]", err)
+	service.emitEvent(eventReasonDeregistered, eventSeverityWarning, "the Edge ID was rejected by the Portainer instance, the agent must be provisioned again")
+
+	// The stop signal is consumed by the poll loop that is executing this poll
+	go service.stop(deregisteredPauseReason)
+}
+
+func (service *PollService) isDeregistered() bool {
+	service.statusMu.Lock()
+	defer service.statusMu.Unlock()
+
+	return service.status.Deregistered
+}
@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/portainer/agent"
+	"github.com/portainer/agent/edge/client"
 	"github.com/portainer/agent/edge/scheduler"
 	"github.com/portainer/agent/edge/stack"
 )
@@ -19,6 +20,9 @@ type (
 		agentOptions      *agent.Options
 		clusterService    agent.ClusterService
 		dockerInfoService agent.DockerInfoService
+		containerCounter  agent.ContainerCountProvider
+		snapshotProducer  agent.SnapshotProducer
+		eventRecorder     agent.EventRecorder
 		key               *edgeKey
 		logsManager       *scheduler.LogsManager
 		pollService       *PollService
@@ -32,6 +36,12 @@ type (
 		ClusterService    agent.ClusterService
 		DockerInfoService agent.DockerInfoService
 		ContainerPlatform agent.ContainerPlatform
+		// ContainerCounter is optional, it is used to report the number of managed containers
+		ContainerCounter agent.ContainerCountProvider
+		// SnapshotProducer is optional, it is used to push platform snapshots in the async Edge mode
+		SnapshotProducer agent.SnapshotProducer
+		// EventRecorder is optional, it is used to report the events of the agent on the container platform
+		EventRecorder agent.EventRecorder
 	}
 )
 
@@ -43,6 +53,9 @@ func NewManager(parameters *ManagerParameters) *Manager {
 		agentOptions:      parameters.Options,
 		advertiseAddr:     parameters.AdvertiseAddr,
 		containerPlatform: parameters.ContainerPlatform,
+		containerCounter:  parameters.ContainerCounter,
+		snapshotProducer:  parameters.SnapshotProducer,
+		eventRecorder:     parameters.EventRecorder,
 	}
 }
 
@@ -57,7 +70,7 @@ func (manager *Manager) Start() error {
 	pollServiceConfig := &pollServiceConfig{
 		APIServerAddr:           apiServerAddr,
 		EdgeID:                  manager.agentOptions.EdgeID,
-		PollFrequency:           agent.DefaultEdgePollInterval,
+		PollFrequency:           manager.agentOptions.EdgePollFrequency,
 		InactivityTimeout:       manager.agentOptions.EdgeInactivityTimeout,
 		InsecurePoll:            manager.agentOptions.EdgeInsecurePoll,
 		TunnelCapability:        manager.agentOptions.EdgeTunnel,
@@ -66,18 +79,140 @@ func (manager *Manager) Start() error {
 		TunnelServerAddr:        manager.key.TunnelServerAddr,
 		TunnelServerFingerprint: manager.key.TunnelServerFingerprint,
 		ContainerPlatform:       manager.containerPlatform,
+		ClientRefreshInterval:   manager.agentOptions.EdgeClientRefresh,
+		HeartbeatInterval:       manager.agentOptions.EdgeHeartbeatInterval,
+		StatsdAddr:              manager.agentOptions.EdgeStatsdAddr,
+		FastRetryStatusCodes:    manager.agentOptions.EdgeFastRetryCodes,
+		TunnelReadinessCheck:    manager.agentOptions.EdgeTunnelReadiness,
+		SlowPollThreshold:       manager.agentOptions.EdgeSlowPollThreshold,
+		LogsMinDiskFreeMB:       manager.agentOptions.EdgeLogsMinDiskFree,
+		LogsMinMemoryFreeMB:     manager.agentOptions.EdgeLogsMinMemoryFree,
+		TunnelServerPathPrefix:  manager.agentOptions.EdgeTunnelPathPrefix,
+		TunnelServerHeaders:     manager.agentOptions.EdgeTunnelHeaders,
+		PollEncoding:            manager.agentOptions.EdgePollEncoding,
+		ClientInitRetries:       manager.agentOptions.EdgeClientInitRetries,
+		PollReplicas:            manager.agentOptions.EdgePollReplicas,
+		ReplicaSelection:        manager.agentOptions.EdgeReplicaSelection,
+		TunnelStateSource:       manager.agentOptions.EdgeTunnelStateSource,
+		TunnelIdleProbe:         manager.agentOptions.EdgeTunnelIdleProbe,
+		TunnelPortRange:         manager.agentOptions.EdgeTunnelPortRange,
+		StatusReport:            manager.agentOptions.EdgeStatusReport,
+		FullReportInterval:      manager.agentOptions.EdgeReportResync,
+		ErrorLogLevels:          manager.agentOptions.EdgeErrorLogLevels,
+		UnexpectedCredentials:   manager.agentOptions.EdgeUnexpectedCreds,
+		TunnelMaxStagger:        manager.agentOptions.EdgeTunnelMaxStagger,
+		MaxConnLifetime:         manager.agentOptions.EdgeMaxConnLifetime,
+		ScheduleValidation:      manager.agentOptions.EdgeScheduleCheck,
+		RedirectPolicy:          manager.agentOptions.EdgeRedirectPolicy,
+		MaxRedirects:            manager.agentOptions.EdgeMaxRedirects,
+		FingerprintMode:         manager.agentOptions.EdgeFingerprintMode,
+		TrustedFingerprints:     manager.agentOptions.EdgeFingerprintList,
+		ReconcileDeadline:       manager.agentOptions.EdgeReconcileDeadline,
+		LocalLivenessFailures:   manager.agentOptions.EdgeLivenessFailures,
+		PollHistoryFile:         manager.agentOptions.EdgePollHistoryFile,
+		PollHistoryMaxSizeMB:    manager.agentOptions.EdgePollHistorySize,
+		PollHistoryMaxFiles:     manager.agentOptions.EdgePollHistoryFiles,
+		InsecureFallback:        manager.agentOptions.EdgeInsecureFallback,
+		TriggerBackoffMode:      manager.agentOptions.EdgeTriggerBackoff,
+		SteadyStateAfter:        manager.agentOptions.EdgeSteadyStateAfter,
+		SteadyStateInterval:     manager.agentOptions.EdgeSteadyStateEvery,
+		DecodeRetry:             manager.agentOptions.EdgeDecodeRetry,
+		ReportActions:           manager.agentOptions.EdgeReportActions,
+		PollOverlap:             manager.agentOptions.EdgePollOverlap,
+		NetworkCheck:            manager.agentOptions.EdgeNetworkCheck,
+		TunnelServerCheck:       manager.agentOptions.EdgeTunnelServerCheck,
+		DeregistrationMode:      manager.agentOptions.EdgeDeregistration,
+		DeregistrationMatch:     manager.agentOptions.EdgeDeregisterMatch,
+		SignReports:             manager.agentOptions.EdgeSignReports,
+		OpenTunnelFactor:        manager.agentOptions.EdgeOpenTunnelFactor,
+		TunnelSessionHistory:    manager.agentOptions.EdgeTunnelSessions,
+		PollWarmup:              manager.agentOptions.EdgePollWarmup,
+		LogsCondition:           manager.agentOptions.EdgeLogsCondition,
+		LogsSlowThreshold:       manager.agentOptions.EdgeLogsSlowAfter,
+		PollTransport:           manager.agentOptions.EdgePollTransport,
+		ResponseMaxAge:          manager.agentOptions.EdgeResponseMaxAge,
+		ResponseClockSkew:       manager.agentOptions.EdgeClockSkew,
+		TunnelOutageTimeout:     manager.agentOptions.EdgeTunnelOutage,
+		DataPath:                manager.agentOptions.DataPath,
+		ResetRestartCount:       manager.agentOptions.EdgeResetRestarts,
+		SystemTimeCheck:         manager.agentOptions.EdgeSystemTimeCheck,
+		TLSSessionCacheSize:     manager.agentOptions.EdgeTLSSessionCache,
+		TLSRenegotiation:        manager.agentOptions.EdgeTLSRenegotiation,
+		OperationMode:           manager.agentOptions.OperationMode,
+		PollJitter:              manager.agentOptions.EdgePollJitter,
+		PollBackoffMax:          manager.agentOptions.EdgePollBackoffMax,
+		AsyncInterval:           manager.agentOptions.EdgeAsyncInterval,
+		PollCompression:         manager.agentOptions.EdgePollCompression,
+		PollCAFiles:             manager.agentOptions.EdgePollCAFiles,
+		ClientCert:              manager.agentOptions.EdgeClientCert,
+		ClientKey:               manager.agentOptions.EdgeClientKey,
+		ClientCertPassword:      manager.agentOptions.EdgeClientCertPass,
+		ClientCertReload:        manager.agentOptions.EdgeClientCertReload,
+		ProxyURL:                manager.agentOptions.EdgeProxyURL,
+		SOCKS5Addr:              manager.agentOptions.EdgeSOCKS5Addr,
+		SOCKS5Username:          manager.agentOptions.EdgeSOCKS5Username,
+		SOCKS5Password:          manager.agentOptions.EdgeSOCKS5Password,
+		FailbackInterval:        manager.agentOptions.EdgeFailbackInterval,
+		ResponseKeyFile:         manager.agentOptions.EdgeResponseKeyFile,
+		ResponseSigningKey:      manager.key.ResponseSigningKey,
 	}
 
-	log.Printf("[DEBUG] [edge] [api_addr: %s] [edge_id: %s] [poll_frequency: %s] [inactivity_timeout: %s] [insecure_poll: %t] [tunnel_capability: %t]", pollServiceConfig.APIServerAddr, pollServiceConfig.EdgeID, pollServiceConfig.PollFrequency, pollServiceConfig.InactivityTimeout, pollServiceConfig.InsecurePoll, manager.agentOptions.EdgeTunnel)
+	if manager.agentOptions.EdgeContainerCount {
+		pollServiceConfig.ContainerCounter = manager.containerCounter
+	}
+
+	if manager.agentOptions.EdgeAsyncInterval > 0 {
+		pollServiceConfig.SnapshotProducer = manager.snapshotProducer
+	}
+
+	if manager.agentOptions.EdgeKubernetesEvents {
+		if manager.containerPlatform != agent.PlatformKubernetes || manager.eventRecorder == nil {
+			log.Println("[WARN] [edge] [message: Kubernetes events are not available, the events are only reported in the logs]")
+		} else {
+			pollServiceConfig.EventRecorder = manager.eventRecorder
+		}
+	}
+
+	err := applyConfigProfile(pollServiceConfig, manager.agentOptions.EdgeConfigProfile, manager.agentOptions.EdgeExplicitOptions)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("[DEBUG] [edge] [api_addr: %s] [edge_id: %s] [poll_frequency: %s] [inactivity_timeout: %s] [insecure_poll: %t] [tunnel_capability: %t] [heartbeat_interval: %s] [config_profile: %s]", pollServiceConfig.APIServerAddr, pollServiceConfig.EdgeID, pollServiceConfig.PollFrequency, pollServiceConfig.InactivityTimeout, pollServiceConfig.InsecurePoll, manager.agentOptions.EdgeTunnel, pollServiceConfig.HeartbeatInterval, manager.agentOptions.EdgeConfigProfile)
 
-	stackManager, err := stack.NewStackManager(manager.key.PortainerInstanceURL, manager.key.EndpointID, manager.agentOptions.EdgeID, manager.agentOptions.AssetsPath, pollServiceConfig.InsecurePoll)
+	stackManager, err := stack.NewStackManager(primaryPortainerURL(manager.key.PortainerInstanceURL), manager.key.EndpointID, manager.agentOptions.EdgeID, manager.agentOptions.AssetsPath, pollServiceConfig.InsecurePoll)
 	if err != nil {
 		return err
 	}
 	manager.stackManager = stackManager
 
-	manager.logsManager = scheduler.NewLogsManager(manager.key.PortainerInstanceURL, manager.key.EndpointID, manager.agentOptions.EdgeID, pollServiceConfig.InsecurePoll)
-	manager.logsManager.Start()
+	err = manager.stackManager.SetReconciliationStrategy(manager.agentOptions.EdgeStackStrategy)
+	if err != nil {
+		return err
+	}
+
+	err = manager.stackManager.SetMaxConcurrency(manager.agentOptions.EdgeStackConcurrency)
+	if err != nil {
+		return err
+	}
+
+	err = manager.stackManager.SetUpdateBatchSize(manager.agentOptions.EdgeStackBatchSize)
+	if err != nil {
+		return err
+	}
+
+	manager.logsManager = scheduler.NewLogsManager(primaryPortainerURL(manager.key.PortainerInstanceURL), manager.key.EndpointID, manager.agentOptions.EdgeID, pollServiceConfig.InsecurePoll)
+
+	if manager.agentOptions.EdgeReportQueueSize > 0 {
+		reportQueue, err := client.NewReportQueue(manager.agentOptions.DataPath, int64(manager.agentOptions.EdgeReportQueueSize)*1024*1024)
+		if err != nil {
+			return err
+		}
+
+		manager.stackManager.SetReportQueue(reportQueue)
+		manager.logsManager.SetReportQueue(reportQueue)
+		pollServiceConfig.ReportQueue = reportQueue
+	}
 
 	pollService, err := newPollService(manager.stackManager, manager.logsManager, pollServiceConfig)
 	if err != nil {
@@ -85,6 +220,8 @@ func (manager *Manager) Start() error {
 	}
 	manager.pollService = pollService
 
+	manager.logsManager.Start()
+
 	return manager.startEdgeBackgroundProcess()
 }
 
@@ -93,6 +230,34 @@ func (manager *Manager) ResetActivityTimer() {
 	manager.pollService.resetActivityTimer()
 }
 
+// Status returns a snapshot of the state of the poll service or nil if the manager is not started
+func (manager *Manager) Status() *PollServiceStatus {
+	if manager.pollService == nil {
+		return nil
+	}
+
+	status := manager.pollService.Status()
+	return &status
+}
+
+// ExportSchedules returns a JSON snapshot of the schedules currently applied by the agent
+func (manager *Manager) ExportSchedules() ([]byte, error) {
+	if manager.pollService == nil {
+		return nil, errors.New("unable to export schedules, Edge manager is not started")
+	}
+
+	return manager.pollService.ExportSchedules()
+}
+
+// Shutdown flushes the state of the poll service before the agent exits
+func (manager *Manager) Shutdown() {
+	if manager.pollService == nil {
+		return
+	}
+
+	manager.pollService.Shutdown()
+}
+
 func (manager *Manager) startEdgeBackgroundProcessOnDocker(runtimeCheckFrequency time.Duration) error {
 	err := manager.checkDockerRuntimeConfig()
 	if err != nil {
@@ -180,7 +345,7 @@ func (manager *Manager) checkDockerRuntimeConfig() error {
 		return manager.stackManager.Start()
 	}
 
-	manager.pollService.stop()
+	manager.pollService.stop("agent is not running on the Swarm leader node")
 
 	return manager.stackManager.Stop()
 }
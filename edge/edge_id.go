@@ -0,0 +1,50 @@
+package edge
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"strings"
+)
+
+const (
+	edgeIDMinLength = 8
+	edgeIDMaxLength = 256
+)
+
+var edgeIDPattern = regexp.MustCompile(`^[a-zA-Z0-9._-]+$`)
+
+// LoadEdgeID reads the Edge identifier from a file, typically written by a provisioning process,
+// and validates its format. Leading and trailing whitespaces are ignored.
+func LoadEdgeID(path string) (string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("unable to read the Edge identifier file %s: %w", path, err)
+	}
+
+	edgeID := strings.TrimSpace(string(data))
+
+	err = validateEdgeID(edgeID)
+	if err != nil {
+		return "", fmt.Errorf("invalid Edge identifier in %s: %w", path, err)
+	}
+
+	return edgeID, nil
+}
+
+func validateEdgeID(edgeID string) error {
+	if edgeID == "" {
+		return errors.New("the Edge identifier is empty")
+	}
+
+	if len(edgeID) < edgeIDMinLength || len(edgeID) > edgeIDMaxLength {
+		return fmt.Errorf("the Edge identifier must be between %d and %d characters long", edgeIDMinLength, edgeIDMaxLength)
+	}
+
+	if !edgeIDPattern.MatchString(edgeID) {
+		return errors.New("the Edge identifier can only contain letters, digits, dots, underscores and dashes")
+	}
+
+	return nil
+}
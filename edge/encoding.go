@@ -0,0 +1,56 @@
+package edge
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+const (
+	pollEncodingJSON    = "json"
+	pollEncodingMsgpack = "msgpack"
+
+	contentTypeJSON    = "application/json"
+	contentTypeMsgpack = "application/x-msgpack"
+)
+
+// parsePollEncoding validates the encoding requested for the poll responses.
+func parsePollEncoding(encoding string) (string, error) {
+	switch encoding {
+	case "", pollEncodingJSON:
+		return pollEncodingJSON, nil
+	case pollEncodingMsgpack:
+		return pollEncodingMsgpack, nil
+	}
+
+	return "", fmt.Errorf("unsupported poll encoding %q, expected %s or %s", encoding, pollEncodingJSON, pollEncodingMsgpack)
+}
+
+// acceptHeader returns the value of the Accept header sent with the poll requests.
+// JSON is always accepted so that Portainer instances that do not support the binary encoding
+// can still answer the request.
+func acceptHeader(encoding string) string {
+	if encoding == pollEncodingMsgpack {
+		return contentTypeMsgpack + ", " + contentTypeJSON + ";q=0.9"
+	}
+
+	return contentTypeJSON
+}
+
+// decodePollResponse decodes the body of a poll response based on its content type and returns
+// the encoding that was used. Responses without a supported binary content type are decoded as JSON.
+func decodePollResponse(resp *http.Response, responseData *pollStatusResponse) (string, error) {
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+
+	if mediaType == contentTypeMsgpack {
+		decoder := msgpack.NewDecoder(resp.Body)
+		decoder.SetCustomStructTag("json")
+
+		return pollEncodingMsgpack, decoder.Decode(responseData)
+	}
+
+	return pollEncodingJSON, json.NewDecoder(resp.Body).Decode(responseData)
+}
@@ -0,0 +1,171 @@
+package edge
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	pollErrorClassTimeout     = "timeout"
+	pollErrorClassTLS         = "tls"
+	pollErrorClassClientError = "4xx"
+	pollErrorClassServerError = "5xx"
+	pollErrorClassDecode      = "decode"
+	pollErrorClassOther       = "other"
+)
+
+// pollStatusError is returned when the Portainer instance answers a poll request with an unexpected status code.
+type pollStatusError struct {
+	StatusCode int
+	// RetryAfter is the delay requested by the Portainer instance before the next poll, if any
+	RetryAfter time.Duration
+	// Body is the beginning of the body of the response
+	Body string
+}
+
+func (err *pollStatusError) Error() string {
+	return fmt.Sprintf("short poll request failed with status code %d", err.StatusCode)
+}
+
+// pollDecodeError is returned when the response of the Portainer instance to a poll request cannot be decoded.
+type pollDecodeError struct {
+	err error
+}
+
+func (err *pollDecodeError) Error() string {
+	return fmt.Sprintf("unable to decode short poll response: %s", err.err)
+}
+
+func (err *pollDecodeError) Unwrap() error {
+	return err.err
+}
+
+// isTransient returns true when the failure is caused by a server side error that is expected to
+// resolve itself without any intervention.
+func (err *pollStatusError) isTransient() bool {
+	return err.StatusCode >= http.StatusInternalServerError
+}
+
+// shouldFastRetry returns true when the poll error is a transient server error that is associated to
+// one of the status codes configured to trigger a fast retry instead of waiting for the next poll interval.
+func shouldFastRetry(err error, fastRetryStatusCodes map[int]bool) bool {
+	var statusErr *pollStatusError
+	if !errors.As(err, &statusErr) || !statusErr.isTransient() {
+		return false
+	}
+
+	return fastRetryStatusCodes[statusErr.StatusCode]
+}
+
+// retryAfterDelay returns the delay requested by the Portainer instance when the poll failed, if any.
+func retryAfterDelay(err error) time.Duration {
+	var statusErr *pollStatusError
+	if !errors.As(err, &statusErr) {
+		return 0
+	}
+
+	return statusErr.RetryAfter
+}
+
+// parseStatusCodes parses a comma separated list of HTTP server error (5xx) status codes, the 5xx entry
+// stands for every server error status code.
+func parseStatusCodes(value string) (map[int]bool, error) {
+	statusCodes := map[int]bool{}
+
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		if strings.EqualFold(entry, "5xx") {
+			for statusCode := http.StatusInternalServerError; statusCode <= 599; statusCode++ {
+				statusCodes[statusCode] = true
+			}
+			continue
+		}
+
+		statusCode, err := strconv.Atoi(entry)
+		if err != nil || statusCode < http.StatusInternalServerError || statusCode > 599 {
+			return nil, fmt.Errorf("invalid HTTP status code %q, expected a 5xx status code", entry)
+		}
+
+		statusCodes[statusCode] = true
+	}
+
+	return statusCodes, nil
+}
+
+// classifyPollError returns the class of a poll error, used to select the level at which the error is logged.
+func classifyPollError(err error) string {
+	var statusErr *pollStatusError
+	if errors.As(err, &statusErr) {
+		if statusErr.StatusCode >= http.StatusInternalServerError {
+			return pollErrorClassServerError
+		}
+
+		return pollErrorClassClientError
+	}
+
+	var decodeErr *pollDecodeError
+	if errors.As(err, &decodeErr) {
+		return pollErrorClassDecode
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return pollErrorClassTimeout
+	}
+
+	var unknownAuthorityErr x509.UnknownAuthorityError
+	var certificateInvalidErr x509.CertificateInvalidError
+	var hostnameErr x509.HostnameError
+	var recordHeaderErr tls.RecordHeaderError
+	if errors.As(err, &unknownAuthorityErr) || errors.As(err, &certificateInvalidErr) || errors.As(err, &hostnameErr) || errors.As(err, &recordHeaderErr) {
+		return pollErrorClassTLS
+	}
+
+	return pollErrorClassOther
+}
+
+// parseErrorLogLevels parses a comma separated list of error class to log level mappings in the class=LEVEL format.
+func parseErrorLogLevels(value string) (map[string]string, error) {
+	levels := map[string]string{}
+
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid error log level %q, expected class=LEVEL", entry)
+		}
+
+		class := strings.ToLower(strings.TrimSpace(parts[0]))
+		switch class {
+		case pollErrorClassTimeout, pollErrorClassTLS, pollErrorClassClientError, pollErrorClassServerError, pollErrorClassDecode, pollErrorClassOther:
+		default:
+			return nil, fmt.Errorf("invalid error class %q", class)
+		}
+
+		level := strings.ToUpper(strings.TrimSpace(parts[1]))
+		switch level {
+		case "DEBUG", "INFO", "WARN", "ERROR":
+		default:
+			return nil, fmt.Errorf("invalid log level %q for error class %s", level, class)
+		}
+
+		levels[class] = level
+	}
+
+	return levels, nil
+}
@@ -0,0 +1,92 @@
+package edge
+
+import (
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/portainer/agent"
+)
+
+func TestShouldFastRetry(t *testing.T) {
+	fastRetryStatusCodes := map[int]bool{502: true, 503: true}
+
+	tests := []struct {
+		err      error
+		codes    map[int]bool
+		expected bool
+	}{
+		{err: &pollStatusError{StatusCode: 503}, codes: fastRetryStatusCodes, expected: true},
+		{err: &pollStatusError{StatusCode: 502}, codes: fastRetryStatusCodes, expected: true},
+		{err: &pollStatusError{StatusCode: 500}, codes: fastRetryStatusCodes, expected: false},
+		{err: &pollStatusError{StatusCode: 504}, codes: fastRetryStatusCodes, expected: false},
+		{err: &pollStatusError{StatusCode: 404}, codes: map[int]bool{404: true}, expected: false},
+		{err: &pollStatusError{StatusCode: 403}, codes: fastRetryStatusCodes, expected: false},
+		{err: &pollStatusError{StatusCode: 503}, codes: map[int]bool{}, expected: false},
+		{err: fmt.Errorf("wrapped: %w", &pollStatusError{StatusCode: 503}), codes: fastRetryStatusCodes, expected: true},
+		{err: errors.New("connection refused"), codes: fastRetryStatusCodes, expected: false},
+	}
+
+	for _, test := range tests {
+		result := shouldFastRetry(test.err, test.codes)
+		if result != test.expected {
+			t.Errorf("shouldFastRetry(%q, %v) = %t, expected %t", test.err, test.codes, result, test.expected)
+		}
+	}
+}
+
+func TestClassifyPollError(t *testing.T) {
+	tests := []struct {
+		err      error
+		expected string
+	}{
+		{err: &pollStatusError{StatusCode: 503}, expected: pollErrorClassServerError},
+		{err: &pollStatusError{StatusCode: 404}, expected: pollErrorClassClientError},
+		{err: &pollDecodeError{err: errors.New("unexpected EOF")}, expected: pollErrorClassDecode},
+		{err: fmt.Errorf("wrapped: %w", x509.UnknownAuthorityError{}), expected: pollErrorClassTLS},
+		{err: &net.OpError{Op: "dial", Err: timeoutError{}}, expected: pollErrorClassTimeout},
+		{err: errors.New("connection refused"), expected: pollErrorClassOther},
+	}
+
+	for _, test := range tests {
+		result := classifyPollError(test.err)
+		if result != test.expected {
+			t.Errorf("classifyPollError(%q) = %s, expected %s", test.err, result, test.expected)
+		}
+	}
+}
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func TestParseStatusCodes(t *testing.T) {
+	codes, err := parseStatusCodes("502, 503,,504")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if len(codes) != 3 || !codes[502] || !codes[503] || !codes[504] {
+		t.Errorf("unexpected status codes: %v", codes)
+	}
+
+	codes, err = parseStatusCodes(agent.DefaultEdgeFastRetryCodes)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if len(codes) != 100 || !codes[500] || !codes[503] || !codes[599] {
+		t.Errorf("expected every server error status code by default, got %d status codes", len(codes))
+	}
+
+	for _, value := range []string{"503,abc", "404", "503,499", "600", "200", "4xx"} {
+		_, err = parseStatusCodes(value)
+		if err == nil {
+			t.Errorf("expected an error for the status codes %q", value)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package edge
+
+import (
+	"log"
+	"net/http"
+)
+
+// setPollETag records the entity tag of the last poll response that was fully applied, it is sent in the
+// If-None-Match header of the next poll requests so that an unchanged status is not downloaded nor reconciled again.
+func (service *PollService) setPollETag(etag string) {
+	service.statusMu.Lock()
+	service.pollETag = etag
+	service.statusMu.Unlock()
+}
+
+func (service *PollService) getPollETag() string {
+	service.statusMu.Lock()
+	defer service.statusMu.Unlock()
+
+	return service.pollETag
+}
+
+// invalidatePollETag forces the next poll response to be downloaded and reconciled, it must be called whenever
+// the local state diverges from the state requested by the last poll response.
+func (service *PollService) invalidatePollETag() {
+	service.setPollETag("")
+}
+
+// deferReconciliation records that part of the current poll response was not applied and will be applied during
+// a later poll, the response is then not cached so that the next poll response is reconciled again.
+func (service *PollService) deferReconciliation(reason string) {
+	service.reconcileDeferred = true
+	log.Printf("[DEBUG] [edge] [reason: %s] [message: reconciliation deferred, the poll response will be reconciled again during the next poll]", reason)
+}
+
+func setIfNoneMatchHeader(req *http.Request, etag string) {
+	if etag == "" {
+		return
+	}
+
+	req.Header.Set("If-None-Match", etag)
+	log.Printf("[DEBUG] [edge] [etag: %s] [message: requesting the status only if it changed]", etag)
+}
@@ -0,0 +1,74 @@
+package edge
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/portainer/agent/edge/scheduler"
+	"github.com/portainer/agent/edge/stack"
+)
+
+// newTestPollService returns a poll service polling the specified server, its loops are stopped so that the polls
+// are driven by the test.
+func newTestPollService(t *testing.T, serverURL string, opts ...Option) *PollService {
+	edgeStackManager, err := stack.NewStackManager(serverURL, "1", "edge-id", t.TempDir(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	opts = append([]Option{
+		WithPortainerInstance(serverURL, "1"),
+		WithEdgeID("edge-id"),
+		WithManagers(edgeStackManager, scheduler.NewLogsManager(serverURL, "1", "edge-id", false)),
+	}, opts...)
+
+	service, err := NewPollService(opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	service.Close()
+
+	return service
+}
+
+func TestStaggeredTunnelCreationIsNotSkippedByCachedResponse(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("ETag", `"v1"`)
+		w.Write([]byte(`{"status":"REQUIRED","port":20000,"checkin":5,"credentials":"credentials"}`))
+	}))
+	defer server.Close()
+
+	service := newTestPollService(t, server.URL, WithTunnelMaxStagger(50*time.Millisecond))
+
+	tunnelClient := &fakeTunnelClient{}
+	service.tunnelClient = tunnelClient
+	service.credentialsCache = &cachedCredentials{encoded: "credentials", decrypted: "user:password", expiresAt: time.Now().Add(time.Minute)}
+
+	poll := func() {
+		err := service.poll()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The first poll staggers the creation of the tunnel
+	poll()
+	if tunnelClient.created != 0 {
+		t.Fatalf("expected the tunnel creation to be staggered, got %d creations", tunnelClient.created)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+
+	poll()
+	if tunnelClient.created != 1 {
+		t.Errorf("expected the tunnel to be created once the stagger delay elapsed, got %d creations", tunnelClient.created)
+	}
+}
@@ -0,0 +1,65 @@
+package edge
+
+import (
+	"log"
+	"time"
+
+	"github.com/portainer/agent"
+)
+
+const (
+	eventSeverityNormal  = "Normal"
+	eventSeverityWarning = "Warning"
+)
+
+// agentEvent is a significant transition of the state of the agent, reported in addition to the logs.
+type agentEvent struct {
+	Reason   string
+	Message  string
+	Severity string
+	Time     time.Time
+}
+
+// eventSink is used to report the events of the poll service.
+type eventSink interface {
+	Emit(event agentEvent)
+}
+
+// logEventSink reports the events in the agent logs.
+type logEventSink struct{}
+
+func (logEventSink) Emit(event agentEvent) {
+	level := "INFO"
+	if event.Severity == eventSeverityWarning {
+		level = "WARN"
+	}
+
+	log.Printf("[%s] [edge,events] [reason: %s] [message: %s]", level, event.Reason, event.Message)
+}
+
+func (service *PollService) emitEvent(reason, severity, message string) {
+	service.events.Emit(agentEvent{
+		Reason:   reason,
+		Message:  message,
+		Severity: severity,
+		Time:     time.Now(),
+	})
+}
+
+// kubernetesEventSink reports the events in the agent logs and as Kubernetes events on the pod of the agent.
+// The Kubernetes events are recorded asynchronously so that the poll service is not blocked by the Kubernetes API.
+type kubernetesEventSink struct {
+	recorder agent.EventRecorder
+	logs     logEventSink
+}
+
+func (sink kubernetesEventSink) Emit(event agentEvent) {
+	sink.logs.Emit(event)
+
+	go func() {
+		err := sink.recorder.RecordEvent(event.Reason, event.Severity, event.Message, event.Time)
+		if err != nil {
+			log.Printf("[WARN] [edge,events] [reason: %s] [message: unable to record Kubernetes event] [error: %s]", event.Reason, err)
+		}
+	}()
+}
@@ -0,0 +1,77 @@
+package edge
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/portainer/agent/logutils"
+)
+
+const (
+	featureFlagVerboseLogging       = "verbose-logging"
+	featureFlagTunnelReadinessCheck = "tunnel-readiness-check"
+)
+
+// featureFlagHandler toggles an agent behavior at runtime.
+type featureFlagHandler func(service *PollService, enabled bool)
+
+// featureFlagHandlers contains the behaviors that can be toggled by the Portainer instance, any other flag is ignored.
+var featureFlagHandlers = map[string]featureFlagHandler{
+	featureFlagVerboseLogging: func(service *PollService, enabled bool) {
+		logutils.SetVerbose(enabled)
+	},
+	featureFlagTunnelReadinessCheck: func(service *PollService, enabled bool) {
+		service.tunnelReadinessCheck = enabled
+	},
+}
+
+// defaultFeatureFlags returns the values used to restore the behaviors once the Portainer instance stops sending a flag.
+func defaultFeatureFlags(config *pollServiceConfig) map[string]bool {
+	return map[string]bool{
+		featureFlagVerboseLogging:       false,
+		featureFlagTunnelReadinessCheck: config.TunnelReadinessCheck,
+	}
+}
+
+// applyFeatureFlags applies the recognized feature flags sent by the Portainer instance and reports the flags
+// that were honored in the status. Behaviors associated to flags that are no longer sent are restored to their default.
+func (service *PollService) applyFeatureFlags(flags map[string]bool) {
+	honored := map[string]bool{}
+
+	for name, enabled := range flags {
+		handler, ok := featureFlagHandlers[name]
+		if !ok {
+			log.Printf("[DEBUG] [edge] [flag: %s] [message: ignoring unknown feature flag]", name)
+			service.recordUnhonoredRequest(unhonoredRequestFeatureFlag, fmt.Sprintf("unknown feature flag %s", name))
+			continue
+		}
+
+		previous, applied := service.featureFlags[name]
+		if !applied || previous != enabled {
+			log.Printf("[INFO] [edge] [flag: %s] [enabled: %t] [message: applying feature flag]", name, enabled)
+			handler(service, enabled)
+		}
+
+		honored[name] = enabled
+	}
+
+	for name := range service.featureFlags {
+		if _, ok := honored[name]; ok {
+			continue
+		}
+
+		log.Printf("[INFO] [edge] [flag: %s] [message: feature flag removed, restoring default behavior]", name)
+		featureFlagHandlers[name](service, service.featureFlagDefaults[name])
+	}
+
+	service.featureFlags = honored
+
+	statusFlags := make(map[string]bool, len(honored))
+	for name, enabled := range honored {
+		statusFlags[name] = enabled
+	}
+
+	service.statusMu.Lock()
+	service.status.FeatureFlags = statusFlags
+	service.statusMu.Unlock()
+}
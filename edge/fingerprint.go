@@ -0,0 +1,83 @@
+package edge
+
+import (
+	"fmt"
+	"log"
+	"strings"
+)
+
+const (
+	// fingerprintModeAdopt adopts the tunnel server fingerprint sent by the Portainer instance, only when the
+	// signature of the response was verified.
+	fingerprintModeAdopt = "adopt"
+	// fingerprintModeTrusted only adopts the tunnel server fingerprint sent by the Portainer instance
+	// when it is part of the list of trusted fingerprints.
+	fingerprintModeTrusted = "trusted"
+	// fingerprintModeDisabled ignores the tunnel server fingerprint sent by the Portainer instance, it is the default.
+	fingerprintModeDisabled = "disabled"
+)
+
+func parseFingerprintMode(mode string) (string, error) {
+	switch mode {
+	case "":
+		return fingerprintModeDisabled, nil
+	case fingerprintModeAdopt, fingerprintModeTrusted, fingerprintModeDisabled:
+		return mode, nil
+	}
+
+	return "", fmt.Errorf("unsupported tunnel server fingerprint mode %q, expected %s, %s or %s", mode, fingerprintModeAdopt, fingerprintModeTrusted, fingerprintModeDisabled)
+}
+
+// parseFingerprintList parses a comma separated list of tunnel server fingerprints.
+func parseFingerprintList(value string) map[string]bool {
+	fingerprints := map[string]bool{}
+
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry != "" {
+			fingerprints[entry] = true
+		}
+	}
+
+	return fingerprints
+}
+
+// updateTunnelServerFingerprint handles a tunnel server fingerprint sent by the Portainer instance, usually after
+// the rotation of the tunnel server key, according to the configured fingerprint mode. A fingerprint is never
+// adopted from a response received over a connection whose certificate was not verified, since a forged fingerprint
+// would allow to intercept the tunnel.
+func (service *PollService) updateTunnelServerFingerprint(responseData *pollStatusResponse) {
+	fingerprint := responseData.TunnelServerFingerprint
+	if fingerprint == "" || fingerprint == service.tunnelServerFingerprint {
+		return
+	}
+
+	if service.fingerprintMode != fingerprintModeDisabled && !responseData.tlsVerified {
+		log.Printf("[WARN] [edge] [fingerprint: %s] [message: ignoring the tunnel server fingerprint sent by the Portainer instance, the response was received over an unverified connection]", fingerprint)
+		service.recordUnhonoredRequest(unhonoredRequestFingerprint, "the response carrying the tunnel server fingerprint was not authenticated")
+		return
+	}
+
+	switch service.fingerprintMode {
+	case fingerprintModeDisabled:
+		log.Printf("[WARN] [edge] [fingerprint: %s] [message: ignoring the tunnel server fingerprint sent by the Portainer instance, automatic adoption is disabled]", fingerprint)
+		service.recordUnhonoredRequest(unhonoredRequestFingerprint, "automatic adoption of the tunnel server fingerprint is disabled")
+		return
+	case fingerprintModeTrusted:
+		if !service.trustedFingerprints[fingerprint] {
+			log.Printf("[WARN] [edge] [fingerprint: %s] [message: rejecting the tunnel server fingerprint sent by the Portainer instance, it is not part of the trusted fingerprints]", fingerprint)
+			service.recordUnhonoredRequest(unhonoredRequestFingerprint, "the tunnel server fingerprint is not trusted")
+			return
+		}
+	case fingerprintModeAdopt:
+		if !responseData.signatureVerified {
+			log.Printf("[WARN] [edge] [fingerprint: %s] [message: ignoring the tunnel server fingerprint sent by the Portainer instance, the response signature was not verified]", fingerprint)
+			service.recordUnhonoredRequest(unhonoredRequestFingerprint, "the response carrying the tunnel server fingerprint was not signed")
+			return
+		}
+	}
+
+	log.Printf("[WARN] [edge] [old_fingerprint: %s] [new_fingerprint: %s] [message: adopting the tunnel server fingerprint sent by the Portainer instance]", service.tunnelServerFingerprint, fingerprint)
+
+	service.tunnelServerFingerprint = fingerprint
+}
@@ -0,0 +1,41 @@
+package edge
+
+import "testing"
+
+func TestParseFingerprintModeDefaultsToDisabled(t *testing.T) {
+	mode, err := parseFingerprintMode("")
+	if err != nil || mode != fingerprintModeDisabled {
+		t.Errorf("expected the disabled mode by default, got %q (%v)", mode, err)
+	}
+}
+
+func TestUpdateTunnelServerFingerprint(t *testing.T) {
+	tests := []struct {
+		mode     string
+		response pollStatusResponse
+		adopted  bool
+	}{
+		{mode: fingerprintModeAdopt, response: pollStatusResponse{tlsVerified: true, signatureVerified: true}, adopted: true},
+		{mode: fingerprintModeAdopt, response: pollStatusResponse{tlsVerified: true}, adopted: false},
+		{mode: fingerprintModeAdopt, response: pollStatusResponse{signatureVerified: true}, adopted: false},
+		{mode: fingerprintModeTrusted, response: pollStatusResponse{tlsVerified: true}, adopted: true},
+		{mode: fingerprintModeTrusted, response: pollStatusResponse{}, adopted: false},
+		{mode: fingerprintModeDisabled, response: pollStatusResponse{tlsVerified: true, signatureVerified: true}, adopted: false},
+	}
+
+	for _, test := range tests {
+		service := &PollService{
+			tunnelServerFingerprint: "old",
+			fingerprintMode:         test.mode,
+			trustedFingerprints:     map[string]bool{"new": true},
+		}
+
+		test.response.TunnelServerFingerprint = "new"
+		service.updateTunnelServerFingerprint(&test.response)
+
+		adopted := service.tunnelServerFingerprint == "new"
+		if adopted != test.adopted {
+			t.Errorf("mode %s, tls verified %t, signature verified %t: adopted = %t, expected %t", test.mode, test.response.tlsVerified, test.response.signatureVerified, adopted, test.adopted)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package edge
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+)
+
+// errMissingResponseDate is returned when the freshness of a poll response is enforced and the response has no Date header.
+var errMissingResponseDate = errors.New("poll response has no valid Date header, unable to check its freshness")
+
+// staleResponseError is returned when the Date header of a poll response is outside of the freshness window.
+type staleResponseError struct {
+	Date time.Time
+	Age  time.Duration
+}
+
+func (err *staleResponseError) Error() string {
+	return fmt.Sprintf("stale poll response, the response is dated %s (age %s)", err.Date.Format(time.RFC1123), err.Age)
+}
+
+// validatePollResponse checks the freshness and the signature of a status sent by the Portainer instance, the
+// statuses received by every transport go through the same checks before being acted upon.
+func (service *PollService) validatePollResponse(resp *http.Response, now time.Time) error {
+	err := service.checkResponseFreshness(resp, now)
+	if err != nil {
+		log.Printf("[WARN] [edge] [date: %s] [message: rejecting the poll response] [error: %s]", resp.Header.Get("Date"), err)
+		return err
+	}
+
+	err = service.verifyResponseSignature(resp)
+	if err != nil {
+		log.Printf("[WARN] [edge] [message: rejecting the poll response] [error: %s]", err)
+		return err
+	}
+
+	return nil
+}
+
+// checkResponseFreshness rejects a poll response whose Date header is older than the maximum age or further in the
+// future than the clock skew tolerance. A response that could have been replayed or served from a stale cache must
+// not be acted upon.
+func (service *PollService) checkResponseFreshness(resp *http.Response, now time.Time) error {
+	if service.responseMaxAge <= 0 {
+		return nil
+	}
+
+	date, err := http.ParseTime(resp.Header.Get("Date"))
+	if err != nil {
+		return errMissingResponseDate
+	}
+
+	age := now.Sub(date)
+	if age > service.responseMaxAge+service.responseClockSkew || -age > service.responseClockSkew {
+		return &staleResponseError{Date: date, Age: age}
+	}
+
+	return nil
+}
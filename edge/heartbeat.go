@@ -0,0 +1,36 @@
+package edge
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/portainer/agent"
+)
+
+// heartbeat sends a lightweight request to the Portainer instance to report that the agent is alive.
+// Unlike poll, it does not retrieve nor reconcile the state associated to the Edge endpoint.
+func (service *PollService) heartbeat() error {
+	heartbeatURL := fmt.Sprintf("%s/api/endpoints/%s/edge/heartbeat", service.replicas.activeURL(), service.endpointID)
+	req, err := http.NewRequest(http.MethodPost, heartbeatURL, nil)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set(agent.HTTPEdgeIdentifierHeaderName, service.edgeID)
+	setInstanceHeaders(req)
+
+	resp, err := service.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
+		log.Printf("[DEBUG] [edge] [response_code: %d] [message: Heartbeat request failure]", resp.StatusCode)
+		return errors.New("heartbeat request failed")
+	}
+
+	return nil
+}
@@ -0,0 +1,172 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/portainer/agent/edge"
+	agentnet "github.com/portainer/agent/net"
+)
+
+const profilesFolder = "profiles"
+
+// DiagnosticsServer exposes the internal state of the Edge manager for troubleshooting purposes.
+type DiagnosticsServer struct {
+	httpServer       *http.Server
+	edgeManager      *edge.Manager
+	dataPath         string
+	profilingEnabled bool
+}
+
+// NewDiagnosticsServer returns a pointer to a new instance of DiagnosticsServer.
+// Profiles can only be captured when profilingEnabled is set, they are written under the data path.
+func NewDiagnosticsServer(edgeManager *edge.Manager, dataPath string, profilingEnabled bool) *DiagnosticsServer {
+	return &DiagnosticsServer{
+		edgeManager:      edgeManager,
+		dataPath:         dataPath,
+		profilingEnabled: profilingEnabled,
+	}
+}
+
+// Start starts a new web server by listening on the specified address. The server exposes control endpoints
+// without authentication, the address must therefore be a loopback address.
+func (server *DiagnosticsServer) Start(addr string) error {
+	loopback, err := agentnet.IsLoopbackAddr(addr)
+	if err != nil {
+		return err
+	}
+
+	if !loopback {
+		return fmt.Errorf("refusing to expose the Edge diagnostics server on %s, only loopback addresses and Unix sockets are allowed", addr)
+	}
+
+	server.httpServer = &http.Server{Addr: addr, Handler: server.router()}
+
+	err = server.httpServer.ListenAndServe()
+	if err != http.ErrServerClosed {
+		return err
+	}
+
+	return nil
+}
+
+// StartOnSocket starts a new web server by listening on a Unix socket created at the specified path
+// with the specified file permissions. A stale socket left at the path is removed.
+func (server *DiagnosticsServer) StartOnSocket(socketPath string, mode os.FileMode) error {
+	err := os.Remove(socketPath)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	listener, err := net.Listen("unix", socketPath)
+	if err != nil {
+		return err
+	}
+
+	err = os.Chmod(socketPath, mode)
+	if err != nil {
+		listener.Close()
+		return err
+	}
+
+	server.httpServer = &http.Server{Handler: server.router()}
+
+	err = server.httpServer.Serve(listener)
+	if err != http.ErrServerClosed {
+		return err
+	}
+
+	return nil
+}
+
+func (server *DiagnosticsServer) router() *mux.Router {
+	router := mux.NewRouter()
+	router.HandleFunc("/status", server.handleStatus()).Methods(http.MethodGet)
+	router.HandleFunc("/schedules", server.handleSchedules()).Methods(http.MethodGet)
+	router.HandleFunc("/livez", server.handleProbe(server.edgeManager.Liveness)).Methods(http.MethodGet)
+	router.HandleFunc("/readyz", server.handleProbe(server.edgeManager.Readiness)).Methods(http.MethodGet)
+	if server.profilingEnabled {
+		router.HandleFunc("/profile", server.handleProfile()).Methods(http.MethodPost)
+	}
+
+	return router
+}
+
+func (server *DiagnosticsServer) handleStatus() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		status := server.edgeManager.Status()
+		if status == nil {
+			http.Error(w, "Edge manager is not started", http.StatusServiceUnavailable)
+			return
+		}
+
+		writeJSON(w, status)
+	}
+}
+
+// handleProbe answers with a 200 status code when the probe succeeds and a 503 status code otherwise,
+// it is used to expose the liveness and the readiness of the agent separately.
+func (server *DiagnosticsServer) handleProbe(probe func() error) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		err := probe()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+
+		w.Write([]byte("ok"))
+	}
+}
+
+func (server *DiagnosticsServer) handleSchedules() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		snapshot, err := server.edgeManager.ExportSchedules()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(snapshot)
+	}
+}
+
+func (server *DiagnosticsServer) handleProfile() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		paths, err := edge.CaptureProfiles(filepath.Join(server.dataPath, profilesFolder))
+		if err != nil {
+			log.Printf("[ERROR] [http,diagnostics] [message: Unable to capture profiles] [error: %s]", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		log.Printf("[INFO] [http,diagnostics] [files: %v] [message: Profiles captured]", paths)
+		writeJSON(w, paths)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+
+	err := json.NewEncoder(w).Encode(data)
+	if err != nil {
+		log.Printf("[ERROR] [http,diagnostics] [message: Unable to write response] [error: %s]", err)
+	}
+}
+
+// Shutdown is used to shutdown the server.
+func (server *DiagnosticsServer) Shutdown() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	return server.httpServer.Shutdown(ctx)
+}
@@ -0,0 +1,14 @@
+package http
+
+import "testing"
+
+func TestDiagnosticsServerRefusesNonLoopbackAddr(t *testing.T) {
+	server := NewDiagnosticsServer(nil, t.TempDir(), false)
+
+	for _, addr := range []string{":9005", "0.0.0.0:9005", "192.168.1.10:9005"} {
+		err := server.Start(addr)
+		if err == nil {
+			t.Errorf("expected the diagnostics server to refuse to listen on %s", addr)
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package edge
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/portainer/agent"
+)
+
+// The instance identifier and start time are generated once per process, they allow the Portainer
+// instance to distinguish a restarted agent from a long-running one.
+var (
+	instanceID        = generateRandomID()
+	instanceStartTime = time.Now().UTC()
+)
+
+// generateRandomID returns a random 128 bits identifier encoded in hexadecimal.
+func generateRandomID() string {
+	id := make([]byte, 16)
+
+	_, err := rand.Read(id)
+	if err != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+
+	return hex.EncodeToString(id)
+}
+
+// setInstanceHeaders decorates a request sent to the Portainer instance with the identity of the agent process.
+func setInstanceHeaders(req *http.Request) {
+	req.Header.Set(agent.HTTPEdgeInstanceHeaderName, instanceID)
+	req.Header.Set(agent.HTTPEdgeStartTimeHeaderName, instanceStartTime.Format(time.RFC3339))
+	setBuildHeader(req)
+}
@@ -0,0 +1,16 @@
+package edge
+
+import "time"
+
+// jitteredPollInterval returns the poll interval adjusted by a random jitter of up to plus or minus the configured
+// ratio of the interval, so that the agents sharing the same checkin interval do not poll the Portainer instance
+// at the same time.
+func (service *PollService) jitteredPollInterval() time.Duration {
+	interval := time.Duration(service.pollIntervalInSeconds * float64(time.Second))
+	if service.pollJitter <= 0 {
+		return interval
+	}
+
+	delta := (service.random.Float64()*2 - 1) * service.pollJitter
+	return time.Duration(float64(interval) * (1 + delta))
+}
@@ -17,6 +17,8 @@ type edgeKey struct {
 	TunnelServerAddr        string
 	TunnelServerFingerprint string
 	EndpointID              string
+	// ResponseSigningKey is optional, it is the base64 encoded public key used to verify the poll responses
+	ResponseSigningKey string
 }
 
 // SetKey parses and associates an Edge key to the agent.
@@ -88,7 +90,7 @@ func (manager *Manager) PropagateKeyInCluster() error {
 }
 
 // parseEdgeKey decodes a base64 encoded key and extract the decoded information from the following
-// format: <portainer_instance_url>|<tunnel_server_addr>|<tunnel_server_fingerprint>|<endpoint_id>
+// format: <portainer_instance_url>|<tunnel_server_addr>|<tunnel_server_fingerprint>|<endpoint_id>[|<response_signing_key>]
 func parseEdgeKey(key string) (*edgeKey, error) {
 	decodedKey, err := base64.RawStdEncoding.DecodeString(key)
 	if err != nil {
@@ -97,7 +99,7 @@ func parseEdgeKey(key string) (*edgeKey, error) {
 
 	keyInfo := strings.Split(string(decodedKey), "|")
 
-	if len(keyInfo) != 4 {
+	if len(keyInfo) != 4 && len(keyInfo) != 5 {
 		return nil, errors.New("invalid key format")
 	}
 
@@ -108,11 +110,18 @@ func parseEdgeKey(key string) (*edgeKey, error) {
 		EndpointID:              keyInfo[3],
 	}
 
+	if len(keyInfo) == 5 {
+		edgeKey.ResponseSigningKey = keyInfo[4]
+	}
+
 	return edgeKey, nil
 }
 
 func encodeKey(edgeKey *edgeKey) string {
 	keyInfo := fmt.Sprintf("%s|%s|%s|%s", edgeKey.PortainerInstanceURL, edgeKey.TunnelServerAddr, edgeKey.TunnelServerFingerprint, edgeKey.EndpointID)
+	if edgeKey.ResponseSigningKey != "" {
+		keyInfo += "|" + edgeKey.ResponseSigningKey
+	}
 	encodedKey := base64.RawStdEncoding.EncodeToString([]byte(keyInfo))
 	return encodedKey
 }
@@ -0,0 +1,40 @@
+package edge
+
+import (
+	"log"
+	"net"
+)
+
+// checkLocalTargetLiveness checks that the local address targeted by the tunnel still accepts connections.
+// The tunnel is closed once the check failed for the configured number of consecutive times, so that the
+// Portainer instance is told that the local target is down instead of keeping a tunnel that cannot serve requests.
+// It returns false when the tunnel was closed.
+func (service *PollService) checkLocalTargetLiveness() bool {
+	conn, err := net.DialTimeout("tcp", service.apiServerAddr, tunnelReadinessDialTimeout)
+	if err == nil {
+		conn.Close()
+		service.localTargetFailures = 0
+		return true
+	}
+
+	service.localTargetFailures++
+
+	log.Printf("[DEBUG] [edge] [local_addr: %s] [failures: %d] [message: tunnel local address is not reachable] [error: %s]", service.apiServerAddr, service.localTargetFailures, err)
+
+	if service.localTargetFailures < service.localLivenessFailures {
+		return true
+	}
+
+	log.Printf("[WARN] [edge] [local_addr: %s] [failures: %d] [message: tunnel local address is unreachable, shutting down tunnel]", service.apiServerAddr, service.localTargetFailures)
+
+	service.localTargetFailures = 0
+
+	err = service.closeTunnel(tunnelCloseReasonLocalUnreachable)
+	if err != nil {
+		log.Printf("[ERROR] [edge] [message: unable to shutdown tunnel] [error: %s]", err)
+	}
+
+	service.triggerPoll()
+
+	return false
+}
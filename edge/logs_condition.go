@@ -0,0 +1,64 @@
+package edge
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/portainer/agent/edge/scheduler"
+)
+
+const (
+	// logsConditionAlways collects the logs of a schedule regardless of the outcome of its last run
+	logsConditionAlways = "always"
+	// logsConditionOnFailure collects the logs of a schedule when its last run exited with a non-zero code
+	logsConditionOnFailure = "on-failure"
+	// logsConditionOnSlow collects the logs of a schedule when its last run lasted longer than the slow threshold
+	logsConditionOnSlow = "on-slow"
+)
+
+func parseLogsCondition(value string) (string, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "", logsConditionAlways:
+		return logsConditionAlways, nil
+	case logsConditionOnFailure:
+		return logsConditionOnFailure, nil
+	case logsConditionOnSlow:
+		return logsConditionOnSlow, nil
+	}
+
+	return "", fmt.Errorf("invalid logs collection condition %q, expected %s, %s or %s", value, logsConditionAlways, logsConditionOnFailure, logsConditionOnSlow)
+}
+
+// shouldCollectLogs returns true when the last run of the schedule matches the logs collection condition.
+// The logs are collected when the outcome of the last run is unknown so that they are never silently dropped.
+func (service *PollService) shouldCollectLogs(scheduleID int) bool {
+	if service.logsCondition == logsConditionAlways {
+		return true
+	}
+
+	result, err := scheduler.LastRunResult(scheduleID)
+	if err != nil {
+		log.Printf("[WARN] [edge] [schedule_id: %d] [message: unable to read the result of the last run, logs will be collected] [error: %s]", scheduleID, err)
+		return true
+	}
+
+	if result == nil {
+		return true
+	}
+
+	switch service.logsCondition {
+	case logsConditionOnFailure:
+		if !result.Failed() {
+			log.Printf("[DEBUG] [edge] [schedule_id: %d] [exit_code: %d] [message: skipping log collection, the last run succeeded]", scheduleID, result.ExitCode)
+			return false
+		}
+	case logsConditionOnSlow:
+		if result.Duration <= service.logsSlowThreshold {
+			log.Printf("[DEBUG] [edge] [schedule_id: %d] [duration: %s] [message: skipping log collection, the last run was not slow]", scheduleID, result.Duration)
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,43 @@
+package edge
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const logsCollectionWindowTimeFormat = "15:04"
+
+// isInLogsCollectionWindow returns true when the specified time is within the logs collection window.
+// The window uses the HH:MM-HH:MM format in local time and can span midnight (e.g. 22:00-06:00).
+// An empty window does not restrict the collection.
+func isInLogsCollectionWindow(window string, now time.Time) (bool, error) {
+	if window == "" {
+		return true, nil
+	}
+
+	parts := strings.SplitN(window, "-", 2)
+	if len(parts) != 2 {
+		return false, fmt.Errorf("invalid logs collection window %q, expected HH:MM-HH:MM", window)
+	}
+
+	start, err := time.Parse(logsCollectionWindowTimeFormat, strings.TrimSpace(parts[0]))
+	if err != nil {
+		return false, fmt.Errorf("invalid logs collection window start %q: %w", parts[0], err)
+	}
+
+	end, err := time.Parse(logsCollectionWindowTimeFormat, strings.TrimSpace(parts[1]))
+	if err != nil {
+		return false, fmt.Errorf("invalid logs collection window end %q: %w", parts[1], err)
+	}
+
+	startMinutes := start.Hour()*60 + start.Minute()
+	endMinutes := end.Hour()*60 + end.Minute()
+	nowMinutes := now.Hour()*60 + now.Minute()
+
+	if startMinutes <= endMinutes {
+		return nowMinutes >= startMinutes && nowMinutes < endMinutes, nil
+	}
+
+	return nowMinutes >= startMinutes || nowMinutes < endMinutes, nil
+}
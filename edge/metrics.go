@@ -0,0 +1,49 @@
+package edge
+
+import "time"
+
+const (
+	metricPollSuccess   = "poll.success"
+	metricPollFailure   = "poll.failure"
+	metricPollLatency   = "poll.latency"
+	metricTunnelCreated = "tunnel.created"
+	metricTunnelClosed  = "tunnel.closed"
+	metricTunnelOpen    = "tunnel.open"
+	// metricPollPhasePrefix is the prefix of the metrics recording the duration of each phase of a poll
+	metricPollPhasePrefix = "poll.phase."
+	// metricTunnelServerReachable reports whether the last reachability check of the tunnel server succeeded
+	metricTunnelServerReachable = "tunnel_server.reachable"
+	// metricTunnelServerLatency records the duration of the successful reachability checks of the tunnel server
+	metricTunnelServerLatency = "tunnel_server.latency"
+	// metricPollInsecureFallback counts the polls that fell back to an insecure connection
+	metricPollInsecureFallback = "poll.insecure_fallback"
+	// metricPollSkippedPrefix is the prefix of the metrics counting the skipped polls for each reason
+	metricPollSkippedPrefix = "poll.skipped."
+	// metricTunnelCredentialsAge reports the age, in seconds, of the tunnel credentials sent by the Portainer instance
+	metricTunnelCredentialsAge = "tunnel.credentials_age"
+)
+
+// metricsSink is used to record the metrics associated to the poll service.
+// Implementations must not block the caller.
+type metricsSink interface {
+	IncrCounter(name string)
+	Timing(name string, duration time.Duration)
+	Gauge(name string, value float64)
+	// Close flushes the buffered metrics, metrics recorded after Close are dropped.
+	Close() error
+}
+
+type noopMetricsSink struct{}
+
+func (noopMetricsSink) IncrCounter(name string)                    {}
+func (noopMetricsSink) Timing(name string, duration time.Duration) {}
+func (noopMetricsSink) Gauge(name string, value float64)           {}
+func (noopMetricsSink) Close() error                               { return nil }
+
+func boolGauge(value bool) float64 {
+	if value {
+		return 1
+	}
+
+	return 0
+}
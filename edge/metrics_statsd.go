@@ -0,0 +1,100 @@
+package edge
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	statsdMetricPrefix = "portainer.agent."
+	statsdQueueSize    = 256
+	statsdFlushTimeout = 2 * time.Second
+)
+
+// statsdMetricsSink sends metrics as StatsD packets over UDP.
+// Metrics are queued and sent from a separate goroutine, they are dropped when the queue is full
+// so that an unreachable StatsD server never slows down the poll service.
+type statsdMetricsSink struct {
+	conn   net.Conn
+	queue  chan string
+	done   chan struct{}
+	mu     sync.RWMutex
+	closed bool
+}
+
+func newStatsdMetricsSink(addr string) (*statsdMetricsSink, error) {
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	sink := &statsdMetricsSink{
+		conn:  conn,
+		queue: make(chan string, statsdQueueSize),
+		done:  make(chan struct{}),
+	}
+
+	go sink.loop()
+
+	return sink, nil
+}
+
+func (sink *statsdMetricsSink) loop() {
+	for line := range sink.queue {
+		_, err := sink.conn.Write([]byte(line))
+		if err != nil {
+			log.Printf("[DEBUG] [edge,metrics] [message: unable to send StatsD metric] [error: %s]", err)
+		}
+	}
+
+	close(sink.done)
+}
+
+func (sink *statsdMetricsSink) send(name, value, metricType string) {
+	sink.mu.RLock()
+	defer sink.mu.RUnlock()
+
+	if sink.closed {
+		return
+	}
+
+	select {
+	case sink.queue <- fmt.Sprintf("%s%s:%s|%s", statsdMetricPrefix, name, value, metricType):
+	default:
+	}
+}
+
+func (sink *statsdMetricsSink) IncrCounter(name string) {
+	sink.send(name, "1", "c")
+}
+
+func (sink *statsdMetricsSink) Timing(name string, duration time.Duration) {
+	sink.send(name, fmt.Sprintf("%d", duration.Milliseconds()), "ms")
+}
+
+func (sink *statsdMetricsSink) Gauge(name string, value float64) {
+	sink.send(name, fmt.Sprintf("%g", value), "g")
+}
+
+// Close sends the queued metrics, waiting at most statsdFlushTimeout, and closes the connection.
+func (sink *statsdMetricsSink) Close() error {
+	sink.mu.Lock()
+	if sink.closed {
+		sink.mu.Unlock()
+		return nil
+	}
+	sink.closed = true
+	close(sink.queue)
+	sink.mu.Unlock()
+
+	select {
+	case <-sink.done:
+	case <-time.After(statsdFlushTimeout):
+		log.Printf("[WARN] [edge,metrics] [timeout_seconds: %f] [message: timeout while flushing StatsD metrics, remaining metrics are dropped]", statsdFlushTimeout.Seconds())
+	}
+
+	return sink.conn.Close()
+}
@@ -0,0 +1,38 @@
+package edge
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/portainer/agent"
+)
+
+// PollNegotiation describes the protocol settings that were effectively negotiated with the Portainer instance
+// during the most recent poll.
+type PollNegotiation struct {
+	Encoding      string
+	Compressed    bool
+	ETag          bool
+	SchemaVersion string
+	ReportDelta   bool
+	// RequestCompression is true when the Portainer instance accepts compressed request bodies
+	RequestCompression bool
+	NegotiatedAt       time.Time
+}
+
+// recordNegotiation updates the status with the protocol settings negotiated for a poll response.
+func (service *PollService) recordNegotiation(resp *http.Response, encoding string) {
+	negotiation := PollNegotiation{
+		Encoding:           encoding,
+		Compressed:         resp.Uncompressed || resp.Header.Get("Content-Encoding") != "",
+		ETag:               resp.Header.Get("ETag") != "",
+		SchemaVersion:      resp.Header.Get(agent.HTTPEdgeSchemaVersionHeaderName),
+		ReportDelta:        hasCapability(resp, capabilityReportDelta),
+		RequestCompression: hasCapability(resp, capabilityRequestCompression),
+		NegotiatedAt:       time.Now(),
+	}
+
+	service.statusMu.Lock()
+	service.status.Negotiation = negotiation
+	service.statusMu.Unlock()
+}
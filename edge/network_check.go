@@ -0,0 +1,45 @@
+package edge
+
+import (
+	"log"
+	"net"
+)
+
+// hasActiveInterface returns true when at least one non-loopback network interface is up and has an address.
+func hasActiveInterface() bool {
+	interfaces, err := net.Interfaces()
+	if err != nil {
+		// Do not prevent the poll when the interfaces cannot be inspected
+		return true
+	}
+
+	for _, iface := range interfaces {
+		if iface.Flags&net.FlagUp == 0 || iface.Flags&net.FlagLoopback != 0 {
+			continue
+		}
+
+		addrs, err := iface.Addrs()
+		if err == nil && len(addrs) > 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// checkNetwork returns false when the network is unavailable, in which case the poll is skipped.
+// The transitions between the offline and online states are logged.
+func (service *PollService) checkNetwork() bool {
+	if !service.networkCheck {
+		return true
+	}
+
+	available := networkAvailable()
+	if available && service.networkOffline {
+		log.Println("[INFO] [edge] [message: network is available again, resuming the short poll]")
+	}
+
+	service.networkOffline = !available
+
+	return available
+}
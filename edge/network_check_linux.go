@@ -0,0 +1,91 @@
+//go:build linux
+// +build linux
+
+package edge
+
+import (
+	"bufio"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const (
+	ipv4RouteFile = "/proc/net/route"
+	ipv6RouteFile = "/proc/net/ipv6_route"
+
+	routeFlagUp = 0x1
+)
+
+// networkAvailable returns true when a network interface is up and a default route is present.
+func networkAvailable() bool {
+	return hasActiveInterface() && hasDefaultRoute()
+}
+
+// hasDefaultRoute looks up an IPv4 or IPv6 default route in the kernel routing tables. The route is assumed
+// to be present when the routing tables cannot be read.
+func hasDefaultRoute() bool {
+	ipv4, err := hasIPv4DefaultRoute()
+	if err != nil {
+		return true
+	}
+
+	if ipv4 {
+		return true
+	}
+
+	ipv6, err := hasIPv6DefaultRoute()
+	if err != nil {
+		return false
+	}
+
+	return ipv6
+}
+
+func hasIPv4DefaultRoute() (bool, error) {
+	file, err := os.Open(ipv4RouteFile)
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		// Iface Destination Gateway Flags RefCnt Use Metric Mask MTU Window IRTT
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 8 || fields[1] != "00000000" || fields[7] != "00000000" {
+			continue
+		}
+
+		flags, err := strconv.ParseUint(fields[3], 16, 32)
+		if err == nil && flags&routeFlagUp != 0 {
+			return true, nil
+		}
+	}
+
+	return false, scanner.Err()
+}
+
+func hasIPv6DefaultRoute() (bool, error) {
+	file, err := os.Open(ipv6RouteFile)
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		// Destination PrefixLength Source SourcePrefixLength NextHop Metric RefCnt Use Flags Iface
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 10 || fields[1] != "00" || strings.Trim(fields[0], "0") != "" || fields[9] == "lo" {
+			continue
+		}
+
+		flags, err := strconv.ParseUint(fields[8], 16, 32)
+		if err == nil && flags&routeFlagUp != 0 {
+			return true, nil
+		}
+	}
+
+	return false, scanner.Err()
+}
@@ -0,0 +1,10 @@
+//go:build !linux
+// +build !linux
+
+package edge
+
+// networkAvailable returns true when a network interface is up, the routing table is not inspected
+// on this platform.
+func networkAvailable() bool {
+	return hasActiveInterface()
+}
@@ -0,0 +1,53 @@
+package edge
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	// operationModeFull enables every behavior of the agent
+	operationModeFull = "full"
+	// operationModeTunnelOnly only opens the reverse tunnel, the schedules and stacks are not managed
+	operationModeTunnelOnly = "tunnel-only"
+	// operationModeStacksOnly only manages the Edge stacks, the tunnel is never opened and the schedules are not managed
+	operationModeStacksOnly = "stacks-only"
+	// operationModeObserver polls the Portainer instance and reports the state of the agent without applying anything
+	operationModeObserver = "observer"
+	// operationModeMaintenance does not poll the Portainer instance
+	operationModeMaintenance = "maintenance"
+
+	maintenancePauseReason = "maintenance operation mode"
+)
+
+// operationMode is the overall posture of the agent. It defines the behaviors that are allowed, the individual
+// options can further disable a behavior allowed by the mode but cannot enable a behavior that the mode disallows.
+type operationMode struct {
+	name      string
+	poll      bool
+	tunnel    bool
+	schedules bool
+	stacks    bool
+}
+
+var operationModes = map[string]operationMode{
+	operationModeFull:        {name: operationModeFull, poll: true, tunnel: true, schedules: true, stacks: true},
+	operationModeTunnelOnly:  {name: operationModeTunnelOnly, poll: true, tunnel: true},
+	operationModeStacksOnly:  {name: operationModeStacksOnly, poll: true, stacks: true},
+	operationModeObserver:    {name: operationModeObserver, poll: true},
+	operationModeMaintenance: {name: operationModeMaintenance},
+}
+
+func parseOperationMode(value string) (operationMode, error) {
+	name := strings.ToLower(strings.TrimSpace(value))
+	if name == "" {
+		name = operationModeFull
+	}
+
+	mode, ok := operationModes[name]
+	if !ok {
+		return operationMode{}, fmt.Errorf("invalid operation mode %q, expected %s, %s, %s, %s or %s", value, operationModeFull, operationModeTunnelOnly, operationModeStacksOnly, operationModeObserver, operationModeMaintenance)
+	}
+
+	return mode, nil
+}
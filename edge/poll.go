@@ -1,24 +1,41 @@
 package edge
 
 import (
+	"context"
+	"crypto"
 	"crypto/tls"
-	"encoding/base64"
-	"encoding/json"
+	"crypto/x509"
 	"errors"
 	"fmt"
 	"log"
+	"math/rand"
+	"net"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/portainer/agent"
 	"github.com/portainer/agent/chisel"
+	"github.com/portainer/agent/edge/client"
 	"github.com/portainer/agent/edge/scheduler"
 	"github.com/portainer/agent/edge/stack"
-	"github.com/portainer/libcrypto"
 )
 
-const tunnelActivityCheckInterval = 30 * time.Second
+const (
+	tunnelActivityCheckInterval = 30 * time.Second
+	pollFastRetryDelay          = 1 * time.Second
+	// defaultSlowPollRatio is the ratio of the poll interval above which a poll is considered slow
+	// when no explicit threshold is configured.
+	defaultSlowPollRatio = 0.5
+	// clientInitRetryDelay is the delay before the first retry of the HTTP client creation, it is doubled
+	// after each failed attempt up to clientInitMaxRetryDelay.
+	clientInitRetryDelay    = 1 * time.Second
+	clientInitMaxRetryDelay = 30 * time.Second
+)
 
 // PollService is used to poll a Portainer instance to retrieve the status associated to the Edge endpoint.
 // It is responsible for managing the state of the reverse tunnel (open and closing after inactivity).
@@ -27,23 +44,128 @@ type PollService struct {
 	apiServerAddr           string
 	pollIntervalInSeconds   float64
 	pollTicker              *time.Ticker
+	heartbeatTicker         *time.Ticker
 	insecurePoll            bool
 	inactivityTimeout       time.Duration
 	edgeID                  string
 	httpClient              *http.Client
+	insecureHTTPClient      *http.Client
+	httpClientCreatedAt     time.Time
+	clientRefreshInterval   time.Duration
 	tunnelClient            agent.ReverseTunnelClient
 	scheduleManager         agent.Scheduler
 	lastActivity            time.Time
 	updateLastActivity      chan struct{}
 	startSignal             chan struct{}
 	stopSignal              chan struct{}
+	pollTrigger             chan struct{}
+	ctx                     context.Context
+	cancel                  context.CancelFunc
+	loops                   sync.WaitGroup
 	edgeStackManager        *stack.StackManager
 	portainerURL            string
 	endpointID              string
 	tunnelServerAddr        string
 	tunnelServerFingerprint string
+	tunnelServerPathPrefix  string
+	tunnelServerHeaders     map[string]string
 	logsManager             *scheduler.LogsManager
 	containerPlatform       agent.ContainerPlatform
+	metrics                 metricsSink
+	fastRetryStatusCodes    map[int]bool
+	tunnelReadinessCheck    bool
+	slowPollThreshold       time.Duration
+	logsResourceThresholds  resourceThresholds
+	statusHandlers          map[string]statusHandler
+	// tunnelCreating is set to 1 while the tunnel client is creating the reverse tunnel
+	tunnelCreating          int32
+	transport               statusTransport
+	restarts                *restartTracker
+	unhonoredRequests       []UnhonoredRequest
+	waitingForSystemTime    bool
+	credentialsHash         string
+	pollEncoding            string
+	replicas                *replicaSelector
+	tunnelStateSource       string
+	tunnelIdleProbe         bool
+	tunnelPortRange         *portRange
+	reportBuilder           *reportBuilder
+	errorLogLevels          map[string]string
+	unexpectedCredentials   string
+	credentialsCache        *cachedCredentials
+	tunnelMaxStagger        time.Duration
+	tunnelStaggerUntil      time.Time
+	random                  *rand.Rand
+	connTracker             *connLifetimeTracker
+	scheduleValidation      string
+	containerCount          *containerCountCache
+	redirectPolicy          string
+	maxRedirects            int
+	pollCycleKey            string
+	fingerprintMode         string
+	trustedFingerprints     map[string]bool
+	reconcileDeadline       time.Duration
+	phaseTimings            map[string]time.Duration
+	featureFlags            map[string]bool
+	featureFlagDefaults     map[string]bool
+	localLivenessFailures   int
+	localTargetFailures     int
+	consecutivePollFailures int
+	lastReconcileComplete   bool
+	pollBackoff             time.Duration
+	pollETag                string
+	// reconcileDeferred is set when part of the reconciliation of the current poll response is deferred
+	reconcileDeferred    bool
+	loopProbe            pollLoopProbe
+	backoffUntil         time.Time
+	agentInfoSent        string
+	events               eventSink
+	stateHash            string
+	lastSteadyStateEvent time.Time
+	pendingActions       []pollAction
+	networkOffline       bool
+	pollStartedAt        time.Time
+	warmupDone           bool
+	pollHistory          *pollHistoryWriter
+	pollSummary          pollSummary
+	insecureFallback     bool
+	triggerBackoffMode   string
+	steadyStateAfter     time.Duration
+	steadyStateInterval  time.Duration
+	decodeRetry          bool
+	reportActions        bool
+	pollGuard            *pollGuard
+	networkCheck         bool
+	deregistrationMode   string
+	deregistrationMatch  string
+	reportSigner         *reportSigner
+	openTunnelFactor     float64
+	tunnelSessionHistory int
+	pollWarmup           time.Duration
+	logsCondition        string
+	logsSlowThreshold    time.Duration
+	responseMaxAge       time.Duration
+	responseClockSkew    time.Duration
+	tunnelOutageTimeout  time.Duration
+	systemTimeCheck      string
+	tlsSessionCache      tls.ClientSessionCache
+	tlsRenegotiation     tls.RenegotiationSupport
+	operationMode        operationMode
+	pollJitter           float64
+	maxPollBackoff       time.Duration
+	async                *asyncService
+	compression          bool
+	// requestCompression is set to 1 once the Portainer instance advertised support for compressed request bodies
+	requestCompression int32
+	rootCAs            *x509.CertPool
+	clientCert         *clientCertificate
+	proxyURL           *url.URL
+	socksDialer        dialContextFunc
+	socksProxy         *url.URL
+	reportQueue        *client.ReportQueue
+	responseSigningKey crypto.PublicKey
+	status             PollServiceStatus
+	statusMu           sync.Mutex
 }
 
 type pollServiceConfig struct {
@@ -58,6 +180,86 @@ type pollServiceConfig struct {
 	TunnelServerAddr        string
 	TunnelServerFingerprint string
 	ContainerPlatform       agent.ContainerPlatform
+	ClientRefreshInterval   time.Duration
+	HeartbeatInterval       string
+	StatsdAddr              string
+	FastRetryStatusCodes    string
+	TunnelReadinessCheck    bool
+	SlowPollThreshold       time.Duration
+	LogsMinDiskFreeMB       uint64
+	LogsMinMemoryFreeMB     uint64
+	TunnelServerPathPrefix  string
+	TunnelServerHeaders     string
+	PollEncoding            string
+	ClientInitRetries       int
+	PollReplicas            string
+	ReplicaSelection        string
+	TunnelStateSource       string
+	TunnelIdleProbe         bool
+	TunnelPortRange         string
+	StatusReport            bool
+	FullReportInterval      time.Duration
+	ErrorLogLevels          string
+	UnexpectedCredentials   string
+	TunnelMaxStagger        time.Duration
+	MaxConnLifetime         time.Duration
+	ScheduleValidation      string
+	ContainerCounter        agent.ContainerCountProvider
+	ReportQueue             *client.ReportQueue
+	SnapshotProducer        agent.SnapshotProducer
+	EventRecorder           agent.EventRecorder
+	RedirectPolicy          string
+	MaxRedirects            int
+	FingerprintMode         string
+	TrustedFingerprints     string
+	ReconcileDeadline       time.Duration
+	LocalLivenessFailures   int
+	PollHistoryFile         string
+	PollHistoryMaxSizeMB    int
+	PollHistoryMaxFiles     int
+	InsecureFallback        bool
+	TriggerBackoffMode      string
+	SteadyStateAfter        time.Duration
+	SteadyStateInterval     time.Duration
+	DecodeRetry             bool
+	ReportActions           bool
+	PollOverlap             string
+	NetworkCheck            bool
+	TunnelServerCheck       time.Duration
+	DeregistrationMode      string
+	DeregistrationMatch     string
+	SignReports             bool
+	OpenTunnelFactor        float64
+	TunnelSessionHistory    int
+	PollWarmup              time.Duration
+	LogsCondition           string
+	LogsSlowThreshold       time.Duration
+	PollTransport           string
+	ResponseMaxAge          time.Duration
+	ResponseClockSkew       time.Duration
+	TunnelOutageTimeout     time.Duration
+	DataPath                string
+	ResetRestartCount       bool
+	SystemTimeCheck         string
+	TLSSessionCacheSize     int
+	TLSRenegotiation        string
+	OperationMode           string
+	PollJitter              float64
+	PollBackoffMax          time.Duration
+	AsyncInterval           time.Duration
+	PollCompression         bool
+	PollCAFiles             string
+	ClientCert              string
+	ClientKey               string
+	ClientCertPassword      string
+	ClientCertReload        bool
+	ProxyURL                string
+	SOCKS5Addr              string
+	SOCKS5Username          string
+	SOCKS5Password          string
+	FailbackInterval        time.Duration
+	ResponseKeyFile         string
+	ResponseSigningKey      string
 }
 
 // newPollService returns a pointer to a new instance of PollService, and will start two loops in go routines.
@@ -65,6 +267,7 @@ type pollServiceConfig struct {
 // if needed as well as manage schedules.
 // The second loop will check for the last activity of the reverse tunnel and close the tunnel if it exceeds the tunnel
 // inactivity duration.
+// The loops exit once Close is called.
 // If TunneCapability is disabled, it will only poll for Edge stacks and schedule without managing reverse tunnels.
 func newPollService(edgeStackManager *stack.StackManager, logsManager *scheduler.LogsManager, config *pollServiceConfig) (*PollService, error) {
 	pollFrequency, err := time.ParseDuration(config.PollFrequency)
@@ -77,8 +280,186 @@ func newPollService(edgeStackManager *stack.StackManager, logsManager *scheduler
 		return nil, err
 	}
 
+	heartbeatInterval, err := time.ParseDuration(config.HeartbeatInterval)
+	if err != nil {
+		return nil, err
+	}
+
+	apiServerAddr, err := normalizeAPIServerAddr(config.APIServerAddr)
+	if err != nil {
+		return nil, err
+	}
+
+	fastRetryStatusCodes, err := parseStatusCodes(config.FastRetryStatusCodes)
+	if err != nil {
+		return nil, err
+	}
+
+	tunnelServerHeaders, err := parseHeaders(config.TunnelServerHeaders)
+	if err != nil {
+		return nil, err
+	}
+
+	pollEncoding, err := parsePollEncoding(config.PollEncoding)
+	if err != nil {
+		return nil, err
+	}
+
+	portainerURL := primaryPortainerURL(config.PortainerURL)
+
+	var replicas *replicaSelector
+	if portainerURLs := splitPortainerURLs(config.PortainerURL); len(portainerURLs) > 1 {
+		if strings.TrimSpace(config.PollReplicas) != "" {
+			return nil, errors.New("additional poll replicas cannot be used with a list of Portainer instance URLs")
+		}
+
+		replicas, err = newFailoverSelector(portainerURLs, config.FailbackInterval)
+	} else {
+		replicas, err = newReplicaSelector(portainerURL, config.PollReplicas, config.ReplicaSelection)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	tunnelStateSource, err := parseTunnelStateSource(config.TunnelStateSource)
+	if err != nil {
+		return nil, err
+	}
+
+	tunnelPortRange, err := parsePortRange(config.TunnelPortRange)
+	if err != nil {
+		return nil, err
+	}
+
+	errorLogLevels, err := parseErrorLogLevels(config.ErrorLogLevels)
+	if err != nil {
+		return nil, err
+	}
+
+	unexpectedCredentials, err := parseUnexpectedCredentialsMode(config.UnexpectedCredentials)
+	if err != nil {
+		return nil, err
+	}
+
+	scheduleValidation, err := parseScheduleValidation(config.ScheduleValidation)
+	if err != nil {
+		return nil, err
+	}
+
+	redirectPolicy, err := parseRedirectPolicy(config.RedirectPolicy)
+	if err != nil {
+		return nil, err
+	}
+
+	fingerprintMode, err := parseFingerprintMode(config.FingerprintMode)
+	if err != nil {
+		return nil, err
+	}
+
+	deregistrationMode, err := parseDeregistrationMode(config.DeregistrationMode)
+	if err != nil {
+		return nil, err
+	}
+
+	pollOverlap, err := parsePollOverlapMode(config.PollOverlap)
+	if err != nil {
+		return nil, err
+	}
+
+	triggerBackoffMode, err := parseTriggerBackoffMode(config.TriggerBackoffMode)
+	if err != nil {
+		return nil, err
+	}
+
+	if config.AsyncInterval < 0 {
+		return nil, fmt.Errorf("invalid async interval %s, must not be negative", config.AsyncInterval)
+	}
+
+	if config.PollBackoffMax < 0 {
+		return nil, fmt.Errorf("invalid maximum poll backoff %s, must not be negative", config.PollBackoffMax)
+	}
+
+	if config.PollJitter < 0 || config.PollJitter >= 1 {
+		return nil, fmt.Errorf("invalid poll jitter %f, must be between 0 and 1", config.PollJitter)
+	}
+
+	if config.OpenTunnelFactor <= 0 {
+		config.OpenTunnelFactor = 1
+	}
+
+	if config.SteadyStateAfter > 0 && config.SteadyStateInterval <= 0 {
+		return nil, fmt.Errorf("invalid steady state interval %s, must be greater than 0", config.SteadyStateInterval)
+	}
+
+	operationMode, err := parseOperationMode(config.OperationMode)
+	if err != nil {
+		return nil, err
+	}
+
+	proxyURL, err := parseProxyURL(config.ProxyURL)
+	if err != nil {
+		return nil, err
+	}
+
+	if proxyURL != nil && config.SOCKS5Addr != "" {
+		return nil, errors.New("a proxy URL and a SOCKS5 proxy address cannot be specified at the same time")
+	}
+
+	socksDialer, err := newSOCKS5Dialer(config.SOCKS5Addr, config.SOCKS5Username, config.SOCKS5Password)
+	if err != nil {
+		return nil, err
+	}
+	socksProxy := socks5ProxyURL(config.SOCKS5Addr, config.SOCKS5Username, config.SOCKS5Password)
+
+	responseSigningKey, err := loadResponseSigningKey(config.ResponseKeyFile, config.ResponseSigningKey)
+	if err != nil {
+		return nil, err
+	}
+
+	// The freshness of the signed responses is always checked, a signed response could be replayed otherwise
+	if responseSigningKey != nil && config.ResponseMaxAge == 0 {
+		log.Printf("[INFO] [edge] [max_age: %s] [message: a response signing key is set, enforcing a maximum age for the poll responses]", agent.DefaultEdgeSignedResponseMaxAge)
+		config.ResponseMaxAge = agent.DefaultEdgeSignedResponseMaxAge
+	}
+
+	tlsSessionCache, err := newTLSSessionCache(config.TLSSessionCacheSize)
+	if err != nil {
+		return nil, err
+	}
+
+	tlsRenegotiation, err := parseTLSRenegotiation(config.TLSRenegotiation)
+	if err != nil {
+		return nil, err
+	}
+
+	systemTimeCheck, err := parseSystemTimeCheck(config.SystemTimeCheck)
+	if err != nil {
+		return nil, err
+	}
+
+	logsCondition, err := parseLogsCondition(config.LogsCondition)
+	if err != nil {
+		return nil, err
+	}
+
+	if logsCondition == logsConditionOnSlow && config.LogsSlowThreshold <= 0 {
+		return nil, fmt.Errorf("invalid logs slow threshold %s, must be greater than 0", config.LogsSlowThreshold)
+	}
+
+	if config.ResponseMaxAge < 0 || config.ResponseClockSkew < 0 {
+		return nil, fmt.Errorf("invalid response freshness window %s (clock skew %s), must not be negative", config.ResponseMaxAge, config.ResponseClockSkew)
+	}
+
+	if config.TunnelOutageTimeout < 0 {
+		return nil, fmt.Errorf("invalid tunnel poll outage timeout %s, must not be negative", config.TunnelOutageTimeout)
+	}
+
+	if config.LocalLivenessFailures < 0 {
+		return nil, fmt.Errorf("invalid number of local liveness failures %d, must not be negative", config.LocalLivenessFailures)
+	}
+
 	pollService := &PollService{
-		apiServerAddr:           config.APIServerAddr,
+		apiServerAddr:           apiServerAddr,
 		edgeID:                  config.EdgeID,
 		pollIntervalInSeconds:   pollFrequency.Seconds(),
 		pollTicker:              time.NewTicker(pollFrequency),
@@ -88,68 +469,521 @@ func newPollService(edgeStackManager *stack.StackManager, logsManager *scheduler
 		updateLastActivity:      make(chan struct{}),
 		startSignal:             make(chan struct{}),
 		stopSignal:              make(chan struct{}),
+		pollTrigger:             make(chan struct{}, 1),
 		edgeStackManager:        edgeStackManager,
-		portainerURL:            config.PortainerURL,
+		portainerURL:            portainerURL,
 		endpointID:              config.EndpointID,
 		tunnelServerAddr:        config.TunnelServerAddr,
 		tunnelServerFingerprint: config.TunnelServerFingerprint,
+		tunnelServerPathPrefix:  config.TunnelServerPathPrefix,
+		tunnelServerHeaders:     tunnelServerHeaders,
 		logsManager:             logsManager,
 		containerPlatform:       config.ContainerPlatform,
+		clientRefreshInterval:   config.ClientRefreshInterval,
+		metrics:                 noopMetricsSink{},
+		events:                  logEventSink{},
+		fastRetryStatusCodes:    fastRetryStatusCodes,
+		tunnelReadinessCheck:    config.TunnelReadinessCheck,
+		slowPollThreshold:       config.SlowPollThreshold,
+		logsResourceThresholds: resourceThresholds{
+			MinDiskFreeMB:   config.LogsMinDiskFreeMB,
+			MinMemoryFreeMB: config.LogsMinMemoryFreeMB,
+		},
+		statusHandlers:        defaultStatusHandlers(),
+		pollEncoding:          pollEncoding,
+		replicas:              replicas,
+		tunnelStateSource:     tunnelStateSource,
+		tunnelIdleProbe:       config.TunnelIdleProbe,
+		tunnelPortRange:       tunnelPortRange,
+		errorLogLevels:        errorLogLevels,
+		unexpectedCredentials: unexpectedCredentials,
+		tunnelMaxStagger:      config.TunnelMaxStagger,
+		scheduleValidation:    scheduleValidation,
+		redirectPolicy:        redirectPolicy,
+		maxRedirects:          config.MaxRedirects,
+		fingerprintMode:       fingerprintMode,
+		trustedFingerprints:   parseFingerprintList(config.TrustedFingerprints),
+		reconcileDeadline:     config.ReconcileDeadline,
+		featureFlagDefaults:   defaultFeatureFlags(config),
+		localLivenessFailures: config.LocalLivenessFailures,
+		insecureFallback:      config.InsecureFallback && !config.InsecurePoll,
+		triggerBackoffMode:    triggerBackoffMode,
+		steadyStateAfter:      config.SteadyStateAfter,
+		steadyStateInterval:   config.SteadyStateInterval,
+		decodeRetry:           config.DecodeRetry,
+		reportActions:         config.ReportActions,
+		pollGuard:             newPollGuard(pollOverlap),
+		networkCheck:          config.NetworkCheck,
+		deregistrationMode:    deregistrationMode,
+		deregistrationMatch:   config.DeregistrationMatch,
+		openTunnelFactor:      config.OpenTunnelFactor,
+		tunnelSessionHistory:  config.TunnelSessionHistory,
+		pollWarmup:            config.PollWarmup,
+		logsCondition:         logsCondition,
+		logsSlowThreshold:     config.LogsSlowThreshold,
+		responseMaxAge:        config.ResponseMaxAge,
+		responseClockSkew:     config.ResponseClockSkew,
+		tunnelOutageTimeout:   config.TunnelOutageTimeout,
+		systemTimeCheck:       systemTimeCheck,
+		tlsSessionCache:       tlsSessionCache,
+		tlsRenegotiation:      tlsRenegotiation,
+		operationMode:         operationMode,
+		pollJitter:            config.PollJitter,
+		maxPollBackoff:        config.PollBackoffMax,
+		compression:           config.PollCompression,
+		proxyURL:              proxyURL,
+		socksDialer:           socksDialer,
+		socksProxy:            socksProxy,
+		responseSigningKey:    responseSigningKey,
+		random:                rand.New(rand.NewSource(time.Now().UnixNano())),
+		status: PollServiceStatus{
+			Paused:      true,
+			PauseReason: "polling not started yet",
+		},
 	}
 
-	if config.TunnelCapability {
+	if config.StatsdAddr != "" {
+		statsdSink, err := newStatsdMetricsSink(config.StatsdAddr)
+		if err != nil {
+			log.Printf("[WARN] [edge] [statsd_addr: %s] [message: unable to setup StatsD metrics, metrics will not be emitted] [error: %s]", config.StatsdAddr, err)
+		} else {
+			pollService.metrics = statsdSink
+		}
+	}
+
+	if config.MaxConnLifetime > 0 {
+		pollService.connTracker = newConnLifetimeTracker(config.MaxConnLifetime)
+	}
+
+	err = pollService.initHTTPClient(config)
+	if err != nil {
+		return nil, err
+	}
+
+	if config.TunnelCapability && operationMode.tunnel {
 		pollService.tunnelClient = chisel.NewClient()
 	}
 
-	go pollService.startStatusPollLoop()
-	go pollService.startActivityMonitoringLoop()
+	if config.ContainerCounter != nil {
+		pollService.containerCount = newContainerCountCache(config.ContainerCounter)
+	}
+
+	pollService.reportQueue = config.ReportQueue
+
+	// The Edge stack status updates and the logs of the schedules are sent to the active Portainer instance with
+	// the TLS, proxy and dial settings of the poll requests
+	reportTransport := pollService.newTransport(pollService.insecurePoll)
+	if edgeStackManager != nil {
+		edgeStackManager.SetTransport(reportTransport)
+		edgeStackManager.SetServerAddress(pollService.replicas.activeURL)
+		edgeStackManager.SetRequestCompression(pollService.compressRequests)
+	}
+	if logsManager != nil {
+		logsManager.SetTransport(reportTransport)
+		logsManager.SetServerAddress(pollService.replicas.activeURL)
+		logsManager.SetRequestCompression(pollService.compressRequests)
+	}
+
+	if config.SignReports {
+		pollService.reportSigner, err = loadReportSigner(config.DataPath)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if config.EventRecorder != nil {
+		pollService.events = kubernetesEventSink{recorder: config.EventRecorder}
+	}
+
+	pollService.transport, err = newStatusTransport(config.PollTransport, pollService)
+	if err != nil {
+		return nil, err
+	}
+
+	if config.DataPath != "" {
+		pollService.restarts, err = loadRestartTracker(config.DataPath, config.ResetRestartCount)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if config.PollHistoryFile != "" {
+		pollService.pollHistory, err = newPollHistoryWriter(config.PollHistoryFile, config.PollHistoryMaxSizeMB, config.PollHistoryMaxFiles)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if config.StatusReport {
+		pollService.reportBuilder = newReportBuilder(config.FullReportInterval)
+	}
+
+	if heartbeatInterval > 0 {
+		pollService.heartbeatTicker = time.NewTicker(heartbeatInterval)
+	}
+
+	pollService.logProxy()
+
+	if config.AsyncInterval > 0 {
+		pollService.async = newAsyncService(pollService, config.SnapshotProducer, config.AsyncInterval)
+	}
+
+	pollService.ctx, pollService.cancel = context.WithCancel(context.Background())
+
+	pollService.runLoop(pollService.startStatusPollLoop)
+	pollService.runLoop(pollService.startActivityMonitoringLoop)
+
+	if pollService.tunnelClient != nil && config.TunnelServerCheck > 0 {
+		pollService.runLoop(func() {
+			pollService.startTunnelServerHealthLoop(config.TunnelServerCheck)
+		})
+	}
 
 	return pollService, nil
 }
 
+// normalizeAPIServerAddr ensures that the address of the agent API, used as the local end of the reverse tunnel,
+// is in the host:port format. A bare port (e.g. "9001" or ":9001") is normalized to a loopback address.
+func normalizeAPIServerAddr(addr string) (string, error) {
+	if _, err := strconv.Atoi(addr); err == nil {
+		addr = ":" + addr
+	}
+
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "", fmt.Errorf("invalid API server address %q, expected host:port: %w", addr, err)
+	}
+
+	portNumber, err := strconv.Atoi(port)
+	if err != nil || portNumber < 1 || portNumber > 65535 {
+		return "", fmt.Errorf("invalid port in API server address %q", addr)
+	}
+
+	if host == "" {
+		host = "127.0.0.1"
+	}
+
+	return net.JoinHostPort(host, port), nil
+}
+
 func (service *PollService) resetActivityTimer() {
-	if service.tunnelClient != nil && service.tunnelClient.IsTunnelOpen() {
-		service.updateLastActivity <- struct{}{}
+	if service.isTunnelOpen() {
+		select {
+		case service.updateLastActivity <- struct{}{}:
+		case <-service.ctx.Done():
+		}
+	}
+}
+
+// runLoop runs a loop of the poll service in a go routine, the loop must return once the context of the service is done.
+func (service *PollService) runLoop(loop func()) {
+	service.loops.Add(1)
+
+	go func() {
+		defer service.loops.Done()
+		loop()
+	}()
+}
+
+// Close terminates the loops of the poll service and waits for them to exit. The poll service cannot be
+// started again once closed.
+func (service *PollService) Close() {
+	service.cancel()
+	service.loops.Wait()
+}
+
+// Start starts polling the Portainer instance.
+func (service *PollService) Start() {
+	service.start()
+}
+
+// Stop pauses the polling of the Portainer instance.
+func (service *PollService) Stop() {
+	service.stop("stopped by the caller")
+}
+
+// Shutdown terminates the loops of the poll service, emits a final snapshot of the metrics and flushes
+// the buffered metrics, it must be called before the agent exits.
+func (service *PollService) Shutdown() {
+	service.Close()
+
+	service.metrics.Gauge(metricTunnelOpen, boolGauge(service.isTunnelOpen()))
+
+	err := service.metrics.Close()
+	if err != nil {
+		log.Printf("[WARN] [edge] [message: unable to flush the metrics] [error: %s]", err)
+	}
+
+	if service.restarts != nil {
+		err = service.restarts.recordExit(exitReasonShutdown)
+		if err != nil {
+			log.Printf("[WARN] [edge] [message: unable to persist the restart state] [error: %s]", err)
+		}
+	}
+
+	if service.pollHistory != nil {
+		err = service.pollHistory.close()
+		if err != nil {
+			log.Printf("[WARN] [edge] [message: unable to close the poll history file] [error: %s]", err)
+		}
 	}
 }
 
 func (service *PollService) start() {
-	service.startSignal <- struct{}{}
+	if service.isDeregistered() {
+		log.Println("[WARN] [edge] [message: the Edge ID was rejected by the Portainer instance, polling will not be started]")
+		return
+	}
+
+	if !service.operationMode.poll {
+		log.Printf("[INFO] [edge] [operation_mode: %s] [message: polling will not be started]", service.operationMode.name)
+
+		service.statusMu.Lock()
+		service.status.Paused = true
+		service.status.PauseReason = maintenancePauseReason
+		service.statusMu.Unlock()
+		return
+	}
+
+	service.statusMu.Lock()
+	service.status.Paused = false
+	service.status.PauseReason = ""
+	service.statusMu.Unlock()
+
+	select {
+	case service.startSignal <- struct{}{}:
+	case <-service.ctx.Done():
+	}
 }
 
-func (service *PollService) stop() {
-	service.stopSignal <- struct{}{}
+// stop pauses the polling, the reason is reported in the status of the service.
+func (service *PollService) stop(reason string) {
+	service.statusMu.Lock()
+	service.status.Paused = true
+	service.status.PauseReason = reason
+	service.statusMu.Unlock()
+
+	select {
+	case service.stopSignal <- struct{}{}:
+	case <-service.ctx.Done():
+	}
 }
 
 func (service *PollService) startStatusPollLoop() {
-	var pollCh <-chan time.Time
+	var pollCh, heartbeatCh, asyncCh, retryCh, clockCh <-chan time.Time
+
+	startPolling := func() {
+		service.pollStartedAt = time.Now()
+		service.warmupDone = false
+		pollCh = service.pollTicker.C
+		if service.heartbeatTicker != nil {
+			heartbeatCh = service.heartbeatTicker.C
+		}
+		if service.async != nil {
+			asyncCh = service.async.ticker.C
+		}
+	}
 
 	log.Printf("[DEBUG] [edge] [poll_interval_seconds: %f] [server_url: %s] [message: starting Portainer short-polling client]", service.pollIntervalInSeconds, service.portainerURL)
+	service.loopProbe.setRunning(true)
+	defer service.loopProbe.setRunning(false)
+	defer service.pollTicker.Stop()
+	if service.heartbeatTicker != nil {
+		defer service.heartbeatTicker.Stop()
+	}
+	if service.async != nil {
+		defer service.async.ticker.Stop()
+	}
 
 	for {
 		select {
 		case <-pollCh:
-			err := service.poll()
+			retryCh = nil
+			if service.pollJitter > 0 && service.pollBackoff == 0 {
+				service.pollTicker.Reset(service.jitteredPollInterval())
+			}
+
+			if service.inBackoff(time.Now()) {
+				service.recordPollSkip(pollSkipBackoff)
+				continue
+			}
+
+			err := service.guardedPoll(true)
+			if shouldFastRetry(err, service.fastRetryStatusCodes) && !service.inBackoff(time.Now()) {
+				log.Printf("[DEBUG] [edge] [retry_delay_seconds: %f] [message: scheduling a fast retry of the short poll]", pollFastRetryDelay.Seconds())
+				retryCh = time.After(pollFastRetryDelay)
+			}
+		case <-retryCh:
+			// Only a single fast retry is attempted per poll interval, a failing retry
+			// will wait for the next tick. The retry is part of the same poll cycle.
+			retryCh = nil
+			service.guardedPoll(false)
+		case <-service.pollTrigger:
+			if pollCh == nil {
+				service.recordPollSkip(pollSkipPaused)
+				continue
+			}
+
+			if !service.shouldPollOnTrigger(time.Now()) {
+				service.recordPollSkip(pollSkipBackoff)
+				continue
+			}
+
+			log.Println("[DEBUG] [edge] [message: immediate poll triggered]")
+			service.guardedPoll(true)
+		case <-heartbeatCh:
+			err := service.heartbeat()
 			if err != nil {
-				log.Printf("[ERROR] [edge] [message: an error occured during short poll] [error: %s]", err)
+				log.Printf("[ERROR] [edge] [message: an error occured during heartbeat] [error: %s]", err)
+			}
+		case <-asyncCh:
+			err := service.async.exchange()
+			if err != nil {
+				log.Printf("[ERROR] [edge] [message: an error occured during async exchange] [error: %s]", err)
 			}
 		case <-service.startSignal:
-			pollCh = service.pollTicker.C
+			service.agentInfoSent = ""
+			if !service.checkSystemTime(time.Now()) {
+				clockCh = time.After(systemTimeCheckInterval)
+				continue
+			}
+
+			startPolling()
+		case <-clockCh:
+			clockCh = nil
+			if !service.checkSystemTime(time.Now()) {
+				clockCh = time.After(systemTimeCheckInterval)
+				continue
+			}
+
+			startPolling()
 		case <-service.stopSignal:
 			log.Println("[DEBUG] [edge] [message: stopping Portainer short-polling client]")
 			pollCh = nil
+			heartbeatCh = nil
+			asyncCh = nil
+			retryCh = nil
+			clockCh = nil
+			service.waitingForSystemTime = false
+		case <-service.ctx.Done():
+			log.Println("[DEBUG] [edge] [message: exiting Portainer short-polling loop]")
+			return
 		}
 	}
 }
 
+// guardedPoll executes a poll unless a poll is already in progress, newCycle is set when the poll starts
+// a new poll cycle rather than retrying the current one.
+func (service *PollService) guardedPoll(newCycle bool) error {
+	if !service.checkNetwork() {
+		service.recordPollSkip(pollSkipOffline)
+		return nil
+	}
+
+	var err error
+
+	executed := service.pollGuard.run(func() {
+		service.loopProbe.enter(time.Now())
+		defer service.loopProbe.exit()
+
+		if newCycle {
+			service.pollCycleKey = generateRandomID()
+		}
+
+		err = service.executePoll(newCycle)
+	})
+
+	if !executed && service.pollGuard.mode == pollOverlapSkip {
+		service.recordPollSkip(pollSkipOverlap)
+	}
+
+	return err
+}
+
+// executePoll polls the Portainer instance and records the outcome of the poll. A failed retry of the current
+// poll cycle is not counted as another consecutive failure, newCycle is set when the poll starts a new cycle.
+func (service *PollService) executePoll(newCycle bool) error {
+	service.pollSummary = pollSummary{Timestamp: time.Now()}
+	defer service.writePollSummary()
+	defer service.queuePollActions()
+
+	err := service.poll()
+
+	// Only a single retry is attempted, and only when the response body could not be read or decoded.
+	// A response that was decoded but is unexpected is not retried.
+	var decodeErr *pollDecodeError
+	if service.decodeRetry && errors.As(err, &decodeErr) {
+		log.Printf("[WARN] [edge] [message: unable to decode the short poll response, retrying the poll] [error: %s]", err)
+		err = service.poll()
+	}
+
+	service.updateBackoff(err, time.Now())
+	if err != nil {
+		// Send the agent information in full once the Portainer instance is reachable again
+		service.agentInfoSent = ""
+
+		errorClass := classifyPollError(err)
+
+		level, ok := service.errorLogLevels[errorClass]
+		if !ok {
+			level = "ERROR"
+		}
+
+		warmup := service.inWarmup(time.Now())
+		if warmup {
+			level = warmupLevel(level)
+		}
+
+		log.Printf("[%s] [edge] [error_class: %s] [warmup: %t] [message: an error occured during short poll] [error: %s]", level, errorClass, warmup, err)
+		service.pollSummary.Error = err.Error()
+		service.pollSummary.ErrorClass = errorClass
+		if !warmup {
+			service.metrics.IncrCounter(metricPollFailure)
+		}
+		if !warmup && newCycle {
+			service.trackPollFailure(err)
+			service.applyFailureBackoff()
+		}
+		service.handleDeregistration(err)
+		return err
+	}
+
+	service.warmupDone = true
+	service.recordPollSuccess(time.Now())
+	service.replayReports()
+	service.trackPollRecovery()
+	service.resetFailureBackoff()
+	service.trackConvergence(time.Now())
+	service.metrics.IncrCounter(metricPollSuccess)
+	return nil
+}
+
+// triggerPoll requests an immediate poll of the Portainer instance, outside of the regular poll interval.
+// It does not block and multiple triggers received while a poll is executing are coalesced.
+func (service *PollService) triggerPoll() {
+	select {
+	case service.pollTrigger <- struct{}{}:
+	default:
+	}
+}
+
 func (service *PollService) startActivityMonitoringLoop() {
 	ticker := time.NewTicker(tunnelActivityCheckInterval)
+	defer ticker.Stop()
 
 	log.Printf("[DEBUG] [edge] [monitoring_interval_seconds: %f] [inactivity_timeout: %s] [message: starting activity monitoring loop]", tunnelActivityCheckInterval.Seconds(), service.inactivityTimeout.String())
 
 	for {
 		select {
 		case <-ticker.C:
+			if service.localLivenessFailures > 0 && service.isTunnelOpen() && !service.checkLocalTargetLiveness() {
+				continue
+			}
+
+			if service.tunnelOutageTimeout > 0 && service.isTunnelOpen() && !service.checkPollOutage(time.Now()) {
+				continue
+			}
+
 			if service.lastActivity.IsZero() {
 				continue
 			}
@@ -157,16 +991,28 @@ func (service *PollService) startActivityMonitoringLoop() {
 			elapsed := time.Since(service.lastActivity)
 			log.Printf("[DEBUG] [edge] [tunnel_last_activity_seconds: %f] [message: tunnel activity monitoring]", elapsed.Seconds())
 
-			if service.tunnelClient != nil && service.tunnelClient.IsTunnelOpen() && elapsed.Seconds() > service.inactivityTimeout.Seconds() {
+			if service.isTunnelOpen() && elapsed.Seconds() > service.inactivityTimeout.Seconds() {
+				if service.tunnelIdleProbe {
+					stats := service.tunnelClient.Stats()
+					if stats.LastActivity.After(service.lastActivity) {
+						log.Printf("[DEBUG] [edge] [tunnel_last_traffic_seconds: %f] [message: traffic registered on the tunnel since the last known activity, keeping the tunnel open]", time.Since(stats.LastActivity).Seconds())
+						service.lastActivity = stats.LastActivity
+						continue
+					}
+				}
+
 				log.Printf("[INFO] [edge] [tunnel_last_activity_seconds: %f] [message: shutting down tunnel after inactivity period]", elapsed.Seconds())
 
-				err := service.tunnelClient.CloseTunnel()
+				err := service.closeTunnel(tunnelCloseReasonInactivity)
 				if err != nil {
 					log.Printf("[ERROR] [edge] [message: unable to shutdown tunnel] [error: %s]", err)
 				}
 			}
 		case <-service.updateLastActivity:
 			service.lastActivity = time.Now()
+		case <-service.ctx.Done():
+			log.Println("[DEBUG] [edge] [message: exiting activity monitoring loop]")
+			return
 		}
 	}
 }
@@ -185,33 +1031,160 @@ type pollStatusResponse struct {
 	CheckinInterval float64          `json:"checkin"`
 	Credentials     string           `json:"credentials"`
 	Stacks          []stackStatus    `json:"stacks"`
+	// etag is the entity tag of the response, it is not part of the response body
+	etag string
+	// tlsVerified is true when the response was received over a connection whose certificate was verified
+	tlsVerified bool
+	// signatureVerified is true when the signature of the response was verified with the response signing key
+	signatureVerified bool
+	// TunnelServerFingerprint is only sent when the fingerprint of the tunnel server changed
+	TunnelServerFingerprint string `json:"tunnelServerFingerprint"`
+	// Flags toggles agent behaviors at runtime, unknown flags are ignored
+	Flags map[string]bool `json:"flags"`
 }
 
-func (service *PollService) createHTTPClient(timeout float64) {
-	httpCli := &http.Client{
-		Timeout: time.Duration(timeout) * time.Second,
+// initHTTPClient loads the CA bundle and the client certificate then creates the HTTP client used to poll the
+// Portainer instance. The loading and the creation are retried with an exponential backoff so that files required
+// by the client that are not available yet when the agent starts (e.g. provisioned by another container) do not
+// cause the startup to fail immediately.
+func (service *PollService) initHTTPClient(config *pollServiceConfig) error {
+	maxAttempts := config.ClientInitRetries
+	if maxAttempts < 1 {
+		maxAttempts = 1
 	}
 
-	if service.insecurePoll {
-		httpCli.Transport = &http.Transport{
-			TLSClientConfig: &tls.Config{
-				InsecureSkipVerify: true,
-			},
+	delay := clientInitRetryDelay
+
+	var err error
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		err = service.loadTLSFiles(config)
+		if err == nil {
+			err = service.createHTTPClient(clientDefaultPollTimeout)
+		}
+		if err == nil {
+			return nil
 		}
+
+		if attempt == maxAttempts {
+			break
+		}
+
+		log.Printf("[WARN] [edge] [attempt: %d] [max_attempts: %d] [retry_delay_seconds: %f] [message: unable to create the poll HTTP client, retrying] [error: %s]", attempt, maxAttempts, delay.Seconds(), err)
+
+		time.Sleep(delay)
+
+		delay *= 2
+		if delay > clientInitMaxRetryDelay {
+			delay = clientInitMaxRetryDelay
+		}
+	}
+
+	return fmt.Errorf("unable to create the poll HTTP client after %d attempts: %w", maxAttempts, err)
+}
+
+// loadTLSFiles loads the CA bundle used to verify the Portainer instance and the client certificate presented to it.
+func (service *PollService) loadTLSFiles(config *pollServiceConfig) error {
+	rootCAs, err := loadCABundle(config.PollCAFiles)
+	if err != nil {
+		return err
+	}
+
+	clientCert, err := newClientCertificate(config.ClientCert, config.ClientKey, config.ClientCertPassword, config.ClientCertReload, config.DataPath)
+	if err != nil {
+		return err
+	}
+
+	service.rootCAs = rootCAs
+	service.clientCert = clientCert
+
+	return nil
+}
+
+func (service *PollService) createHTTPClient(timeout float64) error {
+	httpCli := service.newHTTPClient(timeout, service.insecurePoll)
+
+	if service.httpClient != nil {
+		service.httpClient.CloseIdleConnections()
 	}
 
 	service.httpClient = httpCli
+	service.httpClientCreatedAt = time.Now()
+
+	if service.insecureFallback {
+		if service.insecureHTTPClient != nil {
+			service.insecureHTTPClient.CloseIdleConnections()
+		}
+
+		service.insecureHTTPClient = service.newHTTPClient(timeout, true)
+	}
+
+	return nil
+}
+
+func (service *PollService) newHTTPClient(timeout float64, insecure bool) *http.Client {
+	httpCli := &http.Client{
+		Timeout:       time.Duration(timeout) * time.Second,
+		CheckRedirect: service.checkRedirect(),
+	}
+
+	transport := service.newTransport(insecure)
+	if service.connTracker != nil {
+		transport.DialContext = service.connTracker.dialContext(transport.DialContext)
+	}
+
+	httpCli.Transport = transport
+
+	return httpCli
+}
+
+// newTransport returns a transport reaching the Portainer instance with the TLS configuration, the proxy and the
+// SOCKS5 dialer of the poll service.
+func (service *PollService) newTransport(insecure bool) *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = service.tlsConfig(insecure)
+	transport.Proxy = service.proxy()
+
+	transport.DialContext = (&net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+	}).DialContext
+	if service.socksDialer != nil {
+		transport.DialContext = service.socksDialer
+	}
+
+	return transport
 }
 
 func (service *PollService) poll() error {
+	service.phaseTimings = map[string]time.Duration{}
+	defer service.publishPhaseTimings()
 
-	pollURL := fmt.Sprintf("%s/api/endpoints/%s/status", service.portainerURL, service.endpointID)
-	req, err := http.NewRequest("GET", pollURL, nil)
+	responseData, err := service.transport.fetchStatus()
 	if err != nil {
 		return err
 	}
 
+	if responseData == nil {
+		service.recordPollSkip(pollSkipNoChange)
+		return nil
+	}
+
+	return service.applyStatus(responseData)
+}
+
+// pollStatus retrieves the status of the Edge endpoint with a poll request sent to the Portainer instance.
+func (service *PollService) pollStatus() (*pollStatusResponse, error) {
+	replica := service.replicas.selectReplica()
+
+	pollURL := fmt.Sprintf("%s/api/endpoints/%s/status", replica.URL, service.endpointID)
+	req, err := http.NewRequest("GET", pollURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
 	req.Header.Set(agent.HTTPEdgeIdentifierHeaderName, service.edgeID)
+	req.Header.Set("Accept", acceptHeader(service.pollEncoding))
+	setInstanceHeaders(req)
 
 	// When the header is not set to PlatformDocker Portainer assumes the platform to be kubernetes.
 	// However, Portainer should handle podman agents the same way as docker agents.
@@ -223,94 +1196,205 @@ func (service *PollService) poll() error {
 
 	log.Printf("[DEBUG] [edge] [message: sending agent platform header] [header: %s]", strconv.Itoa(int(agentPlatformIdentifier)))
 
-	if service.httpClient == nil {
-		service.createHTTPClient(clientDefaultPollTimeout)
+	if service.restarts != nil {
+		service.restarts.setHeaders(req)
 	}
 
-	resp, err := service.httpClient.Do(req)
+	// Report the interval actually used by the agent, it can differ from the checkin interval sent by the Portainer instance
+	req.Header.Set(agent.HTTPEdgePollIntervalHeaderName, strconv.FormatFloat(service.pollIntervalInSeconds, 'f', -1, 64))
+
+	if service.containerCount != nil {
+		count, err := service.containerCount.get()
+		if err != nil {
+			log.Printf("[DEBUG] [edge] [message: unable to retrieve the number of managed containers, it will not be reported] [error: %s]", err)
+		} else {
+			req.Header.Set(agent.HTTPEdgeContainerCountHeaderName, strconv.Itoa(count))
+		}
+	}
+
+	agentInfo, err := service.setAgentInfoHeaders(req)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		log.Printf("[DEBUG] [edge] [response_code: %d] [message: Poll request failure]", resp.StatusCode)
-		return errors.New("short poll request failed")
+	reportedActions := service.setActionsHeader(req)
+	service.setUnhonoredRequestsHeader(req)
+	// A 304 Not Modified response carries no signed body, every response is downloaded when the responses are signed
+	if service.responseSigningKey == nil {
+		setIfNoneMatchHeader(req, service.getPollETag())
+	}
+
+	var report statusReport
+	var reportType string
+	if service.reportBuilder != nil {
+		report, reportType, err = service.setReportHeaders(req, time.Now())
+		if err != nil {
+			return nil, err
+		}
+
+		if service.pollCycleKey != "" {
+			req.Header.Set(agent.HTTPEdgeIdempotencyKeyHeaderName, service.pollCycleKey)
+		}
+	}
+
+	if service.reportSigner != nil {
+		service.reportSigner.sign(req, time.Now())
+	}
+
+	if service.clientRefreshInterval > 0 && time.Since(service.httpClientCreatedAt) > service.clientRefreshInterval {
+		log.Printf("[DEBUG] [edge] [client_age_seconds: %f] [message: refreshing poll HTTP client]", time.Since(service.httpClientCreatedAt).Seconds())
+
+		err = service.createHTTPClient(service.httpClient.Timeout.Seconds())
+		if err != nil {
+			log.Printf("[WARN] [edge] [message: unable to refresh the poll HTTP client, the current client will be reused] [error: %s]", err)
+		}
+	}
+
+	if service.connTracker != nil && service.connTracker.hasExpiredConns() {
+		log.Println("[DEBUG] [edge] [message: recycling poll connections that exceeded the maximum connection lifetime]")
+		service.httpClient.CloseIdleConnections()
+	}
+
+	requestStart := time.Now()
+	insecureResponse := service.insecurePoll
+	resp, err := service.httpClient.Do(req)
+	if err != nil && service.insecureHTTPClient != nil && classifyPollError(err) == pollErrorClassTLS {
+		// The verified request always comes first, the insecure client is only used when the certificate
+		// of the Portainer instance cannot be verified
+		log.Printf("[WARN] [edge] [poll_url: %s] [message: unable to verify the TLS certificate of the Portainer instance, falling back to an insecure connection] [error: %s]", pollURL, err)
+		service.metrics.IncrCounter(metricPollInsecureFallback)
+		resp, err = service.insecureHTTPClient.Do(req)
+		insecureResponse = true
+	}
+	requestDuration := time.Since(requestStart)
+	service.recordPhaseDuration(pollPhaseNetwork, requestDuration)
+	service.metrics.Timing(metricPollLatency, requestDuration)
+	service.replicas.recordResult(replica, err == nil && resp.StatusCode < http.StatusInternalServerError)
+
+	slowPollThreshold := service.slowPollThreshold
+	if slowPollThreshold == 0 {
+		slowPollThreshold = time.Duration(service.pollIntervalInSeconds * defaultSlowPollRatio * float64(time.Second))
+	}
+
+	if requestDuration > slowPollThreshold {
+		log.Printf("[WARN] [edge] [poll_url: %s] [duration_seconds: %f] [threshold_seconds: %f] [message: slow poll request]", pollURL, requestDuration.Seconds(), slowPollThreshold.Seconds())
 	}
 
-	var responseData pollStatusResponse
-	err = json.NewDecoder(resp.Body).Decode(&responseData)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	defer resp.Body.Close()
 
-	log.Printf("[DEBUG] [edge] [status: %s] [port: %d] [schedule_count: %d] [checkin_interval_seconds: %f]", responseData.Status, responseData.Port, len(responseData.Schedules), responseData.CheckinInterval)
+	if resp.StatusCode == http.StatusNotModified {
+		if service.responseSigningKey != nil {
+			log.Printf("[WARN] [edge] [message: rejecting the poll response] [error: %s]", errUnsignedNotModified)
+			return nil, errUnsignedNotModified
+		}
 
-	if service.tunnelClient != nil {
-		if responseData.Status == "IDLE" && service.tunnelClient.IsTunnelOpen() {
-			log.Printf("[DEBUG] [edge] [status: %s] [message: Idle status detected, shutting down tunnel]", responseData.Status)
+		log.Println("[DEBUG] [edge] [message: the status did not change since the last poll]")
+		service.agentInfoSent = agentInfo
+		service.pendingActions = service.pendingActions[reportedActions:]
 
-			err := service.tunnelClient.CloseTunnel()
-			if err != nil {
-				log.Printf("[ERROR] [edge] [message: Unable to shutdown tunnel] [error: %s]", err)
-			}
+		if service.reportBuilder != nil {
+			service.reportBuilder.acknowledge(report, reportType, time.Now())
 		}
 
-		if responseData.Status == "REQUIRED" && !service.tunnelClient.IsTunnelOpen() {
-			log.Println("[DEBUG] [edge] [message: Required status detected, creating reverse tunnel]")
+		return nil, nil
+	}
 
-			err := service.createTunnel(responseData.Credentials, responseData.Port)
-			if err != nil {
-				log.Printf("[ERROR] [edge] [message: Unable to create tunnel] [error: %s]", err)
-				return err
-			}
+	if resp.StatusCode != http.StatusOK {
+		log.Printf("[DEBUG] [edge] [response_code: %d] [message: Poll request failure]", resp.StatusCode)
+		return nil, &pollStatusError{
+			StatusCode: resp.StatusCode,
+			RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()),
+			Body:       readErrorBody(resp),
 		}
 	}
 
-	err = service.scheduleManager.Schedule(responseData.Schedules)
+	err = service.validatePollResponse(resp, time.Now())
 	if err != nil {
-		log.Printf("[ERROR] [edge] [message: an error occurred during schedule management] [err: %s]", err)
+		return nil, err
 	}
 
-	logsToCollect := []int{}
-	for _, schedule := range responseData.Schedules {
-		if schedule.CollectLogs {
-			logsToCollect = append(logsToCollect, schedule.ID)
-		}
+	var responseData pollStatusResponse
+	decodeStart := time.Now()
+	responseEncoding, err := decodePollResponse(resp, &responseData)
+	service.recordPhaseDuration(pollPhaseDecode, time.Since(decodeStart))
+	if err != nil {
+		return nil, &pollDecodeError{err: err}
 	}
 
-	service.logsManager.HandleReceivedLogsRequests(logsToCollect)
+	responseData.etag = resp.Header.Get("ETag")
+	responseData.tlsVerified = resp.TLS != nil && !insecureResponse
+	responseData.signatureVerified = service.responseSigningKey != nil
+
+	service.setRequestCompressionSupported(hasCapability(resp, capabilityRequestCompression))
+	service.recordNegotiation(resp, responseEncoding)
+	service.agentInfoSent = agentInfo
+	service.pendingActions = service.pendingActions[reportedActions:]
 
-	if responseData.CheckinInterval != service.pollIntervalInSeconds {
-		log.Printf("[DEBUG] [edge] [old_interval: %f] [new_interval: %f] [message: updating poll interval]", service.pollIntervalInSeconds, responseData.CheckinInterval)
-		service.pollIntervalInSeconds = responseData.CheckinInterval
-		service.createHTTPClient(responseData.CheckinInterval)
-		service.pollTicker.Reset(time.Duration(service.pollIntervalInSeconds) * time.Second)
+	if service.reportBuilder != nil {
+		service.reportBuilder.deltaSupported = hasCapability(resp, capabilityReportDelta)
+		service.reportBuilder.acknowledge(report, reportType, time.Now())
 	}
 
-	if responseData.Stacks != nil {
-		stacks := map[int]int{}
-		for _, stack := range responseData.Stacks {
-			stacks[stack.ID] = stack.Version
-		}
+	return &responseData, nil
+}
+
+// applyStatus reconciles the state of the agent with the status of the Edge endpoint, regardless of the
+// transport used to retrieve it.
+func (service *PollService) applyStatus(responseData *pollStatusResponse) error {
+	service.pollSummary.Status = responseData.Status
+	service.trackSteadyState(responseData, time.Now())
+	service.resetUnhonoredRequests()
+	service.trackCredentials(responseData.Credentials, time.Now())
 
-		err := service.edgeStackManager.UpdateStacksStatus(stacks)
+	log.Printf("[DEBUG] [edge] [status: %s] [port: %d] [schedule_count: %d] [checkin_interval_seconds: %f] [transport: %s]", responseData.Status, responseData.Port, len(responseData.Schedules), responseData.CheckinInterval, service.transport.name())
+
+	service.updateTunnelServerFingerprint(responseData)
+	service.applyFeatureFlags(responseData.Flags)
+
+	checkinInterval := service.effectiveCheckinInterval(responseData.CheckinInterval)
+	if checkinInterval != service.pollIntervalInSeconds {
+		log.Printf("[DEBUG] [edge] [old_interval: %f] [new_interval: %f] [message: updating poll interval]", service.pollIntervalInSeconds, checkinInterval)
+		service.pollIntervalInSeconds = checkinInterval
+		err := service.createHTTPClient(checkinInterval)
 		if err != nil {
-			log.Printf("[ERROR] [edge] [message: an error occurred during stack management] [error: %s]", err)
-			return err
+			log.Printf("[ERROR] [edge] [message: unable to update the poll HTTP client timeout, the current client will be reused] [error: %s]", err)
 		}
+		service.pollTicker.Reset(service.jitteredPollInterval())
 	}
 
-	return nil
-}
+	ctx := context.Background()
+	if service.reconcileDeadline > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, service.reconcileDeadline)
+		defer cancel()
+	}
 
-func (service *PollService) createTunnel(encodedCredentials string, remotePort int) error {
-	decodedCredentials, err := base64.RawStdEncoding.DecodeString(encodedCredentials)
-	if err != nil {
-		return err
+	service.reconcileDeferred = false
+	err := service.reconcile(ctx, responseData)
+	service.lastReconcileComplete = err == nil
+
+	// The response can only be skipped during the next polls once it was fully applied
+	if err == nil && !service.reconcileDeferred {
+		service.setPollETag(responseData.etag)
+	} else {
+		service.invalidatePollETag()
+	}
+	if errors.Is(err, errReconcileDeadlineExceeded) {
+		log.Printf("[WARN] [edge] [deadline_seconds: %f] [message: poll reconciliation deadline exceeded, the remaining work will be done during the next poll]", service.reconcileDeadline.Seconds())
+		service.recordPollAction(pollActionReconcileDeferred, "", nil)
+		return nil
 	}
 
-	credentials, err := libcrypto.Decrypt(decodedCredentials, []byte(service.edgeID))
+	return err
+}
+
+// createTunnel opens the reverse tunnel. When the context is done before the tunnel client returns, the creation
+// is completed in the background and no other creation is attempted until it returns.
+func (service *PollService) createTunnel(ctx context.Context, encodedCredentials string, remotePort int) error {
+	credentials, err := service.tunnelCredentials(encodedCredentials)
 	if err != nil {
 		return err
 	}
@@ -318,16 +1402,72 @@ func (service *PollService) createTunnel(encodedCredentials string, remotePort i
 	tunnelConfig := agent.TunnelConfig{
 		ServerAddr:       service.tunnelServerAddr,
 		ServerFingerpint: service.tunnelServerFingerprint,
-		Credentials:      string(credentials),
+		ServerPathPrefix: service.tunnelServerPathPrefix,
+		ServerHeaders:    service.tunnelServerHeaders,
+		Credentials:      credentials,
 		RemotePort:       strconv.Itoa(remotePort),
 		LocalAddr:        service.apiServerAddr,
+		TrackActivity:    service.tunnelIdleProbe,
 	}
-
-	err = service.tunnelClient.CreateTunnel(tunnelConfig)
+	tunnelConfig.ClientCert, tunnelConfig.ClientKey, err = service.clientCert.tunnelFiles()
 	if err != nil {
 		return err
 	}
 
+	tunnelProxy := service.proxyFor(tunnelHTTPURL(service.tunnelServerAddr))
+	if service.socksProxy != nil {
+		tunnelProxy = service.socksProxy
+	}
+	if tunnelProxy != nil {
+		tunnelConfig.Proxy = tunnelProxy.String()
+	}
+
+	redactedConfig := redactTunnelConfig(tunnelConfig)
+	log.Printf("[DEBUG] [edge] [server_addr: %s] [server_fingerprint: %s] [remote_port: %s] [local_addr: %s] [credentials: %s] [message: creating reverse tunnel]", redactedConfig.ServerAddr, redactedConfig.ServerFingerpint, redactedConfig.RemotePort, redactedConfig.LocalAddr, redactedConfig.Credentials)
+
+	service.statusMu.Lock()
+	service.status.LastTunnelConfig = &redactedConfig
+	service.statusMu.Unlock()
+
+	if service.tunnelReadinessCheck {
+		err = checkLocalAddrReadiness(ctx, service.apiServerAddr)
+		if ctx.Err() != nil {
+			return errReconcileDeadlineExceeded
+		}
+		if err != nil {
+			return fmt.Errorf("tunnel local address %s is not reachable, the tunnel will not be created: %w", service.apiServerAddr, err)
+		}
+	}
+
+	atomic.StoreInt32(&service.tunnelCreating, 1)
+	result := make(chan error, 1)
+	go func() {
+		defer atomic.StoreInt32(&service.tunnelCreating, 0)
+
+		err := service.tunnelClient.CreateTunnel(tunnelConfig)
+		if err == nil {
+			service.tunnelCreated(remotePort)
+		}
+		result <- err
+	}()
+
+	select {
+	case err = <-result:
+		return err
+	case <-ctx.Done():
+		log.Println("[WARN] [edge] [message: the reverse tunnel creation exceeded the reconciliation deadline, it is completed in the background]")
+		return errReconcileDeadlineExceeded
+	}
+}
+
+// tunnelCreated records the creation of the reverse tunnel.
+func (service *PollService) tunnelCreated(remotePort int) {
+	service.setTunnelOpen(true)
+	service.recordTunnelOpened(remotePort)
+	service.emitEvent(eventReasonTunnelOpened, eventSeverityNormal, fmt.Sprintf("reverse tunnel opened on remote port %d", remotePort))
+
+	service.metrics.IncrCounter(metricTunnelCreated)
+	service.metrics.Gauge(metricTunnelOpen, 1)
+
 	service.resetActivityTimer()
-	return nil
 }
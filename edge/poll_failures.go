@@ -0,0 +1,34 @@
+package edge
+
+import "fmt"
+
+const (
+	eventReasonPollFailing   = "PollFailing"
+	eventReasonPollRecovered = "PollRecovered"
+
+	// pollFailureEventThreshold is the number of consecutive poll failures after which an event is emitted
+	pollFailureEventThreshold = 3
+)
+
+// trackPollFailure counts the consecutive poll failures and emits an event when the failure threshold is crossed.
+func (service *PollService) trackPollFailure(err error) {
+	service.consecutivePollFailures++
+
+	if service.consecutivePollFailures != pollFailureEventThreshold {
+		return
+	}
+
+	service.emitEvent(eventReasonPollFailing, eventSeverityWarning, fmt.Sprintf("the Portainer instance could not be polled %d consecutive times: %s", service.consecutivePollFailures, err))
+}
+
+// trackPollRecovery resets the consecutive poll failures and emits an event when the failure threshold was crossed.
+func (service *PollService) trackPollRecovery() {
+	failures := service.consecutivePollFailures
+	service.consecutivePollFailures = 0
+
+	if failures < pollFailureEventThreshold {
+		return
+	}
+
+	service.emitEvent(eventReasonPollRecovered, eventSeverityNormal, fmt.Sprintf("the Portainer instance is reachable again after %d consecutive poll failures", failures))
+}
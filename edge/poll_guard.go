@@ -0,0 +1,71 @@
+package edge
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+)
+
+const (
+	// pollOverlapSkip skips the polls requested while a poll is in progress
+	pollOverlapSkip = "skip"
+	// pollOverlapQueue queues a single poll, executed once the poll in progress completes, the polls
+	// requested while a poll is already queued are coalesced
+	pollOverlapQueue = "queue"
+)
+
+func parsePollOverlapMode(value string) (string, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "", pollOverlapSkip:
+		return pollOverlapSkip, nil
+	case pollOverlapQueue:
+		return pollOverlapQueue, nil
+	}
+
+	return "", fmt.Errorf("invalid poll overlap mode %q, expected %s or %s", value, pollOverlapSkip, pollOverlapQueue)
+}
+
+// pollGuard ensures that at most one poll is executed at a time.
+type pollGuard struct {
+	mode    string
+	running bool
+	queued  bool
+	mu      sync.Mutex
+}
+
+func newPollGuard(mode string) *pollGuard {
+	return &pollGuard{mode: mode}
+}
+
+// run executes fn unless a poll is already in progress, in which case fn is either skipped or queued depending
+// on the overlap mode. It returns false when fn was not executed by this call.
+func (guard *pollGuard) run(fn func()) bool {
+	guard.mu.Lock()
+	if guard.running {
+		if guard.mode == pollOverlapQueue {
+			guard.queued = true
+			log.Println("[DEBUG] [edge] [message: a poll is already in progress, queuing the poll]")
+		} else {
+			log.Println("[DEBUG] [edge] [message: a poll is already in progress, skipping the poll]")
+		}
+
+		guard.mu.Unlock()
+		return false
+	}
+	guard.running = true
+	guard.mu.Unlock()
+
+	for {
+		fn()
+
+		guard.mu.Lock()
+		if !guard.queued {
+			guard.running = false
+			guard.mu.Unlock()
+			return true
+		}
+		guard.queued = false
+		guard.mu.Unlock()
+	}
+}
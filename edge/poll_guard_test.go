@@ -0,0 +1,65 @@
+package edge
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestPollGuardOverlappingTicks(t *testing.T) {
+	tests := []struct {
+		mode       string
+		executions int32
+	}{
+		{mode: pollOverlapSkip, executions: 1},
+		{mode: pollOverlapQueue, executions: 2},
+	}
+
+	for _, test := range tests {
+		guard := newPollGuard(test.mode)
+
+		var executions int32
+		started := make(chan struct{})
+		release := make(chan struct{})
+
+		slowPoll := func() {
+			if atomic.AddInt32(&executions, 1) == 1 {
+				close(started)
+				<-release
+			}
+		}
+
+		done := make(chan bool)
+		go func() {
+			done <- guard.run(slowPoll)
+		}()
+
+		<-started
+
+		// Ticks firing while the slow poll is in progress
+		var wg sync.WaitGroup
+		for i := 0; i < 3; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if guard.run(slowPoll) {
+					t.Errorf("mode %s: expected an overlapping poll not to be executed by the caller", test.mode)
+				}
+			}()
+		}
+		wg.Wait()
+
+		close(release)
+		if !<-done {
+			t.Errorf("mode %s: expected the first poll to be executed", test.mode)
+		}
+
+		if result := atomic.LoadInt32(&executions); result != test.executions {
+			t.Errorf("mode %s: %d polls executed, expected %d", test.mode, result, test.executions)
+		}
+
+		if !guard.run(func() {}) {
+			t.Errorf("mode %s: expected a poll to be executed once the previous poll completed", test.mode)
+		}
+	}
+}
@@ -0,0 +1,195 @@
+package edge
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	pollActionTunnelCreated     = "tunnel-created"
+	pollActionTunnelClosed      = "tunnel-closed"
+	pollActionSchedulesApplied  = "schedules-applied"
+	pollActionLogsRequested     = "logs-requested"
+	pollActionStacksUpdated     = "stacks-updated"
+	pollActionReconcileDeferred = "reconcile-deferred"
+)
+
+// pollSummary is the structured record of a single poll: what the Portainer instance answered,
+// what the agent did in response and how long it took.
+type pollSummary struct {
+	Timestamp  time.Time          `json:"timestamp"`
+	Status     string             `json:"status,omitempty"`
+	Actions    []pollAction       `json:"actions,omitempty"`
+	Error      string             `json:"error,omitempty"`
+	ErrorClass string             `json:"errorClass,omitempty"`
+	DurationMs float64            `json:"durationMs"`
+	PhasesMs   map[string]float64 `json:"phasesMs,omitempty"`
+}
+
+// pollAction is an action performed by the agent in response to a poll and its outcome.
+type pollAction struct {
+	Name    string `json:"name"`
+	Detail  string `json:"detail,omitempty"`
+	Success bool   `json:"success"`
+	Error   string `json:"error,omitempty"`
+}
+
+// recordPollAction adds an action performed by the agent, and its outcome, to the summary of the current poll.
+func (service *PollService) recordPollAction(name, detail string, err error) {
+	action := pollAction{
+		Name:    name,
+		Detail:  detail,
+		Success: err == nil,
+	}
+	if err != nil {
+		action.Error = err.Error()
+	}
+
+	service.pollSummary.Actions = append(service.pollSummary.Actions, action)
+}
+
+// pollHistoryWriter writes the poll summaries as JSON lines to a file, the file is rotated once it reaches
+// the maximum size and only the configured number of rotated files are kept (file.1 being the most recent).
+type pollHistoryWriter struct {
+	path     string
+	maxSize  int64
+	maxFiles int
+	file     *os.File
+	size     int64
+}
+
+func newPollHistoryWriter(path string, maxSizeMB, maxFiles int) (*pollHistoryWriter, error) {
+	if maxSizeMB <= 0 {
+		return nil, fmt.Errorf("invalid poll history maximum size %d, must be greater than 0", maxSizeMB)
+	}
+
+	if maxFiles < 0 {
+		return nil, fmt.Errorf("invalid poll history file count %d, must not be negative", maxFiles)
+	}
+
+	err := os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return nil, err
+	}
+
+	writer := &pollHistoryWriter{
+		path:     path,
+		maxSize:  int64(maxSizeMB) * 1024 * 1024,
+		maxFiles: maxFiles,
+	}
+
+	err = writer.open()
+	if err != nil {
+		return nil, err
+	}
+
+	return writer, nil
+}
+
+func (writer *pollHistoryWriter) open() error {
+	file, err := os.OpenFile(writer.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0640)
+	if err != nil {
+		return err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	writer.file = file
+	writer.size = info.Size()
+
+	return nil
+}
+
+// write appends the summary to the history file, rotating the file first when the summary does not fit in it.
+func (writer *pollHistoryWriter) write(summary pollSummary) error {
+	data, err := json.Marshal(summary)
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	if writer.size > 0 && writer.size+int64(len(data)) > writer.maxSize {
+		err = writer.rotate()
+		if err != nil {
+			return err
+		}
+	}
+
+	n, err := writer.file.Write(data)
+	writer.size += int64(n)
+
+	return err
+}
+
+func (writer *pollHistoryWriter) rotate() error {
+	err := writer.file.Close()
+	if err != nil {
+		return err
+	}
+
+	if writer.maxFiles == 0 {
+		err = os.Remove(writer.path)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+
+		return writer.open()
+	}
+
+	os.Remove(writer.rotatedPath(writer.maxFiles))
+
+	for index := writer.maxFiles - 1; index >= 1; index-- {
+		err = os.Rename(writer.rotatedPath(index), writer.rotatedPath(index+1))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	err = os.Rename(writer.path, writer.rotatedPath(1))
+	if err != nil {
+		return err
+	}
+
+	return writer.open()
+}
+
+func (writer *pollHistoryWriter) rotatedPath(index int) string {
+	return fmt.Sprintf("%s.%d", writer.path, index)
+}
+
+func (writer *pollHistoryWriter) close() error {
+	return writer.file.Close()
+}
+
+// writePollSummary completes the summary of the current poll and appends it to the poll history.
+func (service *PollService) writePollSummary() {
+	if service.pollHistory == nil {
+		return
+	}
+
+	summary := service.pollSummary
+	summary.DurationMs = durationMs(time.Since(summary.Timestamp))
+	if len(service.phaseTimings) > 0 {
+		summary.PhasesMs = make(map[string]float64, len(service.phaseTimings))
+		for phase, duration := range service.phaseTimings {
+			summary.PhasesMs[phase] = durationMs(duration)
+		}
+	}
+
+	err := service.pollHistory.write(summary)
+	if err != nil {
+		log.Printf("[WARN] [edge] [message: unable to write the poll history] [error: %s]", err)
+	}
+}
+
+func durationMs(duration time.Duration) float64 {
+	return float64(duration) / float64(time.Millisecond)
+}
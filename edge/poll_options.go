@@ -0,0 +1,560 @@
+package edge
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/portainer/agent"
+	"github.com/portainer/agent/edge/client"
+	"github.com/portainer/agent/edge/scheduler"
+	"github.com/portainer/agent/edge/stack"
+)
+
+// Option is used to configure a PollService created with NewPollService.
+type Option func(options *pollServiceOptions)
+
+type pollServiceOptions struct {
+	config           pollServiceConfig
+	edgeStackManager *stack.StackManager
+	logsManager      *scheduler.LogsManager
+}
+
+// NewPollService returns a pointer to a new instance of PollService configured with the specified options.
+// The Portainer instance, the Edge identifier as well as the stack and logs managers are required, every other
+// setting uses the same default value as the agent.
+func NewPollService(opts ...Option) (*PollService, error) {
+	options := &pollServiceOptions{
+		config: pollServiceConfig{
+			APIServerAddr:        agent.DefaultAgentAddr + ":" + agent.DefaultAgentPort,
+			PollFrequency:        agent.DefaultEdgePollInterval,
+			InactivityTimeout:    agent.DefaultEdgeSleepInterval,
+			HeartbeatInterval:    agent.DefaultEdgeHeartbeatInterval,
+			PollEncoding:         agent.DefaultEdgePollEncoding,
+			ClientInitRetries:    agent.DefaultEdgeClientInitRetries,
+			FastRetryStatusCodes: agent.DefaultEdgeFastRetryCodes,
+			RedirectPolicy:       agent.DefaultEdgeRedirectPolicy,
+			MaxRedirects:         agent.DefaultEdgeMaxRedirects,
+			ReplicaSelection:     agent.DefaultEdgeReplicaSelection,
+			TunnelStateSource:    agent.DefaultEdgeTunnelStateSource,
+			FullReportInterval:   agent.DefaultEdgeFullReportInterval,
+			PollHistoryMaxSizeMB: agent.DefaultEdgePollHistorySizeMB,
+			PollHistoryMaxFiles:  agent.DefaultEdgePollHistoryFiles,
+			SteadyStateInterval:  agent.DefaultEdgeSteadyStateInterval,
+			TunnelSessionHistory: agent.DefaultEdgeTunnelSessionHistory,
+			LogsSlowThreshold:    agent.DefaultEdgeLogsSlowThreshold,
+			ResponseClockSkew:    agent.DefaultEdgeResponseClockSkew,
+			TLSSessionCacheSize:  agent.DefaultEdgeTLSSessionCacheSize,
+			OperationMode:        agent.DefaultOperationMode,
+			PollCompression:      agent.DefaultEdgePollCompression,
+			FailbackInterval:     agent.DefaultEdgeFailbackInterval,
+		},
+	}
+
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	switch {
+	case options.config.PortainerURL == "" || options.config.EndpointID == "":
+		return nil, errors.New("the Portainer instance URL and the endpoint identifier are required")
+	case options.config.EdgeID == "":
+		return nil, errors.New("the Edge identifier is required")
+	case options.edgeStackManager == nil || options.logsManager == nil:
+		return nil, errors.New("the stack manager and the logs manager are required")
+	}
+
+	return newPollService(options.edgeStackManager, options.logsManager, &options.config)
+}
+
+// WithPortainerInstance sets the URL of the Portainer instance and the identifier of the Edge endpoint to poll.
+// The URL can be a comma separated list of Portainer instance URLs, ordered by preference, to fail over between them.
+func WithPortainerInstance(portainerURL, endpointID string) Option {
+	return func(options *pollServiceOptions) {
+		options.config.PortainerURL = portainerURL
+		options.config.EndpointID = endpointID
+	}
+}
+
+// WithEdgeID sets the Edge identifier sent with each request.
+func WithEdgeID(edgeID string) Option {
+	return func(options *pollServiceOptions) {
+		options.config.EdgeID = edgeID
+	}
+}
+
+// WithManagers sets the managers used to handle the Edge stacks and the logs collection of the schedules.
+func WithManagers(edgeStackManager *stack.StackManager, logsManager *scheduler.LogsManager) Option {
+	return func(options *pollServiceOptions) {
+		options.edgeStackManager = edgeStackManager
+		options.logsManager = logsManager
+	}
+}
+
+// WithContainerCounter enables the report of the number of containers managed by the container platform.
+func WithContainerCounter(counter agent.ContainerCountProvider) Option {
+	return func(options *pollServiceOptions) {
+		options.config.ContainerCounter = counter
+	}
+}
+
+// WithContainerPlatform sets the container platform reported to the Portainer instance.
+func WithContainerPlatform(platform agent.ContainerPlatform) Option {
+	return func(options *pollServiceOptions) {
+		options.config.ContainerPlatform = platform
+	}
+}
+
+// WithAPIServerAddr sets the address of the agent API, used as the local end of the reverse tunnel.
+func WithAPIServerAddr(addr string) Option {
+	return func(options *pollServiceOptions) {
+		options.config.APIServerAddr = addr
+	}
+}
+
+// WithPollFrequency sets the initial interval between two poll requests.
+func WithPollFrequency(frequency time.Duration) Option {
+	return func(options *pollServiceOptions) {
+		options.config.PollFrequency = frequency.String()
+	}
+}
+
+// WithInsecurePoll disables the verification of the Portainer instance TLS certificate.
+func WithInsecurePoll(insecure bool) Option {
+	return func(options *pollServiceOptions) {
+		options.config.InsecurePoll = insecure
+	}
+}
+
+// WithInsecureFallback enables a fallback to an insecure connection when the TLS certificate of the Portainer
+// instance cannot be verified, the verified connection is always attempted first.
+func WithInsecureFallback(fallback bool) Option {
+	return func(options *pollServiceOptions) {
+		options.config.InsecureFallback = fallback
+	}
+}
+
+// WithHeartbeatInterval enables the heartbeats sent between two poll requests.
+func WithHeartbeatInterval(interval time.Duration) Option {
+	return func(options *pollServiceOptions) {
+		options.config.HeartbeatInterval = interval.String()
+	}
+}
+
+// WithHTTPClientSettings sets the maximum age of the poll HTTP client and the number of attempts made to create it.
+func WithHTTPClientSettings(refreshInterval time.Duration, initRetries int) Option {
+	return func(options *pollServiceOptions) {
+		options.config.ClientRefreshInterval = refreshInterval
+		options.config.ClientInitRetries = initRetries
+	}
+}
+
+// WithMaxConnLifetime sets the maximum lifetime of the connections used to poll the Portainer instance.
+func WithMaxConnLifetime(maxLifetime time.Duration) Option {
+	return func(options *pollServiceOptions) {
+		options.config.MaxConnLifetime = maxLifetime
+	}
+}
+
+// WithPollEncoding sets the encoding requested for the poll responses (json or msgpack).
+func WithPollEncoding(encoding string) Option {
+	return func(options *pollServiceOptions) {
+		options.config.PollEncoding = encoding
+	}
+}
+
+// WithReconcileDeadline sets the maximum duration of the reconciliation of a poll response.
+func WithReconcileDeadline(deadline time.Duration) Option {
+	return func(options *pollServiceOptions) {
+		options.config.ReconcileDeadline = deadline
+	}
+}
+
+// WithRedirectPolicy sets the behavior when the Portainer instance answers with a redirect (error, follow or limited)
+// and the maximum number of redirects followed by the limited policy.
+func WithRedirectPolicy(policy string, maxRedirects int) Option {
+	return func(options *pollServiceOptions) {
+		options.config.RedirectPolicy = policy
+		options.config.MaxRedirects = maxRedirects
+	}
+}
+
+// WithReplicas sets the additional Portainer instance replicas used to spread the poll requests and the
+// strategy used to select them (round-robin or random).
+func WithReplicas(replicaURLs []string, selection string) Option {
+	return func(options *pollServiceOptions) {
+		options.config.PollReplicas = strings.Join(replicaURLs, ",")
+		options.config.ReplicaSelection = selection
+	}
+}
+
+// WithFastRetryStatusCodes sets the status codes that trigger a fast retry of a failed poll request.
+func WithFastRetryStatusCodes(statusCodes string) Option {
+	return func(options *pollServiceOptions) {
+		options.config.FastRetryStatusCodes = statusCodes
+	}
+}
+
+// WithSlowPollThreshold sets the duration above which a poll request is logged as slow.
+func WithSlowPollThreshold(threshold time.Duration) Option {
+	return func(options *pollServiceOptions) {
+		options.config.SlowPollThreshold = threshold
+	}
+}
+
+// WithErrorLogLevels sets the class=LEVEL mappings used to log poll errors.
+func WithErrorLogLevels(levels string) Option {
+	return func(options *pollServiceOptions) {
+		options.config.ErrorLogLevels = levels
+	}
+}
+
+// WithScheduleValidation sets the validation policy applied to the schedules before they are applied (none, reject or skip).
+func WithScheduleValidation(policy string) Option {
+	return func(options *pollServiceOptions) {
+		options.config.ScheduleValidation = policy
+	}
+}
+
+// WithStatsd enables the emission of StatsD metrics to the specified address.
+func WithStatsd(addr string) Option {
+	return func(options *pollServiceOptions) {
+		options.config.StatsdAddr = addr
+	}
+}
+
+// WithStatusReport enables the status reports sent with the poll requests.
+func WithStatusReport(fullReportInterval time.Duration) Option {
+	return func(options *pollServiceOptions) {
+		options.config.StatusReport = true
+		options.config.FullReportInterval = fullReportInterval
+	}
+}
+
+// WithLogsResourceThresholds sets the minimum amount of disk space and memory (in MB) required to collect schedule logs.
+func WithLogsResourceThresholds(minDiskFreeMB, minMemoryFreeMB uint64) Option {
+	return func(options *pollServiceOptions) {
+		options.config.LogsMinDiskFreeMB = minDiskFreeMB
+		options.config.LogsMinMemoryFreeMB = minMemoryFreeMB
+	}
+}
+
+// WithTunnel enables the management of the reverse tunnel using the specified tunnel server.
+func WithTunnel(serverAddr, serverFingerprint string, inactivityTimeout time.Duration) Option {
+	return func(options *pollServiceOptions) {
+		options.config.TunnelCapability = true
+		options.config.TunnelServerAddr = serverAddr
+		options.config.TunnelServerFingerprint = serverFingerprint
+		options.config.InactivityTimeout = inactivityTimeout.String()
+	}
+}
+
+// WithTunnelFingerprintMode sets the behavior when the Portainer instance sends a new tunnel server fingerprint
+// (adopt, trusted or disabled) and the fingerprints that can be adopted with the trusted mode.
+func WithTunnelFingerprintMode(mode string, trustedFingerprints []string) Option {
+	return func(options *pollServiceOptions) {
+		options.config.FingerprintMode = mode
+		options.config.TrustedFingerprints = strings.Join(trustedFingerprints, ",")
+	}
+}
+
+// WithTunnelServerConnection sets the path prefix and the headers (Name=Value list) used to connect to the tunnel server.
+func WithTunnelServerConnection(pathPrefix, headers string) Option {
+	return func(options *pollServiceOptions) {
+		options.config.TunnelServerPathPrefix = pathPrefix
+		options.config.TunnelServerHeaders = headers
+	}
+}
+
+// WithTunnelChecks configures the checks performed around the reverse tunnel: the readiness check of the local
+// address, the traffic probe before an inactivity close and the expected range (MIN-MAX) of the tunnel ports.
+func WithTunnelChecks(readinessCheck, idleProbe bool, portRange string) Option {
+	return func(options *pollServiceOptions) {
+		options.config.TunnelReadinessCheck = readinessCheck
+		options.config.TunnelIdleProbe = idleProbe
+		options.config.TunnelPortRange = portRange
+	}
+}
+
+// WithPollHistory enables the history of the polls, written as JSON lines to the file at path. The file is rotated
+// once it reaches maxSizeMB and maxFiles rotated files are kept.
+func WithPollHistory(path string, maxSizeMB, maxFiles int) Option {
+	return func(options *pollServiceOptions) {
+		options.config.PollHistoryFile = path
+		options.config.PollHistoryMaxSizeMB = maxSizeMB
+		options.config.PollHistoryMaxFiles = maxFiles
+	}
+}
+
+// WithTriggerBackoffMode sets whether the immediate polls triggered while the poll service is backing off
+// are skipped (respect) or executed (ignore).
+func WithTriggerBackoffMode(mode string) Option {
+	return func(options *pollServiceOptions) {
+		options.config.TriggerBackoffMode = mode
+	}
+}
+
+// WithSteadyStateEvents enables the events confirming that the agent is healthy once the state requested by the
+// Portainer instance did not change for the after duration, the events are then emitted at the specified interval.
+func WithSteadyStateEvents(after, interval time.Duration) Option {
+	return func(options *pollServiceOptions) {
+		options.config.SteadyStateAfter = after
+		options.config.SteadyStateInterval = interval
+	}
+}
+
+// WithDecodeRetry enables a single immediate retry of the poll when the response cannot be read or decoded.
+func WithDecodeRetry(retry bool) Option {
+	return func(options *pollServiceOptions) {
+		options.config.DecodeRetry = retry
+	}
+}
+
+// WithActionsReport enables the report of the outcome of the actions performed in response to a poll
+// with the next poll request.
+func WithActionsReport(report bool) Option {
+	return func(options *pollServiceOptions) {
+		options.config.ReportActions = report
+	}
+}
+
+// WithPollOverlapMode sets whether the polls requested while a poll is in progress are skipped (skip)
+// or queued (queue).
+func WithPollOverlapMode(mode string) Option {
+	return func(options *pollServiceOptions) {
+		options.config.PollOverlap = mode
+	}
+}
+
+// WithNetworkCheck enables the skipping of the polls while the network is unavailable.
+func WithNetworkCheck(check bool) Option {
+	return func(options *pollServiceOptions) {
+		options.config.NetworkCheck = check
+	}
+}
+
+// WithTunnelServerCheck sets the interval between the reachability checks of the tunnel server, 0 disables the checks.
+func WithTunnelServerCheck(interval time.Duration) Option {
+	return func(options *pollServiceOptions) {
+		options.config.TunnelServerCheck = interval
+	}
+}
+
+// WithDeregistrationMode sets whether polling continues (ignore) or stops (stop) when the Portainer instance rejects
+// the Edge ID, the match is the text that the body of the response must contain, if any.
+func WithDeregistrationMode(mode, match string) Option {
+	return func(options *pollServiceOptions) {
+		options.config.DeregistrationMode = mode
+		options.config.DeregistrationMatch = match
+	}
+}
+
+// WithSignedReports enables the signature of the data reported with each poll request.
+func WithSignedReports(sign bool) Option {
+	return func(options *pollServiceOptions) {
+		options.config.SignReports = sign
+	}
+}
+
+// WithOpenTunnelFactor sets the factor applied to the checkin interval sent by the Portainer instance
+// while the tunnel is open.
+func WithOpenTunnelFactor(factor float64) Option {
+	return func(options *pollServiceOptions) {
+		options.config.OpenTunnelFactor = factor
+	}
+}
+
+// WithTunnelSessionHistory sets the number of recent tunnel sessions exposed in the status, 0 disables the history.
+func WithTunnelSessionHistory(size int) Option {
+	return func(options *pollServiceOptions) {
+		options.config.TunnelSessionHistory = size
+	}
+}
+
+// WithPollWarmup sets the duration of the warmup window during which the poll failures are reported with
+// a reduced severity, 0 disables the warmup window.
+func WithPollWarmup(warmup time.Duration) Option {
+	return func(options *pollServiceOptions) {
+		options.config.PollWarmup = warmup
+	}
+}
+
+// WithLogsCollectionCondition sets the condition on the last run of a schedule for its logs to be collected:
+// always, on-failure or on-slow, the threshold is the duration after which a run is considered slow.
+func WithLogsCollectionCondition(condition string, slowThreshold time.Duration) Option {
+	return func(options *pollServiceOptions) {
+		options.config.LogsCondition = condition
+		options.config.LogsSlowThreshold = slowThreshold
+	}
+}
+
+// WithPollTransport sets the transport used to retrieve the status of the Edge endpoint, HTTP polling is used by default.
+func WithPollTransport(transport string) Option {
+	return func(options *pollServiceOptions) {
+		options.config.PollTransport = transport
+	}
+}
+
+// WithResponseFreshness sets the maximum age of a poll response based on its Date header and the tolerated clock skew,
+// a maximum age of 0 disables the check.
+func WithResponseFreshness(maxAge, clockSkew time.Duration) Option {
+	return func(options *pollServiceOptions) {
+		options.config.ResponseMaxAge = maxAge
+		options.config.ResponseClockSkew = clockSkew
+	}
+}
+
+// WithTunnelPollOutageTimeout sets the duration after which an open tunnel is shut down when the Portainer instance
+// cannot be polled, 0 keeps the tunnel open during poll outages.
+func WithTunnelPollOutageTimeout(timeout time.Duration) Option {
+	return func(options *pollServiceOptions) {
+		options.config.TunnelOutageTimeout = timeout
+	}
+}
+
+// WithSystemTimeCheck sets the behavior when the system time is clearly wrong at startup: off, warn or wait.
+func WithSystemTimeCheck(check string) Option {
+	return func(options *pollServiceOptions) {
+		options.config.SystemTimeCheck = check
+	}
+}
+
+// WithTLSSettings sets the size of the cache used to resume the TLS sessions, 0 disables the session resumption,
+// and the TLS renegotiation support: never, once or freely.
+func WithTLSSettings(sessionCacheSize int, renegotiation string) Option {
+	return func(options *pollServiceOptions) {
+		options.config.TLSSessionCacheSize = sessionCacheSize
+		options.config.TLSRenegotiation = renegotiation
+	}
+}
+
+// WithOperationMode sets the overall posture of the agent: full, tunnel-only, stacks-only, observer or maintenance.
+func WithOperationMode(mode string) Option {
+	return func(options *pollServiceOptions) {
+		options.config.OperationMode = mode
+	}
+}
+
+// WithPollJitter applies a random jitter of up to plus or minus the specified ratio of the poll interval to each poll.
+func WithPollJitter(ratio float64) Option {
+	return func(options *pollServiceOptions) {
+		options.config.PollJitter = ratio
+	}
+}
+
+// WithPollBackoff doubles the poll interval after each consecutive poll failure, up to the specified maximum.
+func WithPollBackoff(max time.Duration) Option {
+	return func(options *pollServiceOptions) {
+		options.config.PollBackoffMax = max
+	}
+}
+
+// WithAsyncMode enables the async Edge mode, a snapshot produced by the producer is pushed to the Portainer instance
+// at the specified interval and the commands received in return are executed.
+func WithAsyncMode(interval time.Duration, producer agent.SnapshotProducer) Option {
+	return func(options *pollServiceOptions) {
+		options.config.AsyncInterval = interval
+		options.config.SnapshotProducer = producer
+	}
+}
+
+// WithPollCompression enables or disables the gzip compression of the request bodies sent to the Portainer instance,
+// the bodies are only compressed once the Portainer instance advertises support for it. It is disabled by default.
+func WithPollCompression(enabled bool) Option {
+	return func(options *pollServiceOptions) {
+		options.config.PollCompression = enabled
+	}
+}
+
+// WithPollCAFiles sets a comma separated list of PEM files containing the certificate authorities trusted in addition
+// to the system trust store when connecting to the Portainer instance.
+func WithPollCAFiles(files string) Option {
+	return func(options *pollServiceOptions) {
+		options.config.PollCAFiles = files
+	}
+}
+
+// WithClientCertificate sets the client certificate presented to the Portainer instance, either a PEM certificate
+// and key pair or a PKCS#12 bundle protected by the password. The certificate is reloaded when its files change
+// on disk if reload is set.
+func WithClientCertificate(certFile, keyFile, password string, reload bool) Option {
+	return func(options *pollServiceOptions) {
+		options.config.ClientCert = certFile
+		options.config.ClientKey = keyFile
+		options.config.ClientCertPassword = password
+		options.config.ClientCertReload = reload
+	}
+}
+
+// WithProxyURL sets the URL of the proxy used to reach the Portainer instance and the tunnel server, the proxy is
+// selected from the environment variables by default.
+func WithProxyURL(proxyURL string) Option {
+	return func(options *pollServiceOptions) {
+		options.config.ProxyURL = proxyURL
+	}
+}
+
+// WithSOCKS5Proxy sets the address of the SOCKS5 proxy used to reach the Portainer instance and the tunnel server,
+// the credentials are optional.
+func WithSOCKS5Proxy(addr, username, password string) Option {
+	return func(options *pollServiceOptions) {
+		options.config.SOCKS5Addr = addr
+		options.config.SOCKS5Username = username
+		options.config.SOCKS5Password = password
+	}
+}
+
+// WithFailbackInterval sets the interval at which a failed Portainer instance listed before the active one is
+// probed to fail back to it, when a comma separated list of Portainer instance URLs is used.
+func WithFailbackInterval(interval time.Duration) Option {
+	return func(options *pollServiceOptions) {
+		options.config.FailbackInterval = interval
+	}
+}
+
+// WithReportQueue sets the queue replayed after each successful poll, the same queue must be set on the stack
+// and logs managers so that their reports are buffered while the Portainer instance is unreachable.
+func WithReportQueue(queue *client.ReportQueue) Option {
+	return func(options *pollServiceOptions) {
+		options.config.ReportQueue = queue
+	}
+}
+
+// WithResponseSigningKey sets the Ed25519 or ECDSA public key, PEM or base64 encoded, used to verify the signature
+// of the poll responses.
+func WithResponseSigningKey(key string) Option {
+	return func(options *pollServiceOptions) {
+		options.config.ResponseSigningKey = key
+	}
+}
+
+// WithLocalLivenessCheck sets the number of consecutive failed checks of the local address targeted by the tunnel
+// after which an open tunnel is closed, 0 disables the check.
+func WithLocalLivenessCheck(failures int) Option {
+	return func(options *pollServiceOptions) {
+		options.config.LocalLivenessFailures = failures
+	}
+}
+
+// WithUnexpectedCredentials sets the behavior when tunnel credentials are received while the tunnel is not
+// required (ignore, warn or cache).
+func WithUnexpectedCredentials(mode string) Option {
+	return func(options *pollServiceOptions) {
+		options.config.UnexpectedCredentials = mode
+	}
+}
+
+// WithTunnelMaxStagger sets the maximum random delay applied before opening a tunnel requested by the Portainer instance.
+func WithTunnelMaxStagger(maxStagger time.Duration) Option {
+	return func(options *pollServiceOptions) {
+		options.config.TunnelMaxStagger = maxStagger
+	}
+}
+
+// WithTunnelStateSource sets the source trusted when the tracked tunnel state and the state reported by
+// the tunnel client differ (agent or client).
+func WithTunnelStateSource(source string) Option {
+	return func(options *pollServiceOptions) {
+		options.config.TunnelStateSource = source
+	}
+}
@@ -0,0 +1,59 @@
+package edge
+
+import (
+	"log"
+	"time"
+)
+
+// recordPollSuccess records the time of the last successful poll, used to measure the poll outages.
+func (service *PollService) recordPollSuccess(now time.Time) {
+	service.statusMu.Lock()
+	service.status.LastSuccessfulPoll = now
+	service.statusMu.Unlock()
+}
+
+// checkPollOutage closes the open tunnel once the Portainer instance could not be polled for the outage timeout,
+// so that the tunnel is not left open while the control plane is unreachable. It returns false when the tunnel
+// was closed.
+func (service *PollService) checkPollOutage(now time.Time) bool {
+	service.statusMu.Lock()
+	lastSuccessfulPoll := service.status.LastSuccessfulPoll
+	service.statusMu.Unlock()
+
+	if lastSuccessfulPoll.IsZero() {
+		return true
+	}
+
+	outage := now.Sub(lastSuccessfulPoll)
+	if outage <= service.tunnelOutageTimeout {
+		return true
+	}
+
+	log.Printf("[WARN] [edge] [outage_seconds: %f] [message: the Portainer instance could not be polled for the outage timeout, shutting down tunnel]", outage.Seconds())
+
+	err := service.closeTunnel(tunnelCloseReasonPollOutage)
+	if err != nil {
+		log.Printf("[ERROR] [edge] [message: unable to shutdown tunnel] [error: %s]", err)
+	}
+
+	return false
+}
+
+// replayReports sends the reports queued while the Portainer instance was unreachable, in the background so that
+// the poll loop is not delayed. The HTTP client is captured as it is replaced by the poll loop.
+func (service *PollService) replayReports() {
+	if service.reportQueue == nil || service.reportQueue.Len() == 0 {
+		return
+	}
+
+	httpClient := service.httpClient
+	serverAddress := service.replicas.activeURL()
+	compress := service.compressRequests()
+
+	go func() {
+		err := service.reportQueue.Replay(httpClient, serverAddress, compress)
+		if err != nil {
+			log.Printf("[WARN] [edge] [queued_reports: %d] [message: unable to replay the queued reports] [error: %s]", service.reportQueue.Len(), err)
+		}
+	}()
+}
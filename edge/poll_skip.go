@@ -0,0 +1,40 @@
+package edge
+
+import (
+	"log"
+	"time"
+)
+
+const (
+	// pollSkipOffline is recorded when the poll is skipped because the network is unavailable
+	pollSkipOffline = "offline"
+	// pollSkipBackoff is recorded when the poll is skipped because the agent is backing off
+	pollSkipBackoff = "backoff"
+	// pollSkipPaused is recorded when an immediate poll is requested while the polling is paused
+	pollSkipPaused = "paused"
+	// pollSkipOverlap is recorded when the poll is skipped because a poll is already in progress
+	pollSkipOverlap = "overlap"
+	// pollSkipNoChange is recorded when the reconciliation is skipped because no new status was retrieved
+	pollSkipNoChange = "no-change"
+)
+
+// PollSkip describes the last poll that was skipped.
+type PollSkip struct {
+	Reason string
+	Time   time.Time
+}
+
+// recordPollSkip records a skipped poll in the status and in the metrics, with a counter per reason.
+func (service *PollService) recordPollSkip(reason string) {
+	log.Printf("[DEBUG] [edge] [reason: %s] [message: skipping the short poll]", reason)
+
+	service.statusMu.Lock()
+	if service.status.SkippedPolls == nil {
+		service.status.SkippedPolls = map[string]uint64{}
+	}
+	service.status.SkippedPolls[reason]++
+	service.status.LastPollSkip = PollSkip{Reason: reason, Time: time.Now()}
+	service.statusMu.Unlock()
+
+	service.metrics.IncrCounter(metricPollSkippedPrefix + reason)
+}
@@ -0,0 +1,39 @@
+package edge
+
+import (
+	"fmt"
+	"log"
+)
+
+const megabyte = 1024 * 1024
+
+// resourceThresholds are the minimum amounts of resources, in megabytes, that must be available on the host
+// before the agent can collect logs. A zero value disables the associated check.
+type resourceThresholds struct {
+	MinDiskFreeMB   uint64
+	MinMemoryFreeMB uint64
+}
+
+// checkResourcePressure returns an error describing the resource under pressure when the host
+// does not have enough resources available. Failing to probe a resource is not considered as pressure.
+func checkResourcePressure(thresholds resourceThresholds) error {
+	if thresholds.MinDiskFreeMB > 0 {
+		diskFree, err := availableDiskSpace()
+		if err != nil {
+			log.Printf("[DEBUG] [edge] [message: unable to retrieve available disk space] [error: %s]", err)
+		} else if diskFree < thresholds.MinDiskFreeMB*megabyte {
+			return fmt.Errorf("available disk space (%d MB) is below the threshold of %d MB", diskFree/megabyte, thresholds.MinDiskFreeMB)
+		}
+	}
+
+	if thresholds.MinMemoryFreeMB > 0 {
+		memoryFree, err := availableMemory()
+		if err != nil {
+			log.Printf("[DEBUG] [edge] [message: unable to retrieve available memory] [error: %s]", err)
+		} else if memoryFree < thresholds.MinMemoryFreeMB*megabyte {
+			return fmt.Errorf("available memory (%d MB) is below the threshold of %d MB", memoryFree/megabyte, thresholds.MinMemoryFreeMB)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,136 @@
+package edge
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// pollLoopStuckThreshold is the duration after which a poll that did not complete is considered stuck,
+// the poll loop is then reported as not alive.
+const pollLoopStuckThreshold = 10 * time.Minute
+
+var (
+	errPollLoopNotRunning = errors.New("the poll loop is not running")
+	errNotConverged       = errors.New("the agent did not converge to the state requested by the Portainer instance yet")
+	errDeregistered       = errors.New("the Edge ID was rejected by the Portainer instance")
+)
+
+// pollLoopProbe tracks whether the poll loop is running and since when the current poll is executing,
+// it is used to report the liveness of the poll service.
+type pollLoopProbe struct {
+	mu        sync.Mutex
+	running   bool
+	busy      int
+	busySince time.Time
+}
+
+func (probe *pollLoopProbe) setRunning(running bool) {
+	probe.mu.Lock()
+	probe.running = running
+	probe.mu.Unlock()
+}
+
+// enter records the start of a poll, exit must be called once the poll completed.
+func (probe *pollLoopProbe) enter(now time.Time) {
+	probe.mu.Lock()
+	if probe.busy == 0 {
+		probe.busySince = now
+	}
+	probe.busy++
+	probe.mu.Unlock()
+}
+
+func (probe *pollLoopProbe) exit() {
+	probe.mu.Lock()
+	probe.busy--
+	if probe.busy == 0 {
+		probe.busySince = time.Time{}
+	}
+	probe.mu.Unlock()
+}
+
+func (probe *pollLoopProbe) check(now time.Time) error {
+	probe.mu.Lock()
+	defer probe.mu.Unlock()
+
+	if !probe.running {
+		return errPollLoopNotRunning
+	}
+
+	if probe.busy > 0 && now.Sub(probe.busySince) > pollLoopStuckThreshold {
+		return fmt.Errorf("the current poll is executing since %s", now.Sub(probe.busySince).Round(time.Second))
+	}
+
+	return nil
+}
+
+// trackConvergence records the first time the agent converged to the state requested by the Portainer instance,
+// that is when the last poll response was fully reconciled and no stack is waiting to be deployed, updated or removed.
+func (service *PollService) trackConvergence(now time.Time) {
+	if !service.lastReconcileComplete {
+		return
+	}
+
+	service.statusMu.Lock()
+	converged := !service.status.ConvergedAt.IsZero()
+	service.statusMu.Unlock()
+
+	if converged {
+		return
+	}
+
+	if service.edgeStackManager != nil && service.operationMode.stacks && !service.edgeStackManager.Converged() {
+		return
+	}
+
+	log.Println("[DEBUG] [edge] [message: agent converged to the state requested by the Portainer instance]")
+
+	service.statusMu.Lock()
+	service.status.ConvergedAt = now
+	service.statusMu.Unlock()
+}
+
+// liveness returns an error when the poll loop is not running or is stuck.
+func (service *PollService) liveness() error {
+	return service.loopProbe.check(time.Now())
+}
+
+// readiness returns an error until the Portainer instance was successfully polled and the agent converged
+// to the requested state at least once.
+func (service *PollService) readiness() error {
+	service.statusMu.Lock()
+	defer service.statusMu.Unlock()
+
+	if service.status.Deregistered {
+		return errDeregistered
+	}
+
+	if service.status.ConvergedAt.IsZero() {
+		return errNotConverged
+	}
+
+	return nil
+}
+
+// Liveness returns an error when the Edge manager is not started or its poll loop is not running anymore.
+// It is meant to be used by the container orchestrator to decide whether the agent must be restarted.
+func (manager *Manager) Liveness() error {
+	if manager.pollService == nil {
+		return errManagerNotStarted
+	}
+
+	return manager.pollService.liveness()
+}
+
+// Readiness returns an error until the agent successfully polled the Portainer instance and converged
+// to the requested state at least once.
+func (manager *Manager) Readiness() error {
+	if manager.pollService == nil {
+		return errManagerNotStarted
+	}
+
+	return manager.pollService.readiness()
+}
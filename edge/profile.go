@@ -0,0 +1,79 @@
+package edge
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime/pprof"
+	"time"
+)
+
+// maxProfileSize is the maximum size in bytes of a captured profile.
+const maxProfileSize = 32 * 1024 * 1024
+
+var errProfileTooLarge = errors.New("profile exceeds the maximum allowed size")
+
+// limitedWriter fails once more than limit bytes have been written.
+type limitedWriter struct {
+	writer  io.Writer
+	limit   int64
+	written int64
+}
+
+func (w *limitedWriter) Write(p []byte) (int, error) {
+	if w.written+int64(len(p)) > w.limit {
+		return 0, errProfileTooLarge
+	}
+
+	n, err := w.writer.Write(p)
+	w.written += int64(n)
+	return n, err
+}
+
+// CaptureProfiles writes a goroutine and a heap profile of the agent process inside the specified folder
+// and returns the paths of the created files.
+func CaptureProfiles(folder string) ([]string, error) {
+	err := os.MkdirAll(folder, 0700)
+	if err != nil {
+		return nil, err
+	}
+
+	timestamp := time.Now().UTC().Format("20060102T150405Z")
+	paths := []string{}
+
+	for _, profileName := range []string{"goroutine", "heap"} {
+		path := filepath.Join(folder, fmt.Sprintf("%s-%s.pprof", timestamp, profileName))
+
+		err := writeProfile(profileName, path)
+		if err != nil {
+			return paths, fmt.Errorf("unable to capture %s profile: %w", profileName, err)
+		}
+
+		paths = append(paths, path)
+	}
+
+	return paths, nil
+}
+
+func writeProfile(profileName, path string) error {
+	profile := pprof.Lookup(profileName)
+	if profile == nil {
+		return fmt.Errorf("unknown profile %s", profileName)
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+
+	err = profile.WriteTo(&limitedWriter{writer: file, limit: maxProfileSize}, 0)
+	closeErr := file.Close()
+	if err != nil {
+		os.Remove(path)
+		return err
+	}
+
+	return closeErr
+}
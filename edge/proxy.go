@@ -0,0 +1,105 @@
+package edge
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// parseProxyURL parses the URL of the proxy used to reach the Portainer instance, the credentials of a proxy
+// requiring basic authentication are specified in the URL. It returns nil for an empty value, the proxy is then
+// selected from the HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables.
+func parseProxyURL(value string) (*url.URL, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil, nil
+	}
+
+	proxyURL, err := url.Parse(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy URL: %w", err)
+	}
+
+	switch proxyURL.Scheme {
+	case "http", "https", "socks5":
+	default:
+		return nil, fmt.Errorf("invalid proxy URL scheme %q, expected http, https or socks5", proxyURL.Scheme)
+	}
+
+	if proxyURL.Host == "" {
+		return nil, fmt.Errorf("invalid proxy URL %q, the host is required", proxyURL.Redacted())
+	}
+
+	return proxyURL, nil
+}
+
+// proxy returns the function selecting the proxy of the requests sent to the Portainer instance.
+func (service *PollService) proxy() func(*http.Request) (*url.URL, error) {
+	// The connections are opened by the SOCKS5 dialer, the environment variables are ignored
+	if service.socksDialer != nil {
+		return nil
+	}
+
+	if service.proxyURL != nil {
+		return http.ProxyURL(service.proxyURL)
+	}
+
+	return http.ProxyFromEnvironment
+}
+
+// proxyFor returns the proxy used to reach the specified URL, or nil when the URL is reached directly.
+func (service *PollService) proxyFor(target string) *url.URL {
+	req, err := http.NewRequest(http.MethodGet, target, nil)
+	if err != nil {
+		return nil
+	}
+
+	selectProxy := service.proxy()
+	if selectProxy == nil {
+		return nil
+	}
+
+	proxyURL, err := selectProxy(req)
+	if err != nil {
+		return nil
+	}
+
+	return proxyURL
+}
+
+// tunnelHTTPURL returns the HTTP URL of the tunnel server, used to select the proxy of the tunnel. The address of
+// the tunnel server can be a WebSocket URL or a host and port.
+func tunnelHTTPURL(serverAddr string) string {
+	switch {
+	case strings.HasPrefix(serverAddr, "wss://"):
+		return "https://" + strings.TrimPrefix(serverAddr, "wss://")
+	case strings.HasPrefix(serverAddr, "ws://"):
+		return "http://" + strings.TrimPrefix(serverAddr, "ws://")
+	case strings.Contains(serverAddr, "://"):
+		return serverAddr
+	}
+
+	return "http://" + serverAddr
+}
+
+// logProxy reports the proxy used to reach the Portainer instance, the credentials are redacted.
+func (service *PollService) logProxy() {
+	if service.socksDialer != nil {
+		return
+	}
+
+	proxyURL := service.proxyFor(service.portainerURL)
+	if proxyURL == nil {
+		log.Printf("[DEBUG] [edge] [server_url: %s] [message: the Portainer instance is reached without a proxy]", service.portainerURL)
+		return
+	}
+
+	source := "environment"
+	if service.proxyURL != nil {
+		source = "configuration"
+	}
+
+	log.Printf("[INFO] [edge] [server_url: %s] [proxy_url: %s] [source: %s] [message: the Portainer instance is reached through a proxy]", service.portainerURL, proxyURL.Redacted(), source)
+}
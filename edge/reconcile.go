@@ -0,0 +1,181 @@
+package edge
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/portainer/agent"
+	"github.com/portainer/agent/edge/stack"
+)
+
+const (
+	pollPhaseNetwork        = "network"
+	pollPhaseDecode         = "decode"
+	reconcilePhaseTunnel    = "tunnel"
+	reconcilePhaseSchedules = "schedules"
+	reconcilePhaseLogs      = "logs"
+	reconcilePhaseStacks    = "stacks"
+)
+
+// errReconcileDeadlineExceeded is returned when the reconciliation of a poll response is abandoned
+// because the reconciliation deadline was exceeded.
+var errReconcileDeadlineExceeded = errors.New("poll reconciliation deadline exceeded")
+
+// reconcile applies the state returned by the Portainer instance. Each subsystem is reconciled in its own phase,
+// the remaining phases are abandoned once the context is done and picked up during the next poll.
+func (service *PollService) reconcile(ctx context.Context, responseData *pollStatusResponse) error {
+	err := service.runReconcilePhase(ctx, reconcilePhaseTunnel, func() error {
+		return service.handleStatus(ctx, responseData)
+	})
+	if err != nil {
+		return err
+	}
+
+	// In the async mode, the schedules and the stacks are managed through the async commands
+	if !service.operationMode.schedules || service.async != nil {
+		return service.reconcileStacksPhase(ctx, responseData)
+	}
+
+	var schedules []agent.Schedule
+	err = service.runReconcilePhase(ctx, reconcilePhaseSchedules, func() error {
+		schedules = service.reconcileSchedules(ctx, responseData.Schedules)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	err = service.runReconcilePhase(ctx, reconcilePhaseLogs, func() error {
+		service.reconcileLogs(schedules)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return service.reconcileStacksPhase(ctx, responseData)
+}
+
+func (service *PollService) reconcileStacksPhase(ctx context.Context, responseData *pollStatusResponse) error {
+	if responseData.Stacks == nil || !service.operationMode.stacks || service.async != nil {
+		return nil
+	}
+
+	return service.runReconcilePhase(ctx, reconcilePhaseStacks, func() error {
+		return service.reconcileStacks(ctx, responseData.Stacks)
+	})
+}
+
+func (service *PollService) runReconcilePhase(ctx context.Context, phase string, fn func() error) error {
+	if ctx.Err() != nil {
+		log.Printf("[DEBUG] [edge] [phase: %s] [message: skipping reconciliation phase]", phase)
+		return errReconcileDeadlineExceeded
+	}
+
+	start := time.Now()
+	err := fn()
+	service.recordPhaseDuration(phase, time.Since(start))
+
+	return err
+}
+
+// recordPhaseDuration records the duration of a phase of the current poll.
+func (service *PollService) recordPhaseDuration(phase string, duration time.Duration) {
+	service.phaseTimings[phase] = duration
+	service.metrics.Timing(metricPollPhasePrefix+phase, duration)
+}
+
+// publishPhaseTimings exposes the duration of the phases of the last poll in the status.
+func (service *PollService) publishPhaseTimings() {
+	service.statusMu.Lock()
+	service.status.LastPollTimings = service.phaseTimings
+	service.statusMu.Unlock()
+}
+
+// reconcileSchedules applies the valid schedules and returns them.
+func (service *PollService) reconcileSchedules(ctx context.Context, received []agent.Schedule) []agent.Schedule {
+	schedules, err := validateSchedules(received, service.scheduleValidation)
+	if err != nil {
+		log.Printf("[ERROR] [edge] [schedule_count: %d] [message: rejecting the schedules sent by the Portainer instance, the current schedules are kept] [error: %s]", len(received), err)
+		return nil
+	}
+
+	err = service.scheduleManager.Schedule(ctx, schedules)
+	if err != nil {
+		log.Printf("[ERROR] [edge] [message: an error occurred during schedule management] [err: %s]", err)
+	}
+	service.recordPollAction(pollActionSchedulesApplied, fmt.Sprintf("%d schedules", len(schedules)), err)
+
+	return schedules
+}
+
+// reconcileLogs requests the collection of the logs of the schedules.
+func (service *PollService) reconcileLogs(schedules []agent.Schedule) {
+	logsToCollect := []int{}
+	now := time.Now()
+	for _, schedule := range schedules {
+		if !schedule.CollectLogs {
+			continue
+		}
+
+		inWindow, err := isInLogsCollectionWindow(schedule.LogsCollectionWindow, now)
+		if err != nil {
+			log.Printf("[WARN] [edge] [schedule_id: %d] [message: invalid logs collection window, logs will be collected without restriction] [error: %s]", schedule.ID, err)
+		} else if !inWindow {
+			log.Printf("[DEBUG] [edge] [schedule_id: %d] [window: %s] [message: deferring log collection until the collection window]", schedule.ID, schedule.LogsCollectionWindow)
+			service.deferReconciliation("logs collection window")
+			continue
+		}
+
+		// The condition is evaluated again during the next polls as the schedule runs again
+		if !service.shouldCollectLogs(schedule.ID) {
+			service.deferReconciliation("logs collection condition")
+			continue
+		}
+
+		logsToCollect = append(logsToCollect, schedule.ID)
+	}
+
+	if len(logsToCollect) > 0 {
+		err := checkResourcePressure(service.logsResourceThresholds)
+		if err != nil {
+			log.Printf("[WARN] [edge] [schedule_count: %d] [message: skipping log collection, the host is under resource pressure] [error: %s]", len(logsToCollect), err)
+			logsToCollect = []int{}
+			service.deferReconciliation("resource pressure")
+		}
+	}
+
+	if len(logsToCollect) > 0 {
+		service.recordPollAction(pollActionLogsRequested, fmt.Sprintf("schedules %v", logsToCollect), nil)
+	}
+
+	service.logsManager.HandleReceivedLogsRequests(logsToCollect)
+}
+
+// reconcileStacks updates the status of the Edge stacks.
+func (service *PollService) reconcileStacks(ctx context.Context, stackStatuses []stackStatus) error {
+	stacks := map[int]int{}
+	for _, stack := range stackStatuses {
+		stacks[stack.ID] = stack.Version
+	}
+
+	err := service.edgeStackManager.UpdateStacksStatus(ctx, stacks)
+	if ctx.Err() != nil && errors.Is(err, ctx.Err()) {
+		return errReconcileDeadlineExceeded
+	}
+
+	if errors.Is(err, stack.ErrStackUpdatePostponed) {
+		service.deferReconciliation("stack update postponed")
+		err = nil
+	}
+	service.recordPollAction(pollActionStacksUpdated, fmt.Sprintf("%d stacks", len(stacks)), err)
+	if err != nil {
+		log.Printf("[ERROR] [edge] [message: an error occurred during stack management] [error: %s]", err)
+		return err
+	}
+
+	return nil
+}
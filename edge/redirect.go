@@ -0,0 +1,50 @@
+package edge
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+)
+
+const (
+	// redirectPolicyError fails the request when the Portainer instance answers with a redirect.
+	redirectPolicyError = "error"
+	// redirectPolicyFollow follows the redirects like the default HTTP client (up to 10 redirects).
+	redirectPolicyFollow = "follow"
+	// redirectPolicyLimited follows a limited number of redirects.
+	redirectPolicyLimited = "limited"
+)
+
+func parseRedirectPolicy(policy string) (string, error) {
+	switch policy {
+	case "":
+		return redirectPolicyError, nil
+	case redirectPolicyError, redirectPolicyFollow, redirectPolicyLimited:
+		return policy, nil
+	}
+
+	return "", fmt.Errorf("unsupported redirect policy %q, expected %s, %s or %s", policy, redirectPolicyError, redirectPolicyFollow, redirectPolicyLimited)
+}
+
+// checkRedirect returns the redirect check function associated to the redirect policy, a nil function
+// uses the default behavior of the HTTP client.
+func (service *PollService) checkRedirect() func(req *http.Request, via []*http.Request) error {
+	switch service.redirectPolicy {
+	case redirectPolicyFollow:
+		return nil
+	case redirectPolicyLimited:
+		return func(req *http.Request, via []*http.Request) error {
+			if len(via) > service.maxRedirects {
+				log.Printf("[WARN] [edge] [redirect_url: %s] [max_redirects: %d] [message: too many redirects, blocking redirect]", req.URL, service.maxRedirects)
+				return fmt.Errorf("stopped after %d redirects", service.maxRedirects)
+			}
+
+			return nil
+		}
+	}
+
+	return func(req *http.Request, via []*http.Request) error {
+		log.Printf("[WARN] [edge] [redirect_url: %s] [message: the Portainer instance answered with a redirect, blocking redirect. This usually indicates a misconfiguration of the Portainer instance URL or of a proxy]", req.URL)
+		return fmt.Errorf("redirect to %s blocked by the redirect policy", req.URL)
+	}
+}
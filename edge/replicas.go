@@ -0,0 +1,219 @@
+package edge
+
+import (
+	"fmt"
+	"log"
+	"math/rand"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	replicaSelectionRoundRobin = "round-robin"
+	replicaSelectionRandom     = "random"
+	// replicaSelectionFailover is used when the Edge key lists several Portainer instances, the first healthy
+	// instance in the list is always preferred
+	replicaSelectionFailover = "failover"
+
+	// replicaUnhealthyThreshold is the number of consecutive failures after which a replica is considered
+	// unhealthy. Unhealthy replicas are only selected when no healthy replica is available.
+	replicaUnhealthyThreshold = 3
+)
+
+// PollReplica is a Portainer instance replica that can be used to poll the status of the Edge endpoint.
+type PollReplica struct {
+	URL                 string
+	ConsecutiveFailures int
+	// Active is true for the Portainer instance currently used when failing over between Portainer instances
+	Active      bool
+	lastFailure time.Time
+}
+
+// replicaSelector selects the Portainer instance replica used for each poll request in order to spread the load
+// across replicas. Replicas that keep failing are avoided until they answer successfully again.
+type replicaSelector struct {
+	mu               sync.Mutex
+	replicas         []*PollReplica
+	strategy         string
+	next             int
+	random           *rand.Rand
+	active           *PollReplica
+	failbackInterval time.Duration
+}
+
+// newReplicaSelector returns a selector for the Portainer instance URL and the comma separated list of additional replica URLs.
+func newReplicaSelector(portainerURL, replicaURLs, strategy string) (*replicaSelector, error) {
+	switch strategy {
+	case "":
+		strategy = replicaSelectionRoundRobin
+	case replicaSelectionRoundRobin, replicaSelectionRandom:
+	default:
+		return nil, fmt.Errorf("unsupported replica selection strategy %q, expected %s or %s", strategy, replicaSelectionRoundRobin, replicaSelectionRandom)
+	}
+
+	selector := &replicaSelector{
+		replicas: []*PollReplica{{URL: portainerURL}},
+		strategy: strategy,
+		random:   rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+	selector.active = selector.replicas[0]
+
+	for _, entry := range strings.Split(replicaURLs, ",") {
+		entry = strings.TrimRight(strings.TrimSpace(entry), "/")
+		if entry == "" || entry == portainerURL {
+			continue
+		}
+
+		replicaURL, err := url.Parse(entry)
+		if err != nil || replicaURL.Scheme == "" || replicaURL.Host == "" {
+			return nil, fmt.Errorf("invalid replica URL %q", entry)
+		}
+
+		selector.replicas = append(selector.replicas, &PollReplica{URL: entry})
+	}
+
+	return selector, nil
+}
+
+// newFailoverSelector returns a selector failing over between the specified Portainer instance URLs, ordered by
+// preference. A failed instance listed before the active one is probed every failback interval to fail back to it.
+func newFailoverSelector(portainerURLs []string, failbackInterval time.Duration) (*replicaSelector, error) {
+	if failbackInterval <= 0 {
+		return nil, fmt.Errorf("invalid failback interval %s, must be positive", failbackInterval)
+	}
+
+	selector := &replicaSelector{
+		strategy:         replicaSelectionFailover,
+		failbackInterval: failbackInterval,
+	}
+
+	for _, entry := range portainerURLs {
+		portainerURL, err := url.Parse(entry)
+		if err != nil || portainerURL.Scheme == "" || portainerURL.Host == "" {
+			return nil, fmt.Errorf("invalid Portainer instance URL %q", entry)
+		}
+
+		selector.replicas = append(selector.replicas, &PollReplica{URL: entry})
+	}
+	selector.active = selector.replicas[0]
+
+	return selector, nil
+}
+
+// splitPortainerURLs returns the URLs of the comma separated list of Portainer instance URLs, duplicates are ignored.
+func splitPortainerURLs(value string) []string {
+	portainerURLs := []string{}
+	seen := map[string]bool{}
+
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimRight(strings.TrimSpace(entry), "/")
+		if entry == "" || seen[entry] {
+			continue
+		}
+
+		seen[entry] = true
+		portainerURLs = append(portainerURLs, entry)
+	}
+
+	return portainerURLs
+}
+
+// primaryPortainerURL returns the first URL of the comma separated list of Portainer instance URLs.
+func primaryPortainerURL(value string) string {
+	portainerURLs := splitPortainerURLs(value)
+	if len(portainerURLs) == 0 {
+		return value
+	}
+
+	return portainerURLs[0]
+}
+
+// selectReplica returns the replica that must be used for the next poll request.
+func (selector *replicaSelector) selectReplica() *PollReplica {
+	selector.mu.Lock()
+	defer selector.mu.Unlock()
+
+	if selector.strategy == replicaSelectionFailover {
+		return selector.selectFailoverReplica()
+	}
+
+	candidates := []*PollReplica{}
+	for _, replica := range selector.replicas {
+		if replica.ConsecutiveFailures < replicaUnhealthyThreshold {
+			candidates = append(candidates, replica)
+		}
+	}
+
+	if len(candidates) == 0 {
+		candidates = selector.replicas
+	}
+
+	if selector.strategy == replicaSelectionRandom {
+		return candidates[selector.random.Intn(len(candidates))]
+	}
+
+	replica := candidates[selector.next%len(candidates)]
+	selector.next++
+
+	return replica
+}
+
+// selectFailoverReplica returns the first healthy Portainer instance in order of preference. A failed instance is
+// selected again once the failback interval has elapsed since its last failure, to probe it.
+// It must be called with the lock held.
+func (selector *replicaSelector) selectFailoverReplica() *PollReplica {
+	now := time.Now()
+
+	for _, replica := range selector.replicas {
+		if replica.ConsecutiveFailures < replicaUnhealthyThreshold || now.Sub(replica.lastFailure) >= selector.failbackInterval {
+			return replica
+		}
+	}
+
+	return selector.active
+}
+
+// recordResult updates the health of a replica based on the outcome of a poll request.
+func (selector *replicaSelector) recordResult(replica *PollReplica, success bool) {
+	selector.mu.Lock()
+	defer selector.mu.Unlock()
+
+	if !success {
+		replica.ConsecutiveFailures++
+		replica.lastFailure = time.Now()
+		return
+	}
+
+	replica.ConsecutiveFailures = 0
+
+	if selector.strategy == replicaSelectionFailover && replica != selector.active {
+		log.Printf("[INFO] [edge] [previous_server_url: %s] [server_url: %s] [message: switching to another Portainer instance]", selector.active.URL, replica.URL)
+		selector.active = replica
+	}
+}
+
+// activeURL returns the URL of the Portainer instance used by the requests that are not spread across replicas.
+// When failing over between Portainer instances, it is the last instance that answered successfully.
+func (selector *replicaSelector) activeURL() string {
+	selector.mu.Lock()
+	defer selector.mu.Unlock()
+
+	return selector.active.URL
+}
+
+// snapshot returns a copy of the replicas and their health.
+func (selector *replicaSelector) snapshot() []PollReplica {
+	selector.mu.Lock()
+	defer selector.mu.Unlock()
+
+	replicas := make([]PollReplica, 0, len(selector.replicas))
+	for _, replica := range selector.replicas {
+		snapshot := *replica
+		snapshot.Active = selector.strategy == replicaSelectionFailover && replica == selector.active
+		replicas = append(replicas, snapshot)
+	}
+
+	return replicas
+}
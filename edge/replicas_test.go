@@ -0,0 +1,54 @@
+package edge
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFailoverSelectorFailsOverAndFailsBack(t *testing.T) {
+	selector, err := newFailoverSelector([]string{"https://primary.example.com", "https://secondary.example.com"}, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	primary := selector.selectReplica()
+	if primary.URL != "https://primary.example.com" {
+		t.Fatalf("expected the primary instance to be selected first, got %s", primary.URL)
+	}
+
+	for i := 0; i < replicaUnhealthyThreshold; i++ {
+		selector.recordResult(selector.selectReplica(), false)
+	}
+
+	secondary := selector.selectReplica()
+	if secondary.URL != "https://secondary.example.com" {
+		t.Fatalf("expected a failover to the secondary instance, got %s", secondary.URL)
+	}
+
+	if selector.activeURL() != "https://primary.example.com" {
+		t.Errorf("expected the active instance to change only once the secondary instance answered, got %s", selector.activeURL())
+	}
+
+	selector.recordResult(secondary, true)
+	if selector.activeURL() != "https://secondary.example.com" {
+		t.Errorf("expected the secondary instance to be active, got %s", selector.activeURL())
+	}
+
+	if replica := selector.selectReplica(); replica != secondary {
+		t.Errorf("expected the failed primary instance not to be probed before the failback interval, got %s", replica.URL)
+	}
+
+	selector.mu.Lock()
+	primary.lastFailure = time.Now().Add(-2 * time.Minute)
+	selector.mu.Unlock()
+
+	probed := selector.selectReplica()
+	if probed != primary {
+		t.Fatalf("expected the primary instance to be probed once the failback interval elapsed, got %s", probed.URL)
+	}
+
+	selector.recordResult(probed, true)
+	if selector.activeURL() != "https://primary.example.com" {
+		t.Errorf("expected a failback to the primary instance, got %s", selector.activeURL())
+	}
+}
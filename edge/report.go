@@ -0,0 +1,120 @@
+package edge
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/portainer/agent"
+)
+
+const (
+	// capabilityReportDelta is advertised by the agent and the Portainer instance when they support
+	// status reports that only contain the fields that changed since the previous report.
+	capabilityReportDelta = "report-delta"
+
+	reportTypeFull  = "full"
+	reportTypeDelta = "delta"
+)
+
+// statusReport contains the state reported by the agent to the Portainer instance with each poll request.
+type statusReport map[string]interface{}
+
+// reportBuilder builds the status reports sent with the poll requests. When the Portainer instance supports it,
+// only the fields that changed since the last acknowledged report are sent, a full report is still sent
+// periodically so that both sides can resync.
+type reportBuilder struct {
+	fullReportInterval time.Duration
+	deltaSupported     bool
+	lastSent           statusReport
+	lastFullReport     time.Time
+}
+
+func newReportBuilder(fullReportInterval time.Duration) *reportBuilder {
+	return &reportBuilder{
+		fullReportInterval: fullReportInterval,
+	}
+}
+
+// build returns the payload that must be sent for the current report as well as its type.
+func (builder *reportBuilder) build(current statusReport, now time.Time) (statusReport, string) {
+	if !builder.deltaSupported || builder.lastSent == nil || now.Sub(builder.lastFullReport) >= builder.fullReportInterval {
+		return current, reportTypeFull
+	}
+
+	delta := statusReport{}
+	for key, value := range current {
+		previous, ok := builder.lastSent[key]
+		if !ok || !reflect.DeepEqual(previous, value) {
+			delta[key] = value
+		}
+	}
+
+	for key := range builder.lastSent {
+		if _, ok := current[key]; !ok {
+			delta[key] = nil
+		}
+	}
+
+	return delta, reportTypeDelta
+}
+
+// acknowledge records a report that was successfully received by the Portainer instance.
+func (builder *reportBuilder) acknowledge(current statusReport, reportType string, now time.Time) {
+	builder.lastSent = current
+	if reportType == reportTypeFull {
+		builder.lastFullReport = now
+	}
+}
+
+// currentReport returns the state currently reported by the agent.
+func (service *PollService) currentReport() statusReport {
+	status := service.Status()
+
+	scheduleIDs := []int{}
+	for _, schedule := range service.scheduleManager.Schedules() {
+		scheduleIDs = append(scheduleIDs, schedule.ID)
+	}
+
+	return statusReport{
+		"version":           agent.Version,
+		"platform":          int(service.containerPlatform),
+		"tunnelOpen":        status.TunnelOpen,
+		"paused":            status.Paused,
+		"pauseReason":       status.PauseReason,
+		"tunnelCloseReason": status.LastTunnelCloseReason,
+		"scheduleIDs":       scheduleIDs,
+	}
+}
+
+// setReportHeaders adds the status report to the poll request. It returns the report and its type
+// so that it can be acknowledged once the Portainer instance answered the request.
+func (service *PollService) setReportHeaders(req *http.Request, now time.Time) (statusReport, string, error) {
+	current := service.currentReport()
+	payload, reportType := service.reportBuilder.build(current, now)
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, "", err
+	}
+
+	req.Header.Set(agent.HTTPEdgeCapabilitiesHeaderName, capabilityReportDelta)
+	req.Header.Set(agent.HTTPEdgeReportHeaderName, base64.StdEncoding.EncodeToString(data))
+	req.Header.Set(agent.HTTPEdgeReportTypeHeaderName, reportType)
+
+	return current, reportType, nil
+}
+
+// hasCapability returns true when the capability is listed in the capabilities header of the response.
+func hasCapability(resp *http.Response, capability string) bool {
+	for _, value := range strings.Split(resp.Header.Get(agent.HTTPEdgeCapabilitiesHeaderName), ",") {
+		if strings.TrimSpace(value) == capability {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,58 @@
+//go:build !windows
+// +build !windows
+
+package edge
+
+import (
+	"bufio"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/portainer/agent"
+)
+
+const memInfoPath = "/proc/meminfo"
+
+// availableDiskSpace returns the number of bytes available on the host filesystem.
+func availableDiskSpace() (uint64, error) {
+	path := agent.HostRoot
+	if _, err := os.Stat(path); err != nil {
+		path = "/"
+	}
+
+	var stat syscall.Statfs_t
+	err := syscall.Statfs(path, &stat)
+	if err != nil {
+		return 0, err
+	}
+
+	return stat.Bavail * uint64(stat.Bsize), nil
+}
+
+// availableMemory returns the number of bytes of memory available on the host.
+func availableMemory() (uint64, error) {
+	file, err := os.Open(memInfoPath)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 || fields[0] != "MemAvailable:" {
+			continue
+		}
+
+		kilobytes, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			return 0, err
+		}
+
+		return kilobytes * 1024, nil
+	}
+
+	return 0, scanner.Err()
+}
@@ -0,0 +1,16 @@
+//go:build windows
+// +build windows
+
+package edge
+
+import "errors"
+
+var errResourceProbeNotSupported = errors.New("resource probing is not supported on Windows")
+
+func availableDiskSpace() (uint64, error) {
+	return 0, errResourceProbeNotSupported
+}
+
+func availableMemory() (uint64, error) {
+	return 0, errResourceProbeNotSupported
+}
@@ -0,0 +1,128 @@
+package edge
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/portainer/agent"
+	"github.com/portainer/agent/filesystem"
+)
+
+var (
+	errMissingResponseSignature = errors.New("the poll response is not signed")
+	errInvalidResponseSignature = errors.New("invalid poll response signature")
+	errUnsignedNotModified      = errors.New("the Portainer instance answered with an unsigned 304 Not Modified response")
+)
+
+// loadResponseSigningKey returns the public key used to verify the signature of the poll responses. The key file
+// takes precedence over the key embedded in the Edge key. It returns nil when no key is specified.
+func loadResponseSigningKey(keyFile, embeddedKey string) (crypto.PublicKey, error) {
+	source := "edge_key"
+	value := embeddedKey
+
+	if keyFile != "" {
+		data, err := filesystem.ReadFromFile(keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read the response signing key file %s: %w", keyFile, err)
+		}
+
+		source = keyFile
+		value = string(data)
+	}
+
+	if strings.TrimSpace(value) == "" {
+		return nil, nil
+	}
+
+	publicKey, err := parseResponseSigningKey(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid response signing key (%s): %w", source, err)
+	}
+
+	log.Printf("[INFO] [edge] [source: %s] [message: the signature of the poll responses is verified]", source)
+
+	return publicKey, nil
+}
+
+// parseResponseSigningKey parses a PKIX public key, either PEM encoded or base64 encoded DER.
+// Only Ed25519 and ECDSA keys are supported.
+func parseResponseSigningKey(value string) (crypto.PublicKey, error) {
+	var der []byte
+
+	if block, _ := pem.Decode([]byte(value)); block != nil {
+		der = block.Bytes
+	} else {
+		decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(value))
+		if err != nil {
+			return nil, errors.New("the key is neither PEM nor base64 encoded")
+		}
+		der = decoded
+	}
+
+	publicKey, err := x509.ParsePKIXPublicKey(der)
+	if err != nil {
+		return nil, err
+	}
+
+	switch publicKey.(type) {
+	case ed25519.PublicKey, *ecdsa.PublicKey:
+		return publicKey, nil
+	}
+
+	return nil, fmt.Errorf("unsupported key type %T, expected an Ed25519 or ECDSA public key", publicKey)
+}
+
+// verifyResponseSignature checks the signature sent by the Portainer instance with the poll response, when a
+// response signing key is set. The signature covers the Date header and the body of the response, separated by
+// a new line, so that a signed response cannot be replayed once it is no longer fresh. The body of the response
+// is buffered so that it can still be decoded.
+func (service *PollService) verifyResponseSignature(resp *http.Response) error {
+	if service.responseSigningKey == nil {
+		return nil
+	}
+
+	header := resp.Header.Get(agent.HTTPEdgeResponseSignatureHeaderName)
+	if header == "" {
+		return errMissingResponseSignature
+	}
+
+	signature, err := base64.StdEncoding.DecodeString(header)
+	if err != nil {
+		return errInvalidResponseSignature
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	message := append([]byte(resp.Header.Get("Date")+"\n"), body...)
+
+	var valid bool
+	switch publicKey := service.responseSigningKey.(type) {
+	case ed25519.PublicKey:
+		valid = ed25519.Verify(publicKey, message, signature)
+	case *ecdsa.PublicKey:
+		digest := sha256.Sum256(message)
+		valid = ecdsa.VerifyASN1(publicKey, digest[:], signature)
+	}
+
+	if !valid {
+		return errInvalidResponseSignature
+	}
+
+	return nil
+}
@@ -0,0 +1,140 @@
+package edge
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/portainer/agent"
+)
+
+func signResponse(privateKey ed25519.PrivateKey, date string, body []byte) string {
+	return base64.StdEncoding.EncodeToString(ed25519.Sign(privateKey, append([]byte(date+"\n"), body...)))
+}
+
+func TestPushedStatusSignatureIsVerified(t *testing.T) {
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	service := &PollService{responseSigningKey: publicKey}
+	now := time.Now()
+	date := now.UTC().Format(http.TimeFormat)
+	status := `{"status":"REQUIRED","port":8000}`
+	signature := signResponse(privateKey, date, []byte(status))
+
+	tests := []struct {
+		name    string
+		message string
+		wantErr bool
+	}{
+		{name: "valid signature", message: `{"date":"` + date + `","signature":"` + signature + `","status":` + status + `}`},
+		{name: "tampered status", message: `{"date":"` + date + `","signature":"` + signature + `","status":{"status":"REQUIRED","port":9000}}`, wantErr: true},
+		{name: "missing signature", message: `{"date":"` + date + `","status":` + status + `}`, wantErr: true},
+	}
+
+	for _, test := range tests {
+		response, err := service.decodePushedStatus([]byte(test.message), true, now)
+		if (err != nil) != test.wantErr {
+			t.Errorf("%s: unexpected error %v", test.name, err)
+			continue
+		}
+
+		if err == nil && (!response.signatureVerified || response.Port != 8000) {
+			t.Errorf("%s: expected a verified status, got %+v", test.name, response)
+		}
+	}
+}
+
+func TestAsyncResponseSignatureIsVerified(t *testing.T) {
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body := []byte(`{"commands":[]}`)
+
+	tests := []struct {
+		name      string
+		body      []byte
+		signature func(date string) string
+		wantErr   bool
+	}{
+		{name: "valid signature", body: body, signature: func(date string) string { return signResponse(privateKey, date, body) }},
+		{name: "tampered body", body: []byte(`{"commands":[{"id":1,"type":"schedules","payload":[]}]}`), signature: func(date string) string { return signResponse(privateKey, date, body) }, wantErr: true},
+		{name: "missing signature", body: body, signature: func(date string) string { return "" }, wantErr: true},
+	}
+
+	for _, test := range tests {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			date := time.Now().UTC().Format(http.TimeFormat)
+			w.Header().Set("Date", date)
+			if signature := test.signature(date); signature != "" {
+				w.Header().Set(agent.HTTPEdgeResponseSignatureHeaderName, signature)
+			}
+			w.Write(test.body)
+		}))
+
+		replicas, err := newReplicaSelector(server.URL, "", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		service := &PollService{
+			responseSigningKey: publicKey,
+			replicas:           replicas,
+			endpointID:         "1",
+			httpClient:         server.Client(),
+		}
+
+		err = (&asyncService{service: service}).exchange()
+		if (err != nil) != test.wantErr {
+			t.Errorf("%s: unexpected error %v", test.name, err)
+		}
+
+		server.Close()
+	}
+}
+
+func TestSignedResponsesAreNeverCached(t *testing.T) {
+	publicKey, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	der, err := x509.MarshalPKIXPublicKey(publicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var ifNoneMatch string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ifNoneMatch = r.Header.Get("If-None-Match")
+		w.WriteHeader(http.StatusNotModified)
+	}))
+	defer server.Close()
+
+	service := newTestPollService(t, server.URL, WithResponseSigningKey(base64.StdEncoding.EncodeToString(der)))
+
+	if service.responseMaxAge != agent.DefaultEdgeSignedResponseMaxAge {
+		t.Errorf("expected the maximum age of the signed responses to be enforced, got %s", service.responseMaxAge)
+	}
+
+	service.setPollETag(`"v1"`)
+
+	err = service.poll()
+	if !errors.Is(err, errUnsignedNotModified) {
+		t.Errorf("expected the unsigned 304 response to be rejected, got %v", err)
+	}
+
+	if ifNoneMatch != "" {
+		t.Errorf("expected no If-None-Match header with signed responses, got %q", ifNoneMatch)
+	}
+}
@@ -0,0 +1,93 @@
+package edge
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"path/filepath"
+	"strconv"
+
+	"github.com/portainer/agent"
+	"github.com/portainer/agent/filesystem"
+)
+
+const (
+	// exitReasonShutdown is recorded when the agent was shut down after receiving a termination signal
+	exitReasonShutdown = "shutdown"
+	// exitReasonUnclean is reported when the previous agent process exited without being shut down, e.g. after
+	// a crash or when it was killed
+	exitReasonUnclean = "unclean"
+)
+
+// restartState is persisted on disk so that the number of restarts of the agent survives the process.
+type restartState struct {
+	Count          int    `json:"count"`
+	LastExitReason string `json:"lastExitReason"`
+	Running        bool   `json:"running"`
+}
+
+// restartTracker counts the restarts of the agent and records the reason of the last exit, so that the Portainer
+// instance can detect agents that are crash-looping.
+type restartTracker struct {
+	folder string
+	state  restartState
+}
+
+// loadRestartTracker loads the restart state persisted in the folder and records the start of the agent.
+// The counter and the last exit reason are cleared when reset is set.
+func loadRestartTracker(folder string, reset bool) (*restartTracker, error) {
+	tracker := &restartTracker{folder: folder}
+
+	path := filepath.Join(folder, agent.EdgeRestartStateFile)
+	exist, err := filesystem.FileExists(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if exist && !reset {
+		data, err := filesystem.ReadFromFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		err = json.Unmarshal(data, &tracker.state)
+		if err != nil {
+			log.Printf("[WARN] [edge] [path: %s] [message: invalid restart state, the restart count is reset] [error: %s]", path, err)
+			tracker.state = restartState{}
+		} else {
+			tracker.state.Count++
+			if tracker.state.Running {
+				tracker.state.LastExitReason = exitReasonUnclean
+			}
+		}
+	}
+
+	tracker.state.Running = true
+
+	return tracker, tracker.save()
+}
+
+// recordExit persists the reason of the exit of the agent.
+func (tracker *restartTracker) recordExit(reason string) error {
+	tracker.state.Running = false
+	tracker.state.LastExitReason = reason
+
+	return tracker.save()
+}
+
+func (tracker *restartTracker) save() error {
+	data, err := json.Marshal(tracker.state)
+	if err != nil {
+		return err
+	}
+
+	return filesystem.WriteFile(tracker.folder, agent.EdgeRestartStateFile, data, 0644)
+}
+
+// setHeaders adds the restart count and the reason of the last exit to a poll request.
+func (tracker *restartTracker) setHeaders(req *http.Request) {
+	req.Header.Set(agent.HTTPEdgeRestartCountHeaderName, strconv.Itoa(tracker.state.Count))
+	if tracker.state.LastExitReason != "" {
+		req.Header.Set(agent.HTTPEdgeLastExitReasonHeaderName, tracker.state.LastExitReason)
+	}
+}
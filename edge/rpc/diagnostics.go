@@ -0,0 +1,146 @@
+package rpc
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/portainer/agent/edge"
+	agentnet "github.com/portainer/agent/net"
+)
+
+// DiagnosticsServiceName is the fully qualified name of the gRPC diagnostics service.
+const DiagnosticsServiceName = "portainer.agent.edge.Diagnostics"
+
+// Empty is the message used by the methods that do not take nor return any data.
+type Empty struct{}
+
+// SelfTestResponse is the response of the SelfTest method.
+type SelfTestResponse struct {
+	Checks []edge.SelfTestCheck
+}
+
+// DiagnosticsServer exposes the state and the control methods of the Edge manager over gRPC.
+// The messages are encoded in JSON, clients must use the "json" content subtype.
+type DiagnosticsServer struct {
+	grpcServer  *grpc.Server
+	edgeManager *edge.Manager
+}
+
+// NewDiagnosticsServer returns a pointer to a new instance of DiagnosticsServer.
+func NewDiagnosticsServer(edgeManager *edge.Manager) *DiagnosticsServer {
+	server := &DiagnosticsServer{
+		grpcServer:  grpc.NewServer(),
+		edgeManager: edgeManager,
+	}
+
+	server.grpcServer.RegisterService(&diagnosticsServiceDesc, server)
+
+	return server
+}
+
+// Start starts the gRPC server by listening on the specified address. The service exposes control methods
+// without authentication, the address must therefore be a loopback address.
+func (server *DiagnosticsServer) Start(addr string) error {
+	err := checkLoopbackAddr(addr)
+	if err != nil {
+		return err
+	}
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	return server.grpcServer.Serve(listener)
+}
+
+// StartOnSocket starts the gRPC server by listening on a Unix socket created at the specified path
+// with the specified file permissions. A stale socket left at the path is removed.
+func (server *DiagnosticsServer) StartOnSocket(socketPath string, mode os.FileMode) error {
+	err := os.Remove(socketPath)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	listener, err := net.Listen("unix", socketPath)
+	if err != nil {
+		return err
+	}
+
+	err = os.Chmod(socketPath, mode)
+	if err != nil {
+		listener.Close()
+		return err
+	}
+
+	return server.grpcServer.Serve(listener)
+}
+
+// checkLoopbackAddr returns an error when the address (in the HOST:PORT format) is not bound to a loopback interface.
+func checkLoopbackAddr(addr string) error {
+	loopback, err := agentnet.IsLoopbackAddr(addr)
+	if err != nil {
+		return err
+	}
+
+	if !loopback {
+		return fmt.Errorf("refusing to expose the Edge diagnostics gRPC service on %s, only loopback addresses and Unix sockets are allowed", addr)
+	}
+
+	return nil
+}
+
+// Shutdown stops the server once the pending calls are completed.
+func (server *DiagnosticsServer) Shutdown() {
+	server.grpcServer.GracefulStop()
+}
+
+// GetStatus returns a snapshot of the state of the poll service.
+func (server *DiagnosticsServer) GetStatus(ctx context.Context, request *Empty) (*edge.PollServiceStatus, error) {
+	status := server.edgeManager.Status()
+	if status == nil {
+		return nil, unavailable("Edge manager is not started")
+	}
+
+	return status, nil
+}
+
+// TriggerPoll requests an immediate poll of the Portainer instance.
+func (server *DiagnosticsServer) TriggerPoll(ctx context.Context, request *Empty) (*Empty, error) {
+	err := server.edgeManager.TriggerPoll()
+	if err != nil {
+		return nil, unavailable(err.Error())
+	}
+
+	return &Empty{}, nil
+}
+
+// CloseTunnels closes the reverse tunnel if it is open.
+func (server *DiagnosticsServer) CloseTunnels(ctx context.Context, request *Empty) (*Empty, error) {
+	err := server.edgeManager.CloseTunnels()
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return &Empty{}, nil
+}
+
+// SelfTest checks the connectivity of the agent with the Portainer instance.
+func (server *DiagnosticsServer) SelfTest(ctx context.Context, request *Empty) (*SelfTestResponse, error) {
+	checks, err := server.edgeManager.SelfTest()
+	if err != nil {
+		return nil, unavailable(err.Error())
+	}
+
+	return &SelfTestResponse{Checks: checks}, nil
+}
+
+func unavailable(message string) error {
+	return status.Error(codes.Unavailable, message)
+}
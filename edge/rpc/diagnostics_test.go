@@ -0,0 +1,26 @@
+package rpc
+
+import "testing"
+
+func TestCheckLoopbackAddr(t *testing.T) {
+	tests := []struct {
+		addr    string
+		wantErr bool
+	}{
+		{addr: "127.0.0.1:9005"},
+		{addr: "[::1]:9005"},
+		{addr: "localhost:9005"},
+		{addr: ":9005", wantErr: true},
+		{addr: "0.0.0.0:9005", wantErr: true},
+		{addr: "192.168.1.10:9005", wantErr: true},
+		{addr: "portainer.example.com:9005", wantErr: true},
+		{addr: "127.0.0.1", wantErr: true},
+	}
+
+	for _, test := range tests {
+		err := checkLoopbackAddr(test.addr)
+		if (err != nil) != test.wantErr {
+			t.Errorf("checkLoopbackAddr(%q) error = %v, want error: %t", test.addr, err, test.wantErr)
+		}
+	}
+}
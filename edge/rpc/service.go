@@ -0,0 +1,77 @@
+package rpc
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+
+	"github.com/portainer/agent/edge"
+)
+
+// diagnosticsService is the interface of the gRPC diagnostics service, it is written by hand as the messages
+// are plain Go structures encoded in JSON rather than generated from protocol buffers.
+type diagnosticsService interface {
+	GetStatus(ctx context.Context, request *Empty) (*edge.PollServiceStatus, error)
+	TriggerPoll(ctx context.Context, request *Empty) (*Empty, error)
+	CloseTunnels(ctx context.Context, request *Empty) (*Empty, error)
+	SelfTest(ctx context.Context, request *Empty) (*SelfTestResponse, error)
+}
+
+var diagnosticsServiceDesc = grpc.ServiceDesc{
+	ServiceName: DiagnosticsServiceName,
+	HandlerType: (*diagnosticsService)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetStatus",
+			Handler: unaryHandler("GetStatus", func(service diagnosticsService, ctx context.Context, request *Empty) (interface{}, error) {
+				return service.GetStatus(ctx, request)
+			}),
+		},
+		{
+			MethodName: "TriggerPoll",
+			Handler: unaryHandler("TriggerPoll", func(service diagnosticsService, ctx context.Context, request *Empty) (interface{}, error) {
+				return service.TriggerPoll(ctx, request)
+			}),
+		},
+		{
+			MethodName: "CloseTunnels",
+			Handler: unaryHandler("CloseTunnels", func(service diagnosticsService, ctx context.Context, request *Empty) (interface{}, error) {
+				return service.CloseTunnels(ctx, request)
+			}),
+		},
+		{
+			MethodName: "SelfTest",
+			Handler: unaryHandler("SelfTest", func(service diagnosticsService, ctx context.Context, request *Empty) (interface{}, error) {
+				return service.SelfTest(ctx, request)
+			}),
+		},
+	},
+	Streams: []grpc.StreamDesc{},
+}
+
+type unaryMethod func(service diagnosticsService, ctx context.Context, request *Empty) (interface{}, error)
+
+// unaryHandler adapts a method of the diagnostics service to the handler signature expected by gRPC,
+// all the methods of the service take an empty request.
+func unaryHandler(methodName string, method unaryMethod) func(interface{}, context.Context, func(interface{}) error, grpc.UnaryServerInterceptor) (interface{}, error) {
+	return func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+		request := new(Empty)
+		if err := dec(request); err != nil {
+			return nil, err
+		}
+
+		service := srv.(diagnosticsService)
+		if interceptor == nil {
+			return method(service, ctx, request)
+		}
+
+		info := &grpc.UnaryServerInfo{
+			Server:     srv,
+			FullMethod: "/" + DiagnosticsServiceName + "/" + methodName,
+		}
+
+		return interceptor(ctx, request, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+			return method(service, ctx, req.(*Empty))
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package edge
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/portainer/agent"
+	"github.com/portainer/agent/edge/scheduler"
+)
+
+const (
+	// scheduleValidationNone applies the schedules without validating them first.
+	scheduleValidationNone = "none"
+	// scheduleValidationReject rejects the whole set of schedules when one of them is invalid.
+	scheduleValidationReject = "reject"
+	// scheduleValidationSkip only applies the valid schedules.
+	scheduleValidationSkip = "skip"
+)
+
+func parseScheduleValidation(policy string) (string, error) {
+	switch policy {
+	case "":
+		return scheduleValidationNone, nil
+	case scheduleValidationNone, scheduleValidationReject, scheduleValidationSkip:
+		return policy, nil
+	}
+
+	return "", fmt.Errorf("unsupported schedule validation policy %q, expected %s, %s or %s", policy, scheduleValidationNone, scheduleValidationReject, scheduleValidationSkip)
+}
+
+// validateSchedules validates the schedules according to the specified policy and returns the schedules
+// that can be applied. An error is returned when the whole set of schedules is rejected.
+func validateSchedules(schedules []agent.Schedule, policy string) ([]agent.Schedule, error) {
+	if policy == scheduleValidationNone {
+		return schedules, nil
+	}
+
+	validSchedules := make([]agent.Schedule, 0, len(schedules))
+	scheduleIDs := map[int]bool{}
+	for _, schedule := range schedules {
+		err := validateScheduleEntry(schedule, scheduleIDs)
+		if err == nil {
+			scheduleIDs[schedule.ID] = true
+			validSchedules = append(validSchedules, schedule)
+			continue
+		}
+
+		if policy == scheduleValidationReject {
+			return nil, fmt.Errorf("schedule %d is invalid: %w", schedule.ID, err)
+		}
+
+		log.Printf("[WARN] [edge] [schedule_id: %d] [message: skipping invalid schedule] [error: %s]", schedule.ID, err)
+	}
+
+	return validSchedules, nil
+}
+
+// validateScheduleEntry validates a single schedule, the identifiers of the schedules already accepted are used
+// to detect duplicated entries.
+func validateScheduleEntry(schedule agent.Schedule, scheduleIDs map[int]bool) error {
+	err := scheduler.ValidateSchedule(schedule)
+	if err != nil {
+		return err
+	}
+
+	if scheduleIDs[schedule.ID] {
+		return fmt.Errorf("duplicated schedule identifier %d", schedule.ID)
+	}
+
+	if schedule.LogsCollectionWindow != "" {
+		_, err = isInLogsCollectionWindow(schedule.LogsCollectionWindow, time.Now())
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,48 @@
+package scheduler
+
+import "github.com/portainer/agent"
+
+// scheduleDiff contains the changes required for the managed schedules to match the received schedules.
+type scheduleDiff struct {
+	added   []agent.Schedule
+	updated []agent.Schedule
+	removed []agent.Schedule
+}
+
+func (diff scheduleDiff) empty() bool {
+	return len(diff.added) == 0 && len(diff.updated) == 0 && len(diff.removed) == 0
+}
+
+// diffSchedules compares the managed schedules with the schedules received from the Portainer instance.
+// A schedule is updated when its version, cron expression or script changed.
+func diffSchedules(managed, received []agent.Schedule) scheduleDiff {
+	diff := scheduleDiff{}
+
+	managedByID := make(map[int]agent.Schedule, len(managed))
+	for _, schedule := range managed {
+		managedByID[schedule.ID] = schedule
+	}
+
+	receivedIDs := make(map[int]bool, len(received))
+	for _, schedule := range received {
+		receivedIDs[schedule.ID] = true
+
+		current, ok := managedByID[schedule.ID]
+		if !ok {
+			diff.added = append(diff.added, schedule)
+			continue
+		}
+
+		if current.Version != schedule.Version || current.CronExpression != schedule.CronExpression || current.Script != schedule.Script {
+			diff.updated = append(diff.updated, schedule)
+		}
+	}
+
+	for _, schedule := range managed {
+		if !receivedIDs[schedule.ID] {
+			diff.removed = append(diff.removed, schedule)
+		}
+	}
+
+	return diff
+}
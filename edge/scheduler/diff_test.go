@@ -0,0 +1,94 @@
+package scheduler
+
+import (
+	"testing"
+
+	"github.com/portainer/agent"
+)
+
+func scheduleIDs(schedules []agent.Schedule) []int {
+	ids := []int{}
+	for _, schedule := range schedules {
+		ids = append(ids, schedule.ID)
+	}
+	return ids
+}
+
+func equalIDs(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+func TestDiffSchedules(t *testing.T) {
+	managed := []agent.Schedule{
+		{ID: 1, CronExpression: "* * * * *", Script: "a", Version: 1},
+		{ID: 2, CronExpression: "* * * * *", Script: "b", Version: 1},
+		{ID: 3, CronExpression: "* * * * *", Script: "c", Version: 1},
+		{ID: 4, CronExpression: "* * * * *", Script: "d", Version: 1},
+	}
+
+	tests := []struct {
+		name     string
+		received []agent.Schedule
+		added    []int
+		updated  []int
+		removed  []int
+	}{
+		{
+			name:     "unchanged",
+			received: managed,
+			added:    []int{},
+			updated:  []int{},
+			removed:  []int{},
+		},
+		{
+			name: "add, update and remove",
+			received: []agent.Schedule{
+				{ID: 1, CronExpression: "* * * * *", Script: "a", Version: 1},
+				{ID: 2, CronExpression: "* * * * *", Script: "b", Version: 2},
+				{ID: 3, CronExpression: "0 * * * *", Script: "c", Version: 1},
+				{ID: 5, CronExpression: "* * * * *", Script: "e", Version: 1},
+			},
+			added:   []int{5},
+			updated: []int{2, 3},
+			removed: []int{4},
+		},
+		{
+			name:     "replaced with the same count",
+			received: []agent.Schedule{managed[0], managed[1], managed[2], {ID: 6, CronExpression: "* * * * *", Script: "f", Version: 1}},
+			added:    []int{6},
+			updated:  []int{},
+			removed:  []int{4},
+		},
+		{
+			name:     "all removed",
+			received: []agent.Schedule{},
+			added:    []int{},
+			updated:  []int{},
+			removed:  []int{1, 2, 3, 4},
+		},
+	}
+
+	for _, test := range tests {
+		diff := diffSchedules(managed, test.received)
+
+		if !equalIDs(scheduleIDs(diff.added), test.added) {
+			t.Errorf("%s: added %v, expected %v", test.name, scheduleIDs(diff.added), test.added)
+		}
+		if !equalIDs(scheduleIDs(diff.updated), test.updated) {
+			t.Errorf("%s: updated %v, expected %v", test.name, scheduleIDs(diff.updated), test.updated)
+		}
+		if !equalIDs(scheduleIDs(diff.removed), test.removed) {
+			t.Errorf("%s: removed %v, expected %v", test.name, scheduleIDs(diff.removed), test.removed)
+		}
+	}
+}
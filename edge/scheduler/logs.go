@@ -3,6 +3,7 @@ package scheduler
 import (
 	"fmt"
 	"log"
+	"net/http"
 
 	"github.com/portainer/agent"
 	"github.com/portainer/agent/edge/client"
@@ -23,6 +24,28 @@ func NewLogsManager(portainerURL, endpointID, edgeID string, insecurePoll bool)
 	}
 }
 
+// SetTransport sets the transport of the requests sent to the Portainer instance, it must be called before Start.
+func (manager *LogsManager) SetTransport(transport http.RoundTripper) {
+	manager.httpClient.SetTransport(transport)
+}
+
+// SetServerAddress sets the function returning the URL of the Portainer instance, it must be called before Start.
+func (manager *LogsManager) SetServerAddress(serverAddress func() string) {
+	manager.httpClient.SetServerAddress(serverAddress)
+}
+
+// SetRequestCompression sets the function telling whether the logs of the schedules must be compressed, it must be
+// called before Start.
+func (manager *LogsManager) SetRequestCompression(enabled func() bool) {
+	manager.httpClient.SetRequestCompression(enabled)
+}
+
+// SetReportQueue sets the queue buffering the logs of the schedules while the Portainer instance is unreachable,
+// it must be called before Start.
+func (manager *LogsManager) SetReportQueue(queue *client.ReportQueue) {
+	manager.httpClient.SetReportQueue(queue)
+}
+
 func (manager *LogsManager) Start() {
 	log.Printf("[DEBUG] [edge,scheduler] [message: logs manager started]")
 	go manager.loop()
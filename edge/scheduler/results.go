@@ -0,0 +1,68 @@
+package scheduler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/portainer/agent"
+	"github.com/portainer/agent/filesystem"
+)
+
+// RunResult is the outcome of the last run of a schedule, recorded by the script wrapping the schedule script.
+type RunResult struct {
+	ExitCode  int
+	StartedAt time.Time
+	Duration  time.Duration
+}
+
+// Failed returns true when the schedule script exited with a non-zero code.
+func (result *RunResult) Failed() bool {
+	return result.ExitCode != 0
+}
+
+func runResultFile(scheduleID int) string {
+	return fmt.Sprintf("%s%s/schedule_%d.result", agent.HostRoot, agent.ScheduleScriptDirectory, scheduleID)
+}
+
+// LastRunResult returns the outcome of the last run of a schedule, nil is returned when the schedule did not run
+// yet or when its outcome was not recorded.
+func LastRunResult(scheduleID int) (*RunResult, error) {
+	path := runResultFile(scheduleID)
+
+	exist, err := filesystem.FileExists(path)
+	if err != nil || !exist {
+		return nil, err
+	}
+
+	content, err := filesystem.ReadFromFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseRunResult(string(content))
+}
+
+// parseRunResult parses a run result in the "exit_code start end" format, start and end being Unix times.
+func parseRunResult(content string) (*RunResult, error) {
+	fields := strings.Fields(content)
+	if len(fields) != 3 {
+		return nil, fmt.Errorf("invalid run result %q", content)
+	}
+
+	values := make([]int64, len(fields))
+	for i, field := range fields {
+		value, err := strconv.ParseInt(field, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid run result %q", content)
+		}
+		values[i] = value
+	}
+
+	return &RunResult{
+		ExitCode:  int(values[0]),
+		StartedAt: time.Unix(values[1], 0),
+		Duration:  time.Duration(values[2]-values[1]) * time.Second,
+	}, nil
+}
@@ -4,10 +4,13 @@
 package scheduler
 
 import (
+	"context"
 	"encoding/base64"
 	"fmt"
 	"log"
+	"os"
 	"strings"
+	"sync"
 
 	"github.com/portainer/agent"
 	"github.com/portainer/agent/filesystem"
@@ -24,6 +27,7 @@ const (
 type CronManager struct {
 	cronFileExists   bool
 	managedSchedules []agent.Schedule
+	mu               sync.Mutex
 }
 
 // NewCronManager returns a pointer to a new instance of CronManager.
@@ -36,9 +40,35 @@ func NewCronManager() *CronManager {
 
 // Schedule takes care of writing schedules on disk inside a cron file.
 // It also creates/updates the script associated to each schedule on the filesystem.
-// It keeps track of managed schedules and will flush the content of the cron file only if it detects any change.
-// Note that this implementation do not clean-up scripts located on the filesystem that are related to old schedules.
-func (manager *CronManager) Schedule(schedules []agent.Schedule) error {
+// It keeps track of managed schedules and will flush the content of the cron file only if it detects any change:
+// the schedules that are no longer received are removed, the changed schedules are updated and the new ones added.
+// The scripts of the removed schedules are removed from the filesystem, their logs are kept.
+// Once the context is done, the remaining changes are abandoned and the cron file is left untouched, the
+// schedules are applied again on the next call.
+func (manager *CronManager) Schedule(ctx context.Context, schedules []agent.Schedule) error {
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+
+	diff := diffSchedules(manager.managedSchedules, schedules)
+	if diff.empty() {
+		return nil
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	for _, schedule := range diff.added {
+		log.Printf("[DEBUG] [edge,scheduler] [schedule_id: %d] [version: %d] [message: Adding schedule]", schedule.ID, schedule.Version)
+	}
+	for _, schedule := range diff.updated {
+		log.Printf("[DEBUG] [edge,scheduler] [schedule_id: %d] [version: %d] [message: Found schedule with new version]", schedule.ID, schedule.Version)
+	}
+	for _, schedule := range diff.removed {
+		log.Printf("[DEBUG] [edge,scheduler] [schedule_id: %d] [message: Removing schedule no longer sent by the Portainer instance]", schedule.ID)
+		removeScheduleScripts(schedule.ID)
+	}
+
 	if len(schedules) == 0 {
 		manager.managedSchedules = schedules
 		if manager.cronFileExists {
@@ -49,32 +79,36 @@ func (manager *CronManager) Schedule(schedules []agent.Schedule) error {
 		return nil
 	}
 
-	if len(manager.managedSchedules) != len(schedules) {
-		manager.managedSchedules = schedules
-		return manager.flushEntries()
+	err := manager.flushEntries(ctx, schedules)
+	if err != nil {
+		return err
 	}
 
-	updateRequired := false
-	for _, schedule := range schedules {
-		for _, managed := range manager.managedSchedules {
-			if schedule.ID == managed.ID && schedule.Version != managed.Version {
-				log.Printf("[DEBUG] [edge,scheduler] [schedule_id: %d] [version: %d] [message: Found schedule with new version]", schedule.ID, schedule.Version)
-				updateRequired = true
-				break
-			}
-		}
+	manager.managedSchedules = schedules
+	return nil
+}
 
-		if updateRequired {
-			break
+// removeScheduleScripts removes the script of a schedule and its wrapper from the filesystem.
+func removeScheduleScripts(scheduleID int) {
+	for _, name := range []string{fmt.Sprintf("schedule_%d", scheduleID), fmt.Sprintf("schedule_%d_run", scheduleID)} {
+		path := fmt.Sprintf("%s%s/%s", agent.HostRoot, agent.ScheduleScriptDirectory, name)
+
+		err := filesystem.RemoveFile(path)
+		if err != nil && !os.IsNotExist(err) {
+			log.Printf("[WARN] [edge,scheduler] [schedule_id: %d] [path: %s] [message: Unable to remove schedule script] [err: %s]", scheduleID, path, err)
 		}
 	}
+}
 
-	if updateRequired {
-		manager.managedSchedules = schedules
-		return manager.flushEntries()
-	}
+// Schedules returns a copy of the schedules currently managed by the agent.
+func (manager *CronManager) Schedules() []agent.Schedule {
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
 
-	return nil
+	schedules := make([]agent.Schedule, len(manager.managedSchedules))
+	copy(schedules, manager.managedSchedules)
+
+	return schedules
 }
 
 func createCronEntry(schedule *agent.Schedule) (string, error) {
@@ -88,14 +122,35 @@ func createCronEntry(schedule *agent.Schedule) (string, error) {
 		return "", err
 	}
 
+	err = filesystem.WriteFile(fmt.Sprintf("%s%s", agent.HostRoot, agent.ScheduleScriptDirectory), fmt.Sprintf("schedule_%d_run", schedule.ID), []byte(runWrapperScript(schedule.ID)), 0744)
+	if err != nil {
+		return "", err
+	}
+
 	cronExpression := schedule.CronExpression
-	command := fmt.Sprintf("%s/schedule_%d", agent.ScheduleScriptDirectory, schedule.ID)
-	logFile := fmt.Sprintf("%s/schedule_%d.log", agent.ScheduleScriptDirectory, schedule.ID)
+	command := fmt.Sprintf("%s/schedule_%d_run", agent.ScheduleScriptDirectory, schedule.ID)
+
+	return fmt.Sprintf("%s %s %s", cronExpression, cronJobUser, command), nil
+}
 
-	return fmt.Sprintf("%s %s %s > %s 2>&1", cronExpression, cronJobUser, command, logFile), nil
+// runWrapperScript returns a script that runs the script of a schedule, redirecting its output to the schedule
+// log file, and records the exit code and the start and end times of the run in the schedule result file.
+func runWrapperScript(scheduleID int) string {
+	command := fmt.Sprintf("%s/schedule_%d", agent.ScheduleScriptDirectory, scheduleID)
+	logFile := fmt.Sprintf("%s/schedule_%d.log", agent.ScheduleScriptDirectory, scheduleID)
+	resultFile := fmt.Sprintf("%s/schedule_%d.result", agent.ScheduleScriptDirectory, scheduleID)
+
+	return strings.Join([]string{
+		"#!/bin/sh",
+		"start=$(date +%s)",
+		fmt.Sprintf("%s > %s 2>&1", command, logFile),
+		"code=$?",
+		fmt.Sprintf("echo \"$code $start $(date +%%s)\" > %s", resultFile),
+		"",
+	}, "\n")
 }
 
-func (manager *CronManager) flushEntries() error {
+func (manager *CronManager) flushEntries(ctx context.Context, schedules []agent.Schedule) error {
 	cronEntries := make([]string, 0)
 
 	header := []string{
@@ -107,7 +162,11 @@ func (manager *CronManager) flushEntries() error {
 
 	cronEntries = append(cronEntries, header...)
 
-	for _, schedule := range manager.managedSchedules {
+	for _, schedule := range schedules {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
 		cronEntry, err := createCronEntry(&schedule)
 		if err != nil {
 			log.Printf("[ERROR] [edge,scheduler] [schedule_id: %d] [message: Unable to create cron entry] [err: %s]", schedule.ID, err)
@@ -116,7 +175,7 @@ func (manager *CronManager) flushEntries() error {
 		cronEntries = append(cronEntries, cronEntry)
 	}
 
-	log.Printf("[DEBUG] [edge,scheduler] [schedule_count: %d] [message: Writing cron file on disk]", len(manager.managedSchedules))
+	log.Printf("[DEBUG] [edge,scheduler] [schedule_count: %d] [message: Writing cron file on disk]", len(schedules))
 
 	cronEntries = append(cronEntries, "")
 	cronFileContent := strings.Join(cronEntries, "\n")
@@ -3,7 +3,11 @@
 
 package scheduler
 
-import "github.com/portainer/agent"
+import (
+	"context"
+
+	"github.com/portainer/agent"
+)
 
 type CronManager struct {
 }
@@ -12,6 +16,10 @@ func NewCronManager() *CronManager {
 	return &CronManager{}
 }
 
-func (manager *CronManager) Schedule(schedules []agent.Schedule) error {
+func (manager *CronManager) Schedule(ctx context.Context, schedules []agent.Schedule) error {
 	return nil
 }
+
+func (manager *CronManager) Schedules() []agent.Schedule {
+	return []agent.Schedule{}
+}
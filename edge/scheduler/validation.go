@@ -0,0 +1,145 @@
+package scheduler
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/portainer/agent"
+)
+
+type cronField struct {
+	name  string
+	min   int
+	max   int
+	names map[string]int
+}
+
+var cronFields = []cronField{
+	{name: "minute", min: 0, max: 59},
+	{name: "hour", min: 0, max: 23},
+	{name: "day of month", min: 1, max: 31},
+	{name: "month", min: 1, max: 12, names: map[string]int{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}},
+	{name: "day of week", min: 0, max: 7, names: map[string]int{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}},
+}
+
+var cronMacros = map[string]bool{
+	"@reboot": true, "@yearly": true, "@annually": true, "@monthly": true,
+	"@weekly": true, "@daily": true, "@midnight": true, "@hourly": true,
+}
+
+// ValidateSchedule ensures that a schedule can be written as a cron entry: the schedule must be identified,
+// the cron expression must be a valid expression for the host cron daemon and the script must be base64 encoded.
+func ValidateSchedule(schedule agent.Schedule) error {
+	if schedule.ID <= 0 {
+		return fmt.Errorf("invalid schedule identifier %d", schedule.ID)
+	}
+
+	if schedule.Version < 0 {
+		return fmt.Errorf("invalid schedule version %d", schedule.Version)
+	}
+
+	if schedule.Script == "" {
+		return errors.New("missing script")
+	}
+
+	err := ValidateCronExpression(schedule.CronExpression)
+	if err != nil {
+		return err
+	}
+
+	_, err = base64.RawStdEncoding.DecodeString(schedule.Script)
+	if err != nil {
+		return fmt.Errorf("invalid script encoding: %w", err)
+	}
+
+	return nil
+}
+
+// ValidateCronExpression validates a 5 fields cron expression (minute, hour, day of month, month and day of week)
+// or one of the @ macros supported by the host cron daemon.
+func ValidateCronExpression(expression string) error {
+	if strings.ContainsAny(expression, "\r\n") {
+		return fmt.Errorf("invalid cron expression %q, line breaks are not allowed", expression)
+	}
+
+	expression = strings.TrimSpace(expression)
+	if strings.HasPrefix(expression, "@") {
+		if !cronMacros[expression] {
+			return fmt.Errorf("invalid cron expression %q, unknown macro", expression)
+		}
+		return nil
+	}
+
+	fields := strings.Fields(expression)
+	if len(fields) != len(cronFields) {
+		return fmt.Errorf("invalid cron expression %q, expected %d fields", expression, len(cronFields))
+	}
+
+	for idx, field := range fields {
+		err := validateCronField(field, cronFields[idx])
+		if err != nil {
+			return fmt.Errorf("invalid cron expression %q: %w", expression, err)
+		}
+	}
+
+	return nil
+}
+
+func validateCronField(value string, field cronField) error {
+	for _, item := range strings.Split(value, ",") {
+		rangePart := item
+
+		if idx := strings.Index(item, "/"); idx != -1 {
+			rangePart = item[:idx]
+
+			step, err := strconv.Atoi(item[idx+1:])
+			if err != nil || step < 1 {
+				return fmt.Errorf("invalid step in %s field %q", field.name, item)
+			}
+		}
+
+		if rangePart == "*" {
+			continue
+		}
+
+		bounds := strings.SplitN(rangePart, "-", 2)
+		start, err := parseCronValue(bounds[0], field)
+		if err != nil {
+			return err
+		}
+
+		if len(bounds) == 2 {
+			end, err := parseCronValue(bounds[1], field)
+			if err != nil {
+				return err
+			}
+
+			if start > end {
+				return fmt.Errorf("invalid range in %s field %q", field.name, rangePart)
+			}
+		}
+	}
+
+	return nil
+}
+
+func parseCronValue(value string, field cronField) (int, error) {
+	if number, ok := field.names[strings.ToLower(value)]; ok {
+		return number, nil
+	}
+
+	number, err := strconv.Atoi(value)
+	if err != nil || number < field.min || number > field.max {
+		return 0, fmt.Errorf("invalid value %q in %s field, expected a value between %d and %d", value, field.name, field.min, field.max)
+	}
+
+	return number, nil
+}
@@ -0,0 +1,26 @@
+package edge
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/portainer/agent"
+)
+
+// schedulesSnapshot is the representation of the schedules applied by the agent at a given time.
+type schedulesSnapshot struct {
+	EndpointID string
+	CreatedAt  time.Time
+	Schedules  []agent.Schedule
+}
+
+// ExportSchedules returns a JSON snapshot of the schedules currently applied by the agent.
+func (service *PollService) ExportSchedules() ([]byte, error) {
+	snapshot := schedulesSnapshot{
+		EndpointID: service.endpointID,
+		CreatedAt:  time.Now().UTC(),
+		Schedules:  service.scheduleManager.Schedules(),
+	}
+
+	return json.MarshalIndent(snapshot, "", "  ")
+}
@@ -0,0 +1,129 @@
+package edge
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"log"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/portainer/agent"
+	"github.com/portainer/agent/filesystem"
+)
+
+const signingKeyPEMType = "PRIVATE KEY"
+
+// signedReportHeaders are the headers carrying the data reported by the agent, covered by the report signature.
+var signedReportHeaders = []string{
+	agent.HTTPEdgeReportHeaderName,
+	agent.HTTPEdgeAgentInfoHeaderName,
+	agent.HTTPEdgeActionsHeaderName,
+}
+
+// reportSigner signs the data reported by the agent with an Ed25519 key generated by the agent. The private key
+// never leaves the agent, only the public key is sent so that the Portainer instance can pin it the first time it
+// is seen and verify that the next reports were sent by this agent.
+type reportSigner struct {
+	privateKey ed25519.PrivateKey
+	publicKey  string
+}
+
+// loadReportSigner loads the signing key persisted in the folder, a new key is generated and persisted when none
+// exists yet. Without a folder, the key only lives as long as the process.
+func loadReportSigner(folder string) (*reportSigner, error) {
+	if folder == "" {
+		log.Println("[WARN] [edge] [message: no data folder, the report signing key will change when the agent restarts]")
+		return generateReportSigner()
+	}
+
+	path := filepath.Join(folder, agent.EdgeSigningKeyFile)
+	exist, err := filesystem.FileExists(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if !exist {
+		signer, err := generateReportSigner()
+		if err != nil {
+			return nil, err
+		}
+
+		der, err := x509.MarshalPKCS8PrivateKey(signer.privateKey)
+		if err != nil {
+			return nil, err
+		}
+
+		err = filesystem.WriteFile(folder, agent.EdgeSigningKeyFile, pem.EncodeToMemory(&pem.Block{Type: signingKeyPEMType, Bytes: der}), 0600)
+		if err != nil {
+			return nil, err
+		}
+
+		log.Printf("[INFO] [edge] [public_key: %s] [message: generated a new report signing key]", signer.publicKey)
+		return signer, nil
+	}
+
+	data, err := filesystem.ReadFromFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != signingKeyPEMType {
+		return nil, errors.New("invalid report signing key file " + path)
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	privateKey, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, errors.New("the report signing key is not an Ed25519 key")
+	}
+
+	return newReportSigner(privateKey), nil
+}
+
+func generateReportSigner() (*reportSigner, error) {
+	_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	return newReportSigner(privateKey), nil
+}
+
+func newReportSigner(privateKey ed25519.PrivateKey) *reportSigner {
+	return &reportSigner{
+		privateKey: privateKey,
+		publicKey:  base64.StdEncoding.EncodeToString(privateKey.Public().(ed25519.PublicKey)),
+	}
+}
+
+// sign signs the data reported by the agent so that the Portainer instance can verify that the report was sent by
+// this agent. The signature is an Ed25519 signature of the timestamp and of the reported headers, one per line.
+func (signer *reportSigner) sign(req *http.Request, now time.Time) {
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+
+	req.Header.Set(agent.HTTPEdgeSignatureTimestampHeaderName, timestamp)
+	req.Header.Set(agent.HTTPEdgeSignatureKeyHeaderName, signer.publicKey)
+	req.Header.Set(agent.HTTPEdgeSignatureHeaderName, base64.StdEncoding.EncodeToString(ed25519.Sign(signer.privateKey, signedReportMessage(req))))
+}
+
+// signedReportMessage returns the message covered by the report signature.
+func signedReportMessage(req *http.Request) []byte {
+	values := []string{req.Header.Get(agent.HTTPEdgeSignatureTimestampHeaderName)}
+	for _, header := range signedReportHeaders {
+		values = append(values, req.Header.Get(header))
+	}
+
+	return []byte(strings.Join(values, "\n"))
+}
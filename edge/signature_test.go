@@ -0,0 +1,74 @@
+package edge
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/portainer/agent"
+)
+
+func TestReportSignatureCannotBeForgedFromTheRequestHeaders(t *testing.T) {
+	signer, err := loadReportSigner(t.TempDir())
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, "https://portainer.example.com/api/endpoints/1/status", nil)
+	req.Header.Set(agent.HTTPEdgeIdentifierHeaderName, "edge-id")
+	req.Header.Set(agent.HTTPEdgeReportHeaderName, "report")
+	signer.sign(req, time.Now())
+
+	publicKey, err := base64.StdEncoding.DecodeString(req.Header.Get(agent.HTTPEdgeSignatureKeyHeaderName))
+	if err != nil {
+		t.Fatalf("invalid public key header: %s", err)
+	}
+
+	signature, _ := base64.StdEncoding.DecodeString(req.Header.Get(agent.HTTPEdgeSignatureHeaderName))
+	if !ed25519.Verify(publicKey, signedReportMessage(req), signature) {
+		t.Fatal("expected the signature to be valid")
+	}
+
+	// Tamper with the report and re-sign it with everything that is sent on the wire
+	req.Header.Set(agent.HTTPEdgeReportHeaderName, "forged")
+	message := signedReportMessage(req)
+
+	for _, secret := range []string{req.Header.Get(agent.HTTPEdgeIdentifierHeaderName), req.Header.Get(agent.HTTPEdgeSignatureKeyHeaderName)} {
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write(message)
+		if ed25519.Verify(publicKey, message, mac.Sum(nil)) {
+			t.Errorf("a signature was forged from the %q header value", secret)
+		}
+	}
+
+	forger, _ := generateReportSigner()
+	if ed25519.Verify(publicKey, message, ed25519.Sign(forger.privateKey, message)) {
+		t.Error("a signature was forged with another key")
+	}
+
+	if ed25519.Verify(publicKey, message, signature) {
+		t.Error("expected the original signature to be rejected for a tampered report")
+	}
+}
+
+func TestLoadReportSignerPersistsTheKey(t *testing.T) {
+	folder := t.TempDir()
+
+	first, err := loadReportSigner(folder)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	second, err := loadReportSigner(folder)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if first.publicKey != second.publicKey {
+		t.Error("expected the signing key to be reused across restarts")
+	}
+}
@@ -0,0 +1,77 @@
+package edge
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net"
+	"net/url"
+	"time"
+
+	"golang.org/x/net/proxy"
+)
+
+// dialContextFunc opens a network connection, it has the signature of net.Dialer.DialContext.
+type dialContextFunc func(ctx context.Context, network, addr string) (net.Conn, error)
+
+// newSOCKS5Dialer returns a dial function connecting through the SOCKS5 proxy listening on the specified address,
+// the credentials are optional. It returns nil when no address is specified.
+func newSOCKS5Dialer(addr, username, password string) (dialContextFunc, error) {
+	if addr == "" {
+		if username != "" {
+			return nil, errors.New("a SOCKS5 username was specified without a SOCKS5 proxy address")
+		}
+
+		return nil, nil
+	}
+
+	var auth *proxy.Auth
+	if username != "" {
+		auth = &proxy.Auth{User: username, Password: password}
+	}
+
+	dialer, err := proxy.SOCKS5("tcp", addr, auth, &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	contextDialer, ok := dialer.(proxy.ContextDialer)
+	if !ok {
+		return nil, errors.New("the SOCKS5 dialer does not support contexts")
+	}
+
+	log.Printf("[INFO] [edge] [socks5_addr: %s] [authentication: %t] [message: the Portainer instance and the tunnel server are reached through a SOCKS5 proxy]", addr, auth != nil)
+
+	return contextDialer.DialContext, nil
+}
+
+// dialTimeout opens a TCP connection to a remote address, through the SOCKS5 proxy when one is set so that the
+// connectivity checks follow the same path as the poll and tunnel connections.
+func (service *PollService) dialTimeout(addr string, timeout time.Duration) (net.Conn, error) {
+	if service.socksDialer == nil {
+		return net.DialTimeout("tcp", addr, timeout)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	return service.socksDialer(ctx, "tcp", addr)
+}
+
+// socks5ProxyURL returns the URL of the SOCKS5 proxy in the format expected by the tunnel client, the host names
+// are resolved by the proxy. It returns nil when no address is specified.
+func socks5ProxyURL(addr, username, password string) *url.URL {
+	if addr == "" {
+		return nil
+	}
+
+	proxyURL := &url.URL{Scheme: "socks5h", Host: addr}
+	if username != "" {
+		proxyURL.User = url.UserPassword(username, password)
+	}
+
+	return proxyURL
+}
@@ -2,8 +2,11 @@ package stack
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"net/http"
+	"sort"
 	"sync"
 	"time"
 
@@ -13,6 +16,10 @@ import (
 	"github.com/portainer/agent/filesystem"
 )
 
+// ErrStackUpdatePostponed is returned when the update of a stack is postponed because the stack is being
+// reconciled, the update must be requested again.
+var ErrStackUpdatePostponed = errors.New("the update of a stack being reconciled is postponed")
+
 type edgeStackID int
 
 type edgeStack struct {
@@ -73,7 +80,14 @@ type StackManager struct {
 	isEnabled  bool
 	httpClient *client.PortainerClient
 	assetsPath string
-	mu         sync.Mutex
+	strategy   ReconciliationStrategy
+	// maxConcurrency is the number of workers reconciling the stacks, the other pending stacks are queued
+	maxConcurrency int
+	// inProgress holds the stacks currently being reconciled by a worker
+	inProgress map[edgeStackID]bool
+	// updateBatchSize is the number of stacks processed at once when the stacks are updated, 0 disables the batches
+	updateBatchSize int
+	mu              sync.Mutex
 }
 
 // NewStackManager returns a pointer to a new instance of StackManager
@@ -85,25 +99,166 @@ func NewStackManager(portainerURL, endpointID, edgeID, assetsPath string, insecu
 		stopSignal: nil,
 		httpClient: cli,
 		assetsPath: assetsPath,
+		strategy:   inPlaceStrategy{},
+
+		maxConcurrency: 1,
+		inProgress:     map[edgeStackID]bool{},
 	}
 
 	return stackManager, nil
 }
 
-func (manager *StackManager) UpdateStacksStatus(stacks map[int]int) error {
+// SetReconciliationStrategy sets the strategy used to converge the stacks, the strategy must have been registered.
+func (manager *StackManager) SetReconciliationStrategy(name string) error {
+	strategy, err := lookupReconciliationStrategy(name)
+	if err != nil {
+		return err
+	}
+
+	manager.mu.Lock()
+	manager.strategy = strategy
+	manager.mu.Unlock()
+
+	return nil
+}
+
+// SetMaxConcurrency sets the maximum number of stacks reconciled concurrently, it must be called before Start.
+func (manager *StackManager) SetMaxConcurrency(maxConcurrency int) error {
+	if maxConcurrency < 1 {
+		return fmt.Errorf("invalid Edge stack concurrency %d, must be at least 1", maxConcurrency)
+	}
+
+	manager.mu.Lock()
+	manager.maxConcurrency = maxConcurrency
+	manager.mu.Unlock()
+
+	return nil
+}
+
+// SetUpdateBatchSize sets the number of stacks processed at once when the stacks are updated, 0 processes
+// all the stacks at once.
+func (manager *StackManager) SetUpdateBatchSize(batchSize int) error {
+	if batchSize < 0 {
+		return fmt.Errorf("invalid Edge stack batch size %d, must not be negative", batchSize)
+	}
+
+	manager.mu.Lock()
+	manager.updateBatchSize = batchSize
+	manager.mu.Unlock()
+
+	return nil
+}
+
+// SetTransport sets the transport of the requests sent to the Portainer instance, it must be called before Start.
+func (manager *StackManager) SetTransport(transport http.RoundTripper) {
+	manager.httpClient.SetTransport(transport)
+}
+
+// SetServerAddress sets the function returning the URL of the Portainer instance, it must be called before Start.
+func (manager *StackManager) SetServerAddress(serverAddress func() string) {
+	manager.httpClient.SetServerAddress(serverAddress)
+}
+
+// SetRequestCompression sets the function telling whether the Edge stack status updates must be compressed,
+// it must be called before Start.
+func (manager *StackManager) SetRequestCompression(enabled func() bool) {
+	manager.httpClient.SetRequestCompression(enabled)
+}
+
+// SetReportQueue sets the queue buffering the Edge stack status updates while the Portainer instance is unreachable,
+// it must be called before Start.
+func (manager *StackManager) SetReportQueue(queue *client.ReportQueue) {
+	manager.httpClient.SetReportQueue(queue)
+}
+
+// UpdateStacksStatus marks the stacks that must be deployed, updated or removed to match the versions requested by
+// the Portainer instance. The stacks to deploy or update are processed in batches and the manager lock is released
+// between the batches so that the stacks already processed can be reconciled. When the context is done, the
+// remaining stacks are left untouched and processed during the next update. ErrStackUpdatePostponed is returned
+// once every batch was processed when the update of a stack being reconciled was postponed.
+func (manager *StackManager) UpdateStacksStatus(ctx context.Context, stacks map[int]int) error {
 	if !manager.isEnabled {
 		return nil
 	}
 
+	manager.mu.Lock()
+	changed := []int{}
+	for stackID, version := range stacks {
+		stack, ok := manager.stacks[edgeStackID(stackID)]
+		if !ok || stack.Version != version {
+			changed = append(changed, stackID)
+		}
+	}
+
+	for stackID, stack := range manager.stacks {
+		if _, ok := stacks[int(stackID)]; !ok && stack.Action != actionDelete {
+			log.Printf("[DEBUG] [edge,stack] [stack_identifier: %d] [message: marking stack for deletion]", stackID)
+			stack.Action = actionDelete
+			stack.Status = statusPending
+		}
+	}
+
+	batchSize := manager.updateBatchSize
+	manager.mu.Unlock()
+
+	sort.Ints(changed)
+	if batchSize <= 0 {
+		batchSize = len(changed)
+	}
+
+	postponed := false
+	for start := 0; start < len(changed); start += batchSize {
+		if ctx.Err() != nil {
+			log.Printf("[DEBUG] [edge,stack] [remaining_stacks: %d] [message: interrupting the stack update, the remaining stacks will be processed during the next update]", len(changed)-start)
+			return ctx.Err()
+		}
+
+		end := start + batchSize
+		if end > len(changed) {
+			end = len(changed)
+		}
+
+		batchPostponed, err := manager.updateStacksBatch(changed[start:end], stacks)
+		if err != nil {
+			return err
+		}
+		postponed = postponed || batchPostponed
+
+		if len(changed) > batchSize {
+			log.Printf("[DEBUG] [edge,stack] [processed_stacks: %d] [total_stacks: %d] [message: stack update batch processed]", end, len(changed))
+		}
+	}
+
+	if postponed {
+		return ErrStackUpdatePostponed
+	}
+
+	return nil
+}
+
+// updateStacksBatch retrieves the configuration of a batch of stacks and marks them for deployment or update.
+// The stacks being reconciled by a worker are skipped so that their stack file is not rewritten while it is in use,
+// their version is left untouched so that they are processed during the next update. It returns true when such a
+// stack was skipped.
+func (manager *StackManager) updateStacksBatch(stackIDs []int, stacks map[int]int) (bool, error) {
 	manager.mu.Lock()
 	defer manager.mu.Unlock()
 
-	for stackID, version := range stacks {
+	postponed := false
+	for _, stackID := range stackIDs {
+		version := stacks[stackID]
+
 		stack, ok := manager.stacks[edgeStackID(stackID)]
 		if ok {
 			if stack.Version == version {
 				continue
 			}
+
+			if manager.inProgress[stack.ID] {
+				log.Printf("[DEBUG] [edge,stack] [stack_identifier: %d] [message: stack is being reconciled, the update is postponed]", stackID)
+				postponed = true
+				continue
+			}
 			log.Printf("[DEBUG] [edge,stack] [stack_identifier: %d] [message: marking stack for update]", stackID)
 
 			stack.Action = actionUpdate
@@ -122,7 +277,7 @@ func (manager *StackManager) UpdateStacksStatus(stacks map[int]int) error {
 
 		stackConfig, err := manager.httpClient.GetEdgeStackConfig(int(stack.ID))
 		if err != nil {
-			return err
+			return false, err
 		}
 
 		stack.Name = stackConfig.Name
@@ -135,7 +290,7 @@ func (manager *StackManager) UpdateStacksStatus(stacks map[int]int) error {
 
 		err = filesystem.WriteFile(folder, fileName, []byte(stackConfig.FileContent), 0644)
 		if err != nil {
-			return err
+			return false, err
 		}
 
 		stack.FileFolder = folder
@@ -145,21 +300,11 @@ func (manager *StackManager) UpdateStacksStatus(stacks map[int]int) error {
 
 		err = manager.httpClient.SetEdgeStackStatus(int(stack.ID), int(edgeStackStatusAcknowledged), "")
 		if err != nil {
-			return err
+			return false, err
 		}
 	}
 
-	for stackID, stack := range manager.stacks {
-		if _, ok := stacks[int(stackID)]; !ok {
-			log.Printf("[DEBUG] [edge,stack] [stack_identifier: %d] [message: marking stack for deletion]", stackID)
-			stack.Action = actionDelete
-			stack.Status = statusPending
-
-			manager.stacks[stackID] = stack
-		}
-	}
-
-	return nil
+	return postponed, nil
 }
 
 func (manager *StackManager) Stop() error {
@@ -185,51 +330,88 @@ func (manager *StackManager) Start() error {
 		return err
 	}
 
-	go (func() {
-		for {
-			select {
-			case <-manager.stopSignal:
-				log.Println("[DEBUG] [edge,stack] [message: shutting down Edge stack manager]")
-				return
-			default:
-				stack := manager.next()
-				if stack == nil {
-					timer1 := time.NewTimer(queueSleepInterval)
-					<-timer1.C
-					continue
-				}
-
-				ctx := context.TODO()
-
-				manager.mu.Lock()
-				stackName := fmt.Sprintf("edge_%s", stack.Name)
-				stackFileLocation := fmt.Sprintf("%s/%s", stack.FileFolder, stack.FileName)
-				manager.mu.Unlock()
-
-				if stack.Action == actionDeploy || stack.Action == actionUpdate {
-					manager.deployStack(ctx, stack, stackName, stackFileLocation)
-				} else if stack.Action == actionDelete {
-					manager.deleteStack(ctx, stack, stackName, stackFileLocation)
-				}
-			}
-		}
-	})()
+	manager.mu.Lock()
+	workers := manager.maxConcurrency
+	manager.mu.Unlock()
+
+	log.Printf("[DEBUG] [edge,stack] [workers: %d] [message: starting Edge stack manager]", workers)
+
+	for i := 0; i < workers; i++ {
+		go manager.work(manager.stopSignal, queueSleepInterval)
+	}
 
 	return nil
 }
 
+// work reconciles the pending stacks one at a time until the stop signal is closed.
+// Several workers can run at the same time, each of them working on a different stack.
+func (manager *StackManager) work(stopSignal chan struct{}, queueSleepInterval time.Duration) {
+	for {
+		select {
+		case <-stopSignal:
+			log.Println("[DEBUG] [edge,stack] [message: shutting down Edge stack manager]")
+			return
+		default:
+			stack := manager.next()
+			if stack == nil {
+				timer1 := time.NewTimer(queueSleepInterval)
+				<-timer1.C
+				continue
+			}
+
+			ctx := context.TODO()
+
+			manager.mu.Lock()
+			stackName := fmt.Sprintf("edge_%s", stack.Name)
+			stackFileLocation := fmt.Sprintf("%s/%s", stack.FileFolder, stack.FileName)
+			action := stack.Action
+			manager.mu.Unlock()
+
+			if action == actionDeploy || action == actionUpdate {
+				manager.deployStack(ctx, stack, stackName, stackFileLocation)
+			} else if action == actionDelete {
+				manager.deleteStack(ctx, stack, stackName, stackFileLocation)
+			}
+
+			manager.mu.Lock()
+			delete(manager.inProgress, stack.ID)
+			manager.mu.Unlock()
+		}
+	}
+}
+
+// next returns a pending stack that is not already being reconciled by another worker and marks it as in progress.
 func (manager *StackManager) next() *edgeStack {
 	manager.mu.Lock()
 	defer manager.mu.Unlock()
 
 	for _, stack := range manager.stacks {
-		if stack.Status == statusPending {
+		if stack.Status == statusPending && !manager.inProgress[stack.ID] {
+			manager.inProgress[stack.ID] = true
 			return stack
 		}
 	}
 	return nil
 }
 
+// Converged returns true when no stack is waiting to be deployed, updated or removed.
+func (manager *StackManager) Converged() bool {
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+
+	if len(manager.inProgress) > 0 {
+		return false
+	}
+
+	for _, stack := range manager.stacks {
+		if stack.Status == statusPending {
+			return false
+		}
+	}
+
+	return true
+}
+
 func (manager *StackManager) SetEngineStatus(engineStatus engineType) error {
 	if engineStatus == manager.engineType {
 		return nil
@@ -251,27 +433,41 @@ func (manager *StackManager) SetEngineStatus(engineStatus engineType) error {
 	return nil
 }
 
+// deployStack deploys or updates a stack. The manager lock is only held while the state of the stack is read and
+// updated so that the other workers are not blocked during the deployment. The stack stays in progress until the
+// deployment completes, its stack file is therefore not rewritten by updateStacksBatch in the meantime.
 func (manager *StackManager) deployStack(ctx context.Context, stack *edgeStack, stackName, stackFileLocation string) {
 	manager.mu.Lock()
-	defer manager.mu.Unlock()
-
 	log.Printf("[DEBUG] [edge,stack] [stack_identifier: %d] [message: stack deployment]", stack.ID)
+	action := stack.Action
+	version := stack.Version
 	stack.Status = statusDone
 	stack.Action = actionIdle
+	strategy := manager.strategy
+	manager.mu.Unlock()
+
 	responseStatus := int(edgeStackStatusOk)
 	errorMessage := ""
 
-	err := manager.deployer.Deploy(ctx, stackName, []string{stackFileLocation}, false)
+	var err error
+	if action == actionUpdate {
+		err = strategy.Update(ctx, manager.deployer, stackName, []string{stackFileLocation})
+	} else {
+		err = strategy.Deploy(ctx, manager.deployer, stackName, []string{stackFileLocation})
+	}
+
+	manager.mu.Lock()
 	if err != nil {
 		log.Printf("[ERROR] [edge,stack] [message: stack deployment failed] [error: %s]", err)
-		stack.Status = statusError
+		if stack.Status != statusPending {
+			stack.Status = statusError
+		}
 		responseStatus = int(edgeStackStatusError)
 		errorMessage = err.Error()
 	} else {
-		log.Printf("[DEBUG] [edge,stack] [stack_identifier: %d] [stack_version: %d] [message: stack deployed]", stack.ID, stack.Version)
+		log.Printf("[DEBUG] [edge,stack] [stack_identifier: %d] [stack_version: %d] [message: stack deployed]", stack.ID, version)
 	}
-
-	manager.stacks[stack.ID] = stack
+	manager.mu.Unlock()
 
 	err = manager.httpClient.SetEdgeStackStatus(int(stack.ID), responseStatus, errorMessage)
 	if err != nil {
@@ -281,7 +477,11 @@ func (manager *StackManager) deployStack(ctx context.Context, stack *edgeStack,
 
 func (manager *StackManager) deleteStack(ctx context.Context, stack *edgeStack, stackName, stackFileLocation string) {
 	log.Printf("[DEBUG] [edge,stack] [stack_identifier: %d] [message: removing stack]", stack.ID)
-	err := manager.deployer.Remove(ctx, stackName, []string{stackFileLocation})
+	manager.mu.Lock()
+	strategy := manager.strategy
+	manager.mu.Unlock()
+
+	err := strategy.Remove(ctx, manager.deployer, stackName, []string{stackFileLocation})
 	if err != nil {
 		log.Printf("[ERROR] [edge,stack] [message: unable to remove stack] [error: %s]", err)
 		return
@@ -294,7 +494,9 @@ func (manager *StackManager) deleteStack(ctx context.Context, stack *edgeStack,
 	}
 
 	manager.mu.Lock()
-	delete(manager.stacks, stack.ID)
+	if stack.Action == actionDelete {
+		delete(manager.stacks, stack.ID)
+	}
 	manager.mu.Unlock()
 }
 
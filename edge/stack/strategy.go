@@ -0,0 +1,87 @@
+package stack
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/portainer/agent"
+)
+
+const (
+	// StrategyInPlace deploys the new version of a stack over the deployed version, this is the default strategy
+	StrategyInPlace = "in-place"
+	// StrategyRecreate removes the deployed version of a stack before deploying the new version
+	StrategyRecreate = "recreate"
+)
+
+// ReconciliationStrategy decides how the deployed Edge stacks converge to the versions requested
+// by the Portainer instance.
+type ReconciliationStrategy interface {
+	// Deploy deploys a stack that is not deployed yet
+	Deploy(ctx context.Context, deployer agent.Deployer, stackName string, filePaths []string) error
+	// Update converges a deployed stack to a new version
+	Update(ctx context.Context, deployer agent.Deployer, stackName string, filePaths []string) error
+	// Remove removes a deployed stack
+	Remove(ctx context.Context, deployer agent.Deployer, stackName string, filePaths []string) error
+}
+
+var (
+	strategies = map[string]ReconciliationStrategy{
+		StrategyInPlace:  inPlaceStrategy{},
+		StrategyRecreate: recreateStrategy{},
+	}
+	strategiesMu sync.RWMutex
+)
+
+// RegisterReconciliationStrategy makes a reconciliation strategy available under the specified name,
+// replacing any strategy registered under the same name.
+func RegisterReconciliationStrategy(name string, strategy ReconciliationStrategy) {
+	strategiesMu.Lock()
+	defer strategiesMu.Unlock()
+
+	strategies[name] = strategy
+}
+
+func lookupReconciliationStrategy(name string) (ReconciliationStrategy, error) {
+	if name == "" {
+		name = StrategyInPlace
+	}
+
+	strategiesMu.RLock()
+	defer strategiesMu.RUnlock()
+
+	strategy, ok := strategies[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown stack reconciliation strategy %q", name)
+	}
+
+	return strategy, nil
+}
+
+type inPlaceStrategy struct{}
+
+func (inPlaceStrategy) Deploy(ctx context.Context, deployer agent.Deployer, stackName string, filePaths []string) error {
+	return deployer.Deploy(ctx, stackName, filePaths, false)
+}
+
+func (inPlaceStrategy) Update(ctx context.Context, deployer agent.Deployer, stackName string, filePaths []string) error {
+	return deployer.Deploy(ctx, stackName, filePaths, false)
+}
+
+func (inPlaceStrategy) Remove(ctx context.Context, deployer agent.Deployer, stackName string, filePaths []string) error {
+	return deployer.Remove(ctx, stackName, filePaths)
+}
+
+type recreateStrategy struct {
+	inPlaceStrategy
+}
+
+func (recreateStrategy) Update(ctx context.Context, deployer agent.Deployer, stackName string, filePaths []string) error {
+	err := deployer.Remove(ctx, stackName, filePaths)
+	if err != nil {
+		return fmt.Errorf("unable to remove the deployed version of the stack: %w", err)
+	}
+
+	return deployer.Deploy(ctx, stackName, filePaths, false)
+}
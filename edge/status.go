@@ -0,0 +1,97 @@
+package edge
+
+import (
+	"time"
+
+	"github.com/portainer/agent"
+)
+
+const redactedCredentials = "<redacted>"
+
+// PollServiceStatus is a snapshot of the state of the poll service.
+type PollServiceStatus struct {
+	InstanceID            string
+	OperationMode         string
+	StartTime             time.Time
+	Paused                bool
+	PauseReason           string
+	LastTunnelConfig      *agent.TunnelConfig
+	LastTunnelCloseReason string
+	LastTunnelCloseTime   time.Time
+	Negotiation           PollNegotiation
+	Replicas              []PollReplica
+	TunnelOpen            bool
+	LastPollTimings       map[string]time.Duration
+	FeatureFlags          map[string]bool
+	LastStateChange       time.Time
+	TunnelServerHealth    TunnelServerHealth
+	Deregistered          bool
+	TunnelSessions        []TunnelSession
+	SkippedPolls          map[string]uint64
+	LastPollSkip          PollSkip
+	LastSuccessfulPoll    time.Time
+	UnhonoredRequests     []UnhonoredRequest
+	TunnelCredentials     TunnelCredentialsInfo
+	ConvergedAt           time.Time
+	QueuedReports         int
+}
+
+// Status returns a snapshot of the current state of the poll service.
+func (service *PollService) Status() PollServiceStatus {
+	service.statusMu.Lock()
+	defer service.statusMu.Unlock()
+
+	status := service.status
+	status.InstanceID = instanceID
+	status.OperationMode = service.operationMode.name
+	status.StartTime = instanceStartTime
+	if status.LastTunnelConfig != nil {
+		tunnelConfig := *status.LastTunnelConfig
+		status.LastTunnelConfig = &tunnelConfig
+	}
+	status.Replicas = service.replicas.snapshot()
+	if service.reportQueue != nil {
+		status.QueuedReports = service.reportQueue.Len()
+	}
+	if !status.TunnelCredentials.ReceivedAt.IsZero() {
+		status.TunnelCredentials.Age = time.Since(status.TunnelCredentials.ReceivedAt)
+	}
+	status.TunnelSessions = append([]TunnelSession(nil), status.TunnelSessions...)
+	status.UnhonoredRequests = append([]UnhonoredRequest(nil), status.UnhonoredRequests...)
+
+	if status.SkippedPolls != nil {
+		skippedPolls := make(map[string]uint64, len(status.SkippedPolls))
+		for reason, count := range status.SkippedPolls {
+			skippedPolls[reason] = count
+		}
+		status.SkippedPolls = skippedPolls
+	}
+
+	if status.LastPollTimings != nil {
+		timings := make(map[string]time.Duration, len(status.LastPollTimings))
+		for phase, duration := range status.LastPollTimings {
+			timings[phase] = duration
+		}
+		status.LastPollTimings = timings
+	}
+
+	return status
+}
+
+// redactTunnelConfig returns a copy of the tunnel configuration with the credentials and header values masked
+// so that it can safely be logged or exposed.
+func redactTunnelConfig(tunnelConfig agent.TunnelConfig) agent.TunnelConfig {
+	if tunnelConfig.Credentials != "" {
+		tunnelConfig.Credentials = redactedCredentials
+	}
+
+	if len(tunnelConfig.ServerHeaders) > 0 {
+		headers := make(map[string]string, len(tunnelConfig.ServerHeaders))
+		for name := range tunnelConfig.ServerHeaders {
+			headers[name] = redactedCredentials
+		}
+		tunnelConfig.ServerHeaders = headers
+	}
+
+	return tunnelConfig
+}
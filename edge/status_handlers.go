@@ -0,0 +1,104 @@
+package edge
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	tunnelStatusIdle     = "IDLE"
+	tunnelStatusRequired = "REQUIRED"
+)
+
+// statusHandler is used to reconcile the state of the agent with the status returned by the Portainer instance.
+// The context is done once the reconciliation deadline is exceeded.
+type statusHandler func(ctx context.Context, service *PollService, responseData *pollStatusResponse) error
+
+func defaultStatusHandlers() map[string]statusHandler {
+	return map[string]statusHandler{
+		tunnelStatusIdle:     handleIdleStatus,
+		tunnelStatusRequired: handleRequiredStatus,
+	}
+}
+
+func (service *PollService) handleStatus(ctx context.Context, responseData *pollStatusResponse) error {
+	if responseData.Status != tunnelStatusRequired && responseData.Credentials != "" {
+		service.handleUnexpectedCredentials(responseData)
+	}
+
+	handler, ok := service.statusHandlers[responseData.Status]
+	if !ok {
+		log.Printf("[WARN] [edge] [status: %s] [message: unknown status received from the Portainer instance, ignoring it]", responseData.Status)
+		service.recordUnhonoredRequest(unhonoredRequestStatus, fmt.Sprintf("unknown status %s", responseData.Status))
+		return nil
+	}
+
+	return handler(ctx, service, responseData)
+}
+
+func handleIdleStatus(ctx context.Context, service *PollService, responseData *pollStatusResponse) error {
+	service.tunnelStaggerUntil = time.Time{}
+
+	if !service.isTunnelOpen() {
+		return nil
+	}
+
+	log.Printf("[DEBUG] [edge] [status: %s] [message: Idle status detected, shutting down tunnel]", responseData.Status)
+
+	err := service.closeTunnel(tunnelCloseReasonIdle)
+	if err != nil {
+		log.Printf("[ERROR] [edge] [message: Unable to shutdown tunnel] [error: %s]", err)
+	}
+	service.recordPollAction(pollActionTunnelClosed, tunnelCloseReasonIdle, err)
+
+	return nil
+}
+
+func handleRequiredStatus(ctx context.Context, service *PollService, responseData *pollStatusResponse) error {
+	if service.tunnelClient == nil {
+		service.recordUnhonoredRequest(unhonoredRequestTunnel, "tunnel support is disabled")
+		return nil
+	}
+
+	if service.isTunnelOpen() {
+		return nil
+	}
+
+	if atomic.LoadInt32(&service.tunnelCreating) == 1 {
+		log.Println("[DEBUG] [edge] [message: a reverse tunnel creation is still in progress]")
+		service.deferReconciliation("tunnel creation in progress")
+		return nil
+	}
+
+	if service.staggerTunnelCreation() {
+		service.deferReconciliation("tunnel creation staggered")
+		return nil
+	}
+
+	log.Println("[DEBUG] [edge] [message: Required status detected, creating reverse tunnel]")
+
+	if responseData.Credentials == "" && !service.hasCachedCredentials(time.Now()) {
+		log.Printf("[ERROR] [edge] [port: %d] [message: Unable to create tunnel] [error: %s]", responseData.Port, errMissingTunnelCredentials)
+		service.recordPollAction(pollActionTunnelCreated, fmt.Sprintf("port %d", responseData.Port), errMissingTunnelCredentials)
+		return errMissingTunnelCredentials
+	}
+
+	if service.tunnelPortRange != nil && !service.tunnelPortRange.contains(responseData.Port) {
+		log.Printf("[WARN] [edge] [port: %d] [expected_range: %d-%d] [message: tunnel port assigned by the Portainer instance is outside of the expected range, the Portainer instance might be misconfigured]", responseData.Port, service.tunnelPortRange.Min, service.tunnelPortRange.Max)
+	}
+
+	err := service.createTunnel(ctx, responseData.Credentials, responseData.Port)
+	service.recordPollAction(pollActionTunnelCreated, fmt.Sprintf("port %d", responseData.Port), err)
+	if err != nil {
+		log.Printf("[ERROR] [edge] [message: Unable to create tunnel] [error: %s]", err)
+		return err
+	}
+
+	// Poll again right away to confirm that the tunnel is still required and sync the rest of the state
+	service.triggerPoll()
+
+	return nil
+}
@@ -0,0 +1,108 @@
+package edge
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/portainer/agent"
+)
+
+type fakeTunnelClient struct {
+	created int
+}
+
+func (client *fakeTunnelClient) CreateTunnel(config agent.TunnelConfig) error {
+	client.created++
+	return nil
+}
+
+func (client *fakeTunnelClient) CloseTunnel() error       { return nil }
+func (client *fakeTunnelClient) IsTunnelOpen() bool       { return false }
+func (client *fakeTunnelClient) Stats() agent.TunnelStats { return agent.TunnelStats{} }
+
+func TestHandleRequiredStatusWithoutCredentials(t *testing.T) {
+	tunnelClient := &fakeTunnelClient{}
+	service := &PollService{
+		tunnelClient: tunnelClient,
+		metrics:      noopMetricsSink{},
+	}
+
+	err := handleRequiredStatus(context.Background(), service, &pollStatusResponse{Status: tunnelStatusRequired, Port: 20000})
+	if !errors.Is(err, errMissingTunnelCredentials) {
+		t.Errorf("expected the missing credentials error, got %v", err)
+	}
+
+	if tunnelClient.created != 0 {
+		t.Errorf("expected no tunnel creation attempt, got %d", tunnelClient.created)
+	}
+
+	actions := service.pollSummary.Actions
+	if len(actions) != 1 || actions[0].Success {
+		t.Errorf("expected a single failed tunnel action, got %+v", actions)
+	}
+
+	service.credentialsCache = &cachedCredentials{encoded: "cached", decrypted: "user:password", expiresAt: time.Now().Add(time.Minute)}
+	if !service.hasCachedCredentials(time.Now()) {
+		t.Error("expected the cached credentials to be usable when the Portainer instance sends none")
+	}
+}
+
+type failingCloseTunnelClient struct {
+	fakeTunnelClient
+}
+
+func (client *failingCloseTunnelClient) CloseTunnel() error { return errors.New("close failure") }
+
+func TestCloseTunnelFailureClearsTrackedState(t *testing.T) {
+	service := &PollService{
+		tunnelClient:      &failingCloseTunnelClient{},
+		tunnelStateSource: tunnelStateSourceAgent,
+		metrics:           noopMetricsSink{},
+	}
+	service.setTunnelOpen(true)
+
+	err := service.closeTunnel(tunnelCloseReasonIdle)
+	if err == nil {
+		t.Fatal("expected the close error to be returned")
+	}
+
+	if service.isTunnelOpen() {
+		t.Error("expected the tunnel to be considered closed after a failed closure")
+	}
+}
+
+type blockingTunnelClient struct {
+	fakeTunnelClient
+	release chan struct{}
+}
+
+func (client *blockingTunnelClient) CreateTunnel(config agent.TunnelConfig) error {
+	<-client.release
+	return nil
+}
+
+func TestCreateTunnelRespectsReconcileDeadline(t *testing.T) {
+	// The tunnel client is never released, the creation stays in progress until the end of the test
+	tunnelClient := &blockingTunnelClient{release: make(chan struct{})}
+
+	service := &PollService{
+		tunnelClient:     tunnelClient,
+		metrics:          noopMetricsSink{},
+		credentialsCache: &cachedCredentials{decrypted: "user:password", expiresAt: time.Now().Add(time.Minute)},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	err := service.createTunnel(ctx, "", 20000)
+	if !errors.Is(err, errReconcileDeadlineExceeded) {
+		t.Fatalf("expected the reconciliation deadline error, got %v", err)
+	}
+
+	err = handleRequiredStatus(context.Background(), service, &pollStatusResponse{Status: tunnelStatusRequired, Port: 20000, Credentials: "credentials"})
+	if err != nil {
+		t.Errorf("expected the pending tunnel creation to be awaited, got %v", err)
+	}
+}
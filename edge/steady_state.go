@@ -0,0 +1,77 @@
+package edge
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/portainer/agent"
+)
+
+const eventReasonSteadyState = "SteadyState"
+
+// responseStateHash returns a hash of the state requested by the Portainer instance in a poll response,
+// the tunnel credentials are not part of the state.
+func responseStateHash(responseData *pollStatusResponse) (string, error) {
+	data, err := json.Marshal(struct {
+		Status          string
+		Port            int
+		Schedules       []agent.Schedule
+		CheckinInterval float64
+		Stacks          []stackStatus
+	}{
+		Status:          responseData.Status,
+		Port:            responseData.Port,
+		Schedules:       responseData.Schedules,
+		CheckinInterval: responseData.CheckinInterval,
+		Stacks:          responseData.Stacks,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// trackSteadyState records when the state requested by the Portainer instance last changed. Once the state
+// stayed unchanged for the steady state duration, an event confirming that the agent is healthy but has nothing
+// to do is emitted at the configured interval, so that the steady state is not mistaken for a hung agent.
+func (service *PollService) trackSteadyState(responseData *pollStatusResponse, now time.Time) {
+	hash, err := responseStateHash(responseData)
+	if err != nil {
+		return
+	}
+
+	if hash != service.stateHash {
+		service.stateHash = hash
+		service.lastSteadyStateEvent = time.Time{}
+
+		service.statusMu.Lock()
+		service.status.LastStateChange = now
+		service.statusMu.Unlock()
+
+		return
+	}
+
+	if service.steadyStateAfter <= 0 {
+		return
+	}
+
+	service.statusMu.Lock()
+	unchanged := now.Sub(service.status.LastStateChange)
+	service.statusMu.Unlock()
+
+	if unchanged < service.steadyStateAfter {
+		return
+	}
+
+	if !service.lastSteadyStateEvent.IsZero() && now.Sub(service.lastSteadyStateEvent) < service.steadyStateInterval {
+		return
+	}
+
+	service.lastSteadyStateEvent = now
+	service.emitEvent(eventReasonSteadyState, eventSeverityNormal, fmt.Sprintf("agent is polling successfully, the state requested by the Portainer instance is unchanged since %s", unchanged.Round(time.Second)))
+}
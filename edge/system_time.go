@@ -0,0 +1,61 @@
+package edge
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+const (
+	// systemTimeCheckOff starts polling regardless of the system time
+	systemTimeCheckOff = "off"
+	// systemTimeCheckWarn starts polling with an error logged when the system time is implausible
+	systemTimeCheckWarn = "warn"
+	// systemTimeCheckWait waits for the system time to be plausible, usually once synchronized, before starting polling
+	systemTimeCheckWait = "wait"
+
+	systemTimeCheckInterval = 5 * time.Second
+)
+
+// minimumSystemTime is earlier than the build of the agent, a system time before it is clearly wrong. It usually
+// happens on devices without a real time clock that booted before their clock was synchronized.
+var minimumSystemTime = time.Date(2022, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+func parseSystemTimeCheck(value string) (string, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "", systemTimeCheckOff:
+		return systemTimeCheckOff, nil
+	case systemTimeCheckWarn:
+		return systemTimeCheckWarn, nil
+	case systemTimeCheckWait:
+		return systemTimeCheckWait, nil
+	}
+
+	return "", fmt.Errorf("invalid system time check %q, expected %s, %s or %s", value, systemTimeCheckOff, systemTimeCheckWarn, systemTimeCheckWait)
+}
+
+// checkSystemTime returns false when polling must not start yet because the system time is implausible and the
+// agent is configured to wait for it to be synchronized.
+func (service *PollService) checkSystemTime(now time.Time) bool {
+	if service.systemTimeCheck == systemTimeCheckOff || !now.Before(minimumSystemTime) {
+		if service.waitingForSystemTime {
+			log.Printf("[INFO] [edge] [system_time: %s] [message: system time is now plausible, starting the short poll]", now.Format(time.RFC3339))
+			service.waitingForSystemTime = false
+		}
+
+		return true
+	}
+
+	if service.systemTimeCheck == systemTimeCheckWarn {
+		log.Printf("[ERROR] [edge] [system_time: %s] [minimum_time: %s] [message: system time is clearly wrong, TLS certificate validation and time based features will fail until the clock is synchronized]", now.Format(time.RFC3339), minimumSystemTime.Format(time.RFC3339))
+		return true
+	}
+
+	if !service.waitingForSystemTime {
+		log.Printf("[WARN] [edge] [system_time: %s] [minimum_time: %s] [message: system time is clearly wrong, waiting for the clock to be synchronized before starting the short poll]", now.Format(time.RFC3339), minimumSystemTime.Format(time.RFC3339))
+		service.waitingForSystemTime = true
+	}
+
+	return false
+}
@@ -0,0 +1,93 @@
+package edge
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"strings"
+)
+
+const (
+	tlsRenegotiationNever  = "never"
+	tlsRenegotiationOnce   = "once"
+	tlsRenegotiationFreely = "freely"
+)
+
+func parseTLSRenegotiation(value string) (tls.RenegotiationSupport, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "", tlsRenegotiationNever:
+		return tls.RenegotiateNever, nil
+	case tlsRenegotiationOnce:
+		return tls.RenegotiateOnceAsClient, nil
+	case tlsRenegotiationFreely:
+		return tls.RenegotiateFreelyAsClient, nil
+	}
+
+	return tls.RenegotiateNever, fmt.Errorf("invalid TLS renegotiation setting %q, expected %s, %s or %s", value, tlsRenegotiationNever, tlsRenegotiationOnce, tlsRenegotiationFreely)
+}
+
+// newTLSSessionCache returns the cache used to resume the TLS sessions across the poll requests, so that a full
+// handshake is not required for every poll. A size of 0 disables the session resumption.
+func newTLSSessionCache(size int) (tls.ClientSessionCache, error) {
+	if size < 0 {
+		return nil, fmt.Errorf("invalid TLS session cache size %d, must not be negative", size)
+	}
+
+	if size == 0 {
+		return nil, nil
+	}
+
+	return tls.NewLRUClientSessionCache(size), nil
+}
+
+// loadCABundle returns the system trust store extended with the certificate authorities of the comma separated list
+// of PEM files. It returns nil when no file is specified so that the system trust store is used as is.
+func loadCABundle(files string) (*x509.CertPool, error) {
+	var paths []string
+	for _, path := range strings.Split(files, ",") {
+		path = strings.TrimSpace(path)
+		if path != "" {
+			paths = append(paths, path)
+		}
+	}
+
+	if len(paths) == 0 {
+		return nil, nil
+	}
+
+	pool, err := x509.SystemCertPool()
+	if err != nil || pool == nil {
+		pool = x509.NewCertPool()
+	}
+
+	for _, path := range paths {
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read CA file %s: %w", path, err)
+		}
+
+		if !pool.AppendCertsFromPEM(content) {
+			return nil, fmt.Errorf("no valid PEM certificate found in CA file %s", path)
+		}
+	}
+
+	return pool, nil
+}
+
+// tlsConfig returns the TLS configuration of the poll HTTP client. The session cache is shared by the successive
+// clients so that the sessions survive the refresh of the client.
+func (service *PollService) tlsConfig(insecure bool) *tls.Config {
+	config := &tls.Config{
+		InsecureSkipVerify: insecure,
+		RootCAs:            service.rootCAs,
+		ClientSessionCache: service.tlsSessionCache,
+		Renegotiation:      service.tlsRenegotiation,
+	}
+
+	if service.clientCert != nil {
+		config.GetClientCertificate = service.clientCert.getClientCertificate
+	}
+
+	return config
+}
@@ -0,0 +1,70 @@
+package edge
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// pollTransportHTTP retrieves the status of the Edge endpoint with poll requests sent to the Portainer instance
+const pollTransportHTTP = "http"
+
+// statusTransport retrieves the status of the Edge endpoint from the Portainer instance. The status is reconciled
+// by the poll service in the same way regardless of the transport, each poll cycle fetches the status once.
+type statusTransport interface {
+	// name returns the name under which the transport is registered
+	name() string
+	// fetchStatus returns the latest status of the Edge endpoint. A push based transport returns nil when no
+	// status was received since the previous call, the reconciliation is then skipped for the cycle.
+	fetchStatus() (*pollStatusResponse, error)
+}
+
+// statusTransportFactory creates a transport bound to a poll service.
+type statusTransportFactory func(service *PollService) (statusTransport, error)
+
+var statusTransports = map[string]statusTransportFactory{
+	pollTransportHTTP: newHTTPStatusTransport,
+}
+
+// registerStatusTransport makes a transport available under a name, replacing any existing transport for that name.
+// Alternative transports register themselves from an init function.
+func registerStatusTransport(name string, factory statusTransportFactory) {
+	statusTransports[name] = factory
+}
+
+func newStatusTransport(name string, service *PollService) (statusTransport, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" {
+		name = pollTransportHTTP
+	}
+
+	factory, ok := statusTransports[name]
+	if !ok {
+		names := make([]string, 0, len(statusTransports))
+		for name := range statusTransports {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		return nil, fmt.Errorf("invalid poll transport %q, expected one of %s", name, strings.Join(names, ", "))
+	}
+
+	return factory(service)
+}
+
+// httpStatusTransport is the default transport, it polls the Portainer instance over HTTP.
+type httpStatusTransport struct {
+	service *PollService
+}
+
+func newHTTPStatusTransport(service *PollService) (statusTransport, error) {
+	return &httpStatusTransport{service: service}, nil
+}
+
+func (transport *httpStatusTransport) name() string {
+	return pollTransportHTTP
+}
+
+func (transport *httpStatusTransport) fetchStatus() (*pollStatusResponse, error) {
+	return transport.service.pollStatus()
+}
@@ -0,0 +1,146 @@
+package edge
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/portainer/agent/edge/client"
+)
+
+func TestPortainerClientTrustsTheCABundle(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	rootCAs := x509.NewCertPool()
+	rootCAs.AddCert(server.Certificate())
+	service := &PollService{rootCAs: rootCAs}
+
+	portainerClient := client.NewPortainerClient(server.URL, "1", "edge-id", false)
+
+	err := portainerClient.SendJobLogFile(1, []byte("logs"))
+	if err == nil {
+		t.Fatal("expected the certificate of the Portainer instance to be rejected without the CA bundle")
+	}
+
+	portainerClient.SetTransport(service.newTransport(false))
+
+	err = portainerClient.SendJobLogFile(1, []byte("logs"))
+	if err != nil {
+		t.Errorf("expected the certificate of the Portainer instance to be trusted with the CA bundle, got %s", err)
+	}
+}
+
+func TestCABundleProvisionedAfterStartupIsLoaded(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	go func() {
+		// The CA bundle appears after the first attempt
+		time.Sleep(100 * time.Millisecond)
+		ioutil.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}), 0600)
+	}()
+
+	service := &PollService{}
+	err := service.initHTTPClient(&pollServiceConfig{PollCAFiles: caFile, ClientInitRetries: 3})
+	if err != nil {
+		t.Fatalf("expected the HTTP client to be created once the CA bundle is available, got %s", err)
+	}
+
+	resp, err := service.httpClient.Get(server.URL)
+	if err != nil {
+		t.Fatalf("expected the certificate of the Portainer instance to be trusted with the CA bundle, got %s", err)
+	}
+	resp.Body.Close()
+}
+
+func TestPortainerClientUsesTheProxy(t *testing.T) {
+	proxied := make(chan string, 1)
+	proxyServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied <- r.URL.String()
+	}))
+	defer proxyServer.Close()
+
+	proxyURL, _ := url.Parse(proxyServer.URL)
+	service := &PollService{proxyURL: proxyURL}
+
+	portainerClient := client.NewPortainerClient("http://portainer.example.com", "1", "edge-id", false)
+	portainerClient.SetTransport(service.newTransport(false))
+
+	err := portainerClient.SendJobLogFile(1, []byte("logs"))
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+
+	select {
+	case requestURL := <-proxied:
+		if requestURL != "http://portainer.example.com/api/endpoints/1/edge/jobs/1/logs" {
+			t.Errorf("unexpected URL requested through the proxy: %s", requestURL)
+		}
+	default:
+		t.Error("expected the report to be sent through the proxy")
+	}
+}
+
+func TestConnectionsUseTheSOCKS5Dialer(t *testing.T) {
+	dialed := []string{}
+	service := &PollService{
+		socksDialer: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			dialed = append(dialed, addr)
+			return nil, errors.New("unreachable")
+		},
+	}
+
+	portainerClient := client.NewPortainerClient("http://portainer.example.com", "1", "edge-id", false)
+	portainerClient.SetTransport(service.newTransport(false))
+	portainerClient.SendJobLogFile(1, []byte("logs"))
+
+	service.dialTimeout("tunnel.example.com:8000", time.Second)
+
+	if len(dialed) != 2 || dialed[0] != "portainer.example.com:80" || dialed[1] != "tunnel.example.com:8000" {
+		t.Errorf("expected the connections to be opened by the SOCKS5 dialer, got %v", dialed)
+	}
+}
+
+func TestPortainerClientFollowsTheActiveInstance(t *testing.T) {
+	requested := make(chan string, 2)
+	newServer := func(name string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requested <- name
+		}))
+	}
+
+	primary := newServer("primary")
+	defer primary.Close()
+	secondary := newServer("secondary")
+	defer secondary.Close()
+
+	selector, err := newFailoverSelector([]string{primary.URL, secondary.URL}, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	portainerClient := client.NewPortainerClient(primary.URL, "1", "edge-id", false)
+	portainerClient.SetServerAddress(selector.activeURL)
+
+	selector.recordResult(selector.replicas[1], true)
+
+	err = portainerClient.SetEdgeStackStatus(1, 1, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if name := <-requested; name != "secondary" {
+		t.Errorf("expected the report to be sent to the active instance, got %s", name)
+	}
+}
@@ -0,0 +1,244 @@
+package edge
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/portainer/agent"
+)
+
+const (
+	// pollTransportWebSocket receives the status of the Edge endpoint pushed by the Portainer instance over a WebSocket
+	pollTransportWebSocket = "websocket"
+
+	webSocketHandshakeTimeout = 10 * time.Second
+	webSocketReconnectDelay   = 10 * time.Second
+	// webSocketSafetyPollInterval is the interval at which the status is still polled over HTTP while the connection
+	// is established, so that a status missed by the connection is eventually applied
+	webSocketSafetyPollInterval = 5 * time.Minute
+)
+
+func init() {
+	registerStatusTransport(pollTransportWebSocket, newWebSocketStatusTransport)
+}
+
+// webSocketStatusTransport maintains a persistent WebSocket connection to the Portainer instance, which pushes the
+// status of the Edge endpoint whenever it changes. An immediate poll cycle is triggered when a status is received so
+// that it is reconciled without waiting for the poll interval. The status is polled over HTTP while the connection
+// is not established, and at a low frequency while it is.
+type webSocketStatusTransport struct {
+	service   *PollService
+	fallback  *httpStatusTransport
+	start     sync.Once
+	mu        sync.Mutex
+	connected bool
+	latest    *pollStatusResponse
+	lastPoll  time.Time
+}
+
+// pushedStatus is a message pushed over the status WebSocket. The date and the signature are the values of the Date
+// and signature headers of an HTTP poll response, the status is the body of the response. The message is therefore
+// validated as a poll response.
+type pushedStatus struct {
+	Date      string          `json:"date"`
+	Signature string          `json:"signature,omitempty"`
+	Status    json.RawMessage `json:"status"`
+}
+
+func newWebSocketStatusTransport(service *PollService) (statusTransport, error) {
+	return &webSocketStatusTransport{
+		service:  service,
+		fallback: &httpStatusTransport{service: service},
+	}, nil
+}
+
+func (transport *webSocketStatusTransport) name() string {
+	return pollTransportWebSocket
+}
+
+func (transport *webSocketStatusTransport) fetchStatus() (*pollStatusResponse, error) {
+	transport.start.Do(func() {
+		transport.service.runLoop(transport.run)
+	})
+
+	transport.mu.Lock()
+	connected := transport.connected
+	latest := transport.latest
+	transport.latest = nil
+	transport.mu.Unlock()
+
+	if latest != nil {
+		return latest, nil
+	}
+
+	if !connected || time.Since(transport.lastPoll) >= webSocketSafetyPollInterval {
+		transport.lastPoll = time.Now()
+		return transport.fallback.fetchStatus()
+	}
+
+	return nil, nil
+}
+
+// run keeps the WebSocket connection open until the poll service is closed, the connection is established again
+// after a delay when it is lost.
+func (transport *webSocketStatusTransport) run() {
+	ctx := transport.service.ctx
+
+	for {
+		err := transport.receive()
+		if ctx.Err() != nil {
+			return
+		}
+
+		log.Printf("[WARN] [edge] [retry_delay_seconds: %f] [message: status WebSocket connection lost, polling the Portainer instance until the connection is established again] [error: %s]", webSocketReconnectDelay.Seconds(), err)
+
+		select {
+		case <-time.After(webSocketReconnectDelay):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// receive opens the WebSocket connection and records the statuses pushed by the Portainer instance until the
+// connection is closed.
+func (transport *webSocketStatusTransport) receive() error {
+	service := transport.service
+
+	wsURL, err := webSocketStatusURL(service.replicas.activeURL(), service.endpointID)
+	if err != nil {
+		return err
+	}
+
+	header := http.Header{}
+	header.Set(agent.HTTPEdgeIdentifierHeaderName, service.edgeID)
+	setInstanceHeaders(&http.Request{Header: header})
+
+	dialer := &websocket.Dialer{
+		Proxy:            service.proxy(),
+		NetDialContext:   service.socksDialer,
+		TLSClientConfig:  service.tlsConfig(service.insecurePoll),
+		HandshakeTimeout: webSocketHandshakeTimeout,
+	}
+
+	conn, _, err := dialer.DialContext(service.ctx, wsURL, header)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	log.Printf("[DEBUG] [edge] [url: %s] [message: status WebSocket connection established]", wsURL)
+
+	transport.setConnected(true)
+	defer transport.setConnected(false)
+
+	done := make(chan struct{})
+	defer close(done)
+
+	go func() {
+		select {
+		case <-service.ctx.Done():
+			conn.Close()
+		case <-done:
+		}
+	}()
+
+	tlsVerified := isSecureWebSocketURL(wsURL) && !service.insecurePoll
+
+	for {
+		_, message, err := conn.ReadMessage()
+		if err != nil {
+			return err
+		}
+
+		response, err := service.decodePushedStatus(message, tlsVerified, time.Now())
+		if err != nil {
+			log.Printf("[WARN] [edge] [message: ignoring the status pushed over the WebSocket] [error: %s]", err)
+			continue
+		}
+
+		transport.mu.Lock()
+		transport.latest = response
+		transport.mu.Unlock()
+
+		service.triggerPoll()
+	}
+}
+
+// decodePushedStatus validates a status pushed over the WebSocket with the same checks as a poll response and
+// decodes it.
+func (service *PollService) decodePushedStatus(message []byte, tlsVerified bool, now time.Time) (*pollStatusResponse, error) {
+	var pushed pushedStatus
+	err := json.Unmarshal(message, &pushed)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &http.Response{
+		Header: http.Header{},
+		Body:   ioutil.NopCloser(bytes.NewReader(pushed.Status)),
+	}
+	if pushed.Date != "" {
+		resp.Header.Set("Date", pushed.Date)
+	}
+	if pushed.Signature != "" {
+		resp.Header.Set(agent.HTTPEdgeResponseSignatureHeaderName, pushed.Signature)
+	}
+
+	err = service.validatePollResponse(resp, now)
+	if err != nil {
+		return nil, err
+	}
+
+	var response pollStatusResponse
+	err = json.NewDecoder(resp.Body).Decode(&response)
+	if err != nil {
+		return nil, err
+	}
+
+	response.tlsVerified = tlsVerified
+	response.signatureVerified = service.responseSigningKey != nil
+
+	return &response, nil
+}
+
+func isSecureWebSocketURL(wsURL string) bool {
+	u, err := url.Parse(wsURL)
+	return err == nil && u.Scheme == "wss"
+}
+
+func (transport *webSocketStatusTransport) setConnected(connected bool) {
+	transport.mu.Lock()
+	transport.connected = connected
+	transport.mu.Unlock()
+}
+
+// webSocketStatusURL returns the URL of the status WebSocket of an Edge endpoint.
+func webSocketStatusURL(portainerURL, endpointID string) (string, error) {
+	u, err := url.Parse(portainerURL)
+	if err != nil {
+		return "", err
+	}
+
+	switch u.Scheme {
+	case "https":
+		u.Scheme = "wss"
+	case "http":
+		u.Scheme = "ws"
+	default:
+		return "", fmt.Errorf("unsupported Portainer instance URL scheme %q", u.Scheme)
+	}
+
+	u.Path = fmt.Sprintf("%s/api/endpoints/%s/edge/status/ws", u.Path, endpointID)
+
+	return u.String(), nil
+}
@@ -0,0 +1,34 @@
+package edge
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestDecodePushedStatusChecksFreshness(t *testing.T) {
+	now := time.Now()
+	service := &PollService{responseMaxAge: time.Minute}
+
+	tests := []struct {
+		name    string
+		message string
+		wantErr bool
+	}{
+		{name: "fresh", message: `{"date":"` + now.UTC().Format(http.TimeFormat) + `","status":{"status":"IDLE"}}`},
+		{name: "stale", message: `{"date":"` + now.Add(-time.Hour).UTC().Format(http.TimeFormat) + `","status":{"status":"IDLE"}}`, wantErr: true},
+		{name: "undated", message: `{"status":{"status":"IDLE"}}`, wantErr: true},
+	}
+
+	for _, test := range tests {
+		response, err := service.decodePushedStatus([]byte(test.message), true, now)
+		if (err != nil) != test.wantErr {
+			t.Errorf("%s: unexpected error %v", test.name, err)
+			continue
+		}
+
+		if err == nil && response.Status != "IDLE" {
+			t.Errorf("%s: expected the IDLE status, got %q", test.name, response.Status)
+		}
+	}
+}
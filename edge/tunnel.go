@@ -0,0 +1,178 @@
+package edge
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// tunnelCloseReasonIdle is used when the Portainer instance reports an idle status
+	tunnelCloseReasonIdle = "idle"
+	// tunnelCloseReasonInactivity is used when no activity was registered on the tunnel for the inactivity timeout
+	tunnelCloseReasonInactivity = "inactivity"
+	// tunnelCloseReasonLocalUnreachable is used when the local address targeted by the tunnel stopped accepting connections
+	tunnelCloseReasonLocalUnreachable = "local-unreachable"
+	// tunnelCloseReasonManual is used when the tunnel is closed through the diagnostics API
+	tunnelCloseReasonManual = "manual"
+	// tunnelCloseReasonPollOutage is used when the Portainer instance could not be polled for the outage timeout
+	tunnelCloseReasonPollOutage = "poll-outage"
+)
+
+const (
+	eventReasonTunnelOpened = "TunnelOpened"
+	eventReasonTunnelClosed = "TunnelClosed"
+)
+
+const (
+	tunnelReadinessCheckAttempts = 5
+	tunnelReadinessCheckDelay    = 1 * time.Second
+	tunnelReadinessDialTimeout   = 2 * time.Second
+)
+
+// checkLocalAddrReadiness ensures that the local address targeted by the reverse tunnel accepts TCP connections,
+// it retries a few times before giving up to leave some time for the agent API server to start. It gives up as soon
+// as the context is done.
+func checkLocalAddrReadiness(ctx context.Context, addr string) error {
+	var err error
+
+	for attempt := 1; attempt <= tunnelReadinessCheckAttempts; attempt++ {
+		var conn net.Conn
+		conn, err = net.DialTimeout("tcp", addr, tunnelReadinessDialTimeout)
+		if err == nil {
+			conn.Close()
+			return nil
+		}
+
+		log.Printf("[DEBUG] [edge] [local_addr: %s] [attempt: %d] [message: tunnel local address is not reachable yet] [error: %s]", addr, attempt, err)
+
+		if attempt < tunnelReadinessCheckAttempts {
+			select {
+			case <-time.After(tunnelReadinessCheckDelay):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+	}
+
+	return err
+}
+
+// closeTunnel closes the reverse tunnel and records the reason of the closure.
+func (service *PollService) closeTunnel(reason string) error {
+	log.Printf("[DEBUG] [edge] [reason: %s] [message: closing reverse tunnel]", reason)
+
+	stats := service.tunnelClient.Stats()
+
+	// The tunnel client considers the tunnel closed even when the closure fails, the tracked state must follow
+	// so that the tunnel can be opened again
+	err := service.tunnelClient.CloseTunnel()
+	service.setTunnelOpen(false)
+	if err == nil {
+		service.recordTunnelClosed(reason, stats)
+		service.emitEvent(eventReasonTunnelClosed, eventSeverityNormal, fmt.Sprintf("reverse tunnel closed, reason: %s", reason))
+
+		// The tunnel may still be required by the Portainer instance
+		service.invalidatePollETag()
+	}
+
+	service.statusMu.Lock()
+	service.status.LastTunnelCloseReason = reason
+	service.status.LastTunnelCloseTime = time.Now()
+	service.statusMu.Unlock()
+
+	service.metrics.IncrCounter(metricTunnelClosed)
+	service.metrics.Gauge(metricTunnelOpen, boolGauge(service.isTunnelOpen()))
+
+	return err
+}
+
+// parseHeaders parses a comma separated list of headers in the Name=Value format.
+func parseHeaders(value string) (map[string]string, error) {
+	headers := map[string]string{}
+
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" {
+			return nil, fmt.Errorf("invalid header %q, expected Name=Value", entry)
+		}
+
+		headers[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+	}
+
+	return headers, nil
+}
+
+// portRange is an inclusive range of TCP ports.
+type portRange struct {
+	Min int
+	Max int
+}
+
+func (r *portRange) contains(port int) bool {
+	return port >= r.Min && port <= r.Max
+}
+
+// parsePortRange parses a port range in the MIN-MAX format, an empty value returns a nil range.
+func parsePortRange(value string) (*portRange, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil, nil
+	}
+
+	parts := strings.SplitN(value, "-", 2)
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("invalid port range %q, expected MIN-MAX", value)
+	}
+
+	min, err := strconv.Atoi(strings.TrimSpace(parts[0]))
+	if err != nil {
+		return nil, fmt.Errorf("invalid port range %q, expected MIN-MAX", value)
+	}
+
+	max, err := strconv.Atoi(strings.TrimSpace(parts[1]))
+	if err != nil {
+		return nil, fmt.Errorf("invalid port range %q, expected MIN-MAX", value)
+	}
+
+	if min < 1 || max > 65535 || min > max {
+		return nil, fmt.Errorf("invalid port range %q, ports must be between 1 and 65535 and MIN must not be greater than MAX", value)
+	}
+
+	return &portRange{Min: min, Max: max}, nil
+}
+
+// staggerTunnelCreation returns true when the creation of the tunnel must be delayed. A random delay, up to the
+// configured maximum stagger, is picked the first time the tunnel is required and the Portainer instance is polled
+// again once the delay has elapsed, so that the tunnel is only created if it is still required.
+func (service *PollService) staggerTunnelCreation() bool {
+	if service.tunnelMaxStagger <= 0 {
+		return false
+	}
+
+	if service.tunnelStaggerUntil.IsZero() {
+		delay := time.Duration(service.random.Int63n(int64(service.tunnelMaxStagger)))
+		service.tunnelStaggerUntil = time.Now().Add(delay)
+
+		log.Printf("[DEBUG] [edge] [delay_seconds: %f] [message: delaying the creation of the reverse tunnel]", delay.Seconds())
+
+		time.AfterFunc(delay, service.triggerPoll)
+		return true
+	}
+
+	if time.Now().Before(service.tunnelStaggerUntil) {
+		return true
+	}
+
+	service.tunnelStaggerUntil = time.Time{}
+	return false
+}
@@ -0,0 +1,57 @@
+package edge
+
+import (
+	"log"
+	"time"
+)
+
+const tunnelServerDialTimeout = 5 * time.Second
+
+// TunnelServerHealth is the result of the last reachability check of the tunnel server.
+type TunnelServerHealth struct {
+	Reachable bool
+	Latency   time.Duration
+	CheckedAt time.Time
+	Error     string
+}
+
+// startTunnelServerHealthLoop periodically checks that the tunnel server accepts TCP connections, independently
+// of the polls, so that a tunnel server that cannot be reached is detected before a tunnel is required.
+func (service *PollService) startTunnelServerHealthLoop(interval time.Duration) {
+	log.Printf("[DEBUG] [edge] [tunnel_server_addr: %s] [check_interval_seconds: %f] [message: starting tunnel server health checks]", service.tunnelServerAddr, interval.Seconds())
+
+	service.checkTunnelServerHealth()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			service.checkTunnelServerHealth()
+		case <-service.ctx.Done():
+			return
+		}
+	}
+}
+
+func (service *PollService) checkTunnelServerHealth() {
+	health := TunnelServerHealth{CheckedAt: time.Now()}
+
+	conn, err := service.dialTimeout(service.tunnelServerAddr, tunnelServerDialTimeout)
+	health.Latency = time.Since(health.CheckedAt)
+	if err != nil {
+		health.Error = err.Error()
+		log.Printf("[DEBUG] [edge] [tunnel_server_addr: %s] [message: tunnel server is not reachable] [error: %s]", service.tunnelServerAddr, err)
+	} else {
+		conn.Close()
+		health.Reachable = true
+		service.metrics.Timing(metricTunnelServerLatency, health.Latency)
+	}
+
+	service.metrics.Gauge(metricTunnelServerReachable, boolGauge(health.Reachable))
+
+	service.statusMu.Lock()
+	service.status.TunnelServerHealth = health
+	service.statusMu.Unlock()
+}
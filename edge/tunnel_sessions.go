@@ -0,0 +1,57 @@
+package edge
+
+import (
+	"time"
+
+	"github.com/portainer/agent"
+)
+
+// TunnelSession is a reverse tunnel that was opened by the agent. The credentials of the tunnel are never recorded.
+type TunnelSession struct {
+	OpenedAt    time.Time
+	ClosedAt    time.Time
+	CloseReason string
+	Port        int
+	// BytesIn and BytesOut are only available when the traffic going through the tunnel is tracked
+	BytesIn  uint64
+	BytesOut uint64
+}
+
+// recordTunnelOpened adds a session to the history of the tunnel sessions, the oldest session is dropped
+// once the history is full.
+func (service *PollService) recordTunnelOpened(port int) {
+	if service.tunnelSessionHistory <= 0 {
+		return
+	}
+
+	service.statusMu.Lock()
+	defer service.statusMu.Unlock()
+
+	sessions := append(service.status.TunnelSessions, TunnelSession{OpenedAt: time.Now(), Port: port})
+	if len(sessions) > service.tunnelSessionHistory {
+		sessions = sessions[len(sessions)-service.tunnelSessionHistory:]
+	}
+
+	service.status.TunnelSessions = sessions
+}
+
+// recordTunnelClosed completes the most recent session of the history of the tunnel sessions.
+func (service *PollService) recordTunnelClosed(reason string, stats agent.TunnelStats) {
+	if service.tunnelSessionHistory <= 0 {
+		return
+	}
+
+	service.statusMu.Lock()
+	defer service.statusMu.Unlock()
+
+	count := len(service.status.TunnelSessions)
+	if count == 0 || !service.status.TunnelSessions[count-1].ClosedAt.IsZero() {
+		return
+	}
+
+	session := &service.status.TunnelSessions[count-1]
+	session.ClosedAt = time.Now()
+	session.CloseReason = reason
+	session.BytesIn = stats.BytesIn
+	session.BytesOut = stats.BytesOut
+}
@@ -0,0 +1,63 @@
+package edge
+
+import (
+	"fmt"
+	"log"
+)
+
+const (
+	// tunnelStateSourceAgent trusts the tunnel state tracked by the poll service when the tunnel client reports
+	// the tunnel as open while the poll service tracks it as closed.
+	tunnelStateSourceAgent = "agent"
+	// tunnelStateSourceClient trusts the state reported by the tunnel client and updates the state tracked by
+	// the poll service accordingly.
+	tunnelStateSourceClient = "client"
+)
+
+func parseTunnelStateSource(source string) (string, error) {
+	switch source {
+	case "":
+		return tunnelStateSourceAgent, nil
+	case tunnelStateSourceAgent, tunnelStateSourceClient:
+		return source, nil
+	}
+
+	return "", fmt.Errorf("unsupported tunnel state source %q, expected %s or %s", source, tunnelStateSourceAgent, tunnelStateSourceClient)
+}
+
+// setTunnelOpen updates the tunnel state tracked by the poll service, it must be called
+// whenever the tunnel is opened or closed by the poll service.
+func (service *PollService) setTunnelOpen(open bool) {
+	service.statusMu.Lock()
+	service.status.TunnelOpen = open
+	service.statusMu.Unlock()
+}
+
+// isTunnelOpen returns the state of the tunnel. The state tracked by the poll service is cross-checked against
+// the state reported by the tunnel client and any discrepancy is logged and reconciled based on the configured
+// tunnel state source.
+func (service *PollService) isTunnelOpen() bool {
+	if service.tunnelClient == nil {
+		return false
+	}
+
+	reported := service.tunnelClient.IsTunnelOpen()
+
+	service.statusMu.Lock()
+	defer service.statusMu.Unlock()
+
+	tracked := service.status.TunnelOpen
+	if tracked == reported {
+		return tracked
+	}
+
+	log.Printf("[WARN] [edge] [tracked_open: %t] [reported_open: %t] [trusted_source: %s] [message: tunnel state reported by the tunnel client differs from the tracked state]", tracked, reported, service.tunnelStateSource)
+
+	// A tunnel reported as closed by the tunnel client cannot be used, whatever the trusted source
+	if service.tunnelStateSource == tunnelStateSourceClient || !reported {
+		service.status.TunnelOpen = reported
+		return reported
+	}
+
+	return tracked
+}
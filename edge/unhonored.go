@@ -0,0 +1,60 @@
+package edge
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/portainer/agent"
+)
+
+const (
+	unhonoredRequestTunnel      = "tunnel"
+	unhonoredRequestStatus      = "status"
+	unhonoredRequestFeatureFlag = "feature-flag"
+	unhonoredRequestFingerprint = "tunnel-server-fingerprint"
+)
+
+// UnhonoredRequest is a request of the Portainer instance that the agent could not honor, usually because of a
+// mismatch between the capabilities expected by the Portainer instance and the configuration of the agent.
+type UnhonoredRequest struct {
+	Request string `json:"request"`
+	Reason  string `json:"reason"`
+}
+
+// resetUnhonoredRequests clears the requests that could not be honored before a poll response is reconciled.
+func (service *PollService) resetUnhonoredRequests() {
+	service.unhonoredRequests = nil
+
+	service.statusMu.Lock()
+	service.status.UnhonoredRequests = nil
+	service.statusMu.Unlock()
+}
+
+// recordUnhonoredRequest records a request of the Portainer instance that could not be honored, it is reported
+// with the next poll request and exposed in the status.
+func (service *PollService) recordUnhonoredRequest(request, reason string) {
+	unhonored := UnhonoredRequest{Request: request, Reason: reason}
+	service.unhonoredRequests = append(service.unhonoredRequests, unhonored)
+
+	service.statusMu.Lock()
+	service.status.UnhonoredRequests = append(service.status.UnhonoredRequests, unhonored)
+	service.statusMu.Unlock()
+}
+
+// setUnhonoredRequestsHeader adds the requests that could not be honored while reconciling the previous poll
+// response to the poll request.
+func (service *PollService) setUnhonoredRequestsHeader(req *http.Request) {
+	if len(service.unhonoredRequests) == 0 {
+		return
+	}
+
+	data, err := json.Marshal(service.unhonoredRequests)
+	if err != nil {
+		log.Printf("[WARN] [edge] [message: unable to encode the unhonored requests, they will not be reported] [error: %s]", err)
+		return
+	}
+
+	req.Header.Set(agent.HTTPEdgeUnhonoredRequestsHeaderName, base64.StdEncoding.EncodeToString(data))
+}
@@ -0,0 +1,23 @@
+package edge
+
+import "time"
+
+// warmupLogLevel is the level at which the poll failures are logged during the warmup window, unless
+// a lower level is configured for the class of the error
+const warmupLogLevel = "WARN"
+
+// inWarmup returns true during the warmup window that follows the start of the polling, until the first
+// successful poll. Failures during the warmup window are often transient (DNS resolution, TLS session setup)
+// and are reported with a reduced severity.
+func (service *PollService) inWarmup(now time.Time) bool {
+	return service.pollWarmup > 0 && !service.warmupDone && now.Sub(service.pollStartedAt) < service.pollWarmup
+}
+
+// warmupLevel returns the level at which a poll failure must be logged during the warmup window.
+func warmupLevel(level string) string {
+	if level == "ERROR" {
+		return warmupLogLevel
+	}
+
+	return level
+}
@@ -1,10 +1,12 @@
 package kubernetes
 
 import (
+	"context"
 	"errors"
 	"io"
 
 	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/client-go/rest"
@@ -88,3 +90,18 @@ func (kcl *KubeClient) StartExecProcess(token, namespace, podName, containerName
 
 	return nil
 }
+
+// ContainerCount returns the number of containers defined in the pods of all the namespaces of the cluster.
+func (kcl *KubeClient) ContainerCount(ctx context.Context) (int, error) {
+	pods, err := kcl.cli.CoreV1().Pods("").List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, pod := range pods.Items {
+		count += len(pod.Spec.Containers)
+	}
+
+	return count, nil
+}
@@ -0,0 +1,95 @@
+package kubernetes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/portainer/agent"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	serviceAccountNamespaceFile = "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
+	eventSourceComponent        = "portainer-agent"
+	eventRecordTimeout          = 10 * time.Second
+)
+
+// PodEventRecorder can be used to record Kubernetes events on the pod of the agent
+type PodEventRecorder struct {
+	cli       *kubernetes.Clientset
+	namespace string
+	podName   string
+}
+
+// NewPodEventRecorder returns a pointer to a new PodEventRecorder instance. The pod of the agent is identified
+// through the KUBERNETES_POD_NAME and KUBERNETES_POD_NAMESPACE environment variables, which should be specified
+// with the downward API, and defaults to the hostname and the namespace of the service account.
+func NewPodEventRecorder(kcl *KubeClient) (*PodEventRecorder, error) {
+	if kcl == nil || kcl.cli == nil {
+		return nil, errors.New("the Kubernetes client is not available")
+	}
+
+	podName := os.Getenv(agent.KubernetesPodName)
+	if podName == "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			return nil, fmt.Errorf("unable to determine the name of the agent pod: %w", err)
+		}
+		podName = hostname
+	}
+
+	namespace := os.Getenv(agent.KubernetesPodNamespace)
+	if namespace == "" {
+		content, err := ioutil.ReadFile(serviceAccountNamespaceFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to determine the namespace of the agent pod: %w", err)
+		}
+		namespace = strings.TrimSpace(string(content))
+	}
+
+	return &PodEventRecorder{
+		cli:       kcl.cli,
+		namespace: namespace,
+		podName:   podName,
+	}, nil
+}
+
+// RecordEvent creates a Kubernetes event of the specified type (Normal or Warning) on the pod of the agent.
+func (recorder *PodEventRecorder) RecordEvent(reason, eventType, message string, timestamp time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), eventRecordTimeout)
+	defer cancel()
+
+	eventTime := metav1.NewTime(timestamp)
+
+	event := &v1.Event{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("%s.%x", recorder.podName, timestamp.UnixNano()),
+			Namespace: recorder.namespace,
+		},
+		InvolvedObject: v1.ObjectReference{
+			APIVersion: "v1",
+			Kind:       "Pod",
+			Name:       recorder.podName,
+			Namespace:  recorder.namespace,
+		},
+		Reason:         reason,
+		Message:        message,
+		Type:           eventType,
+		FirstTimestamp: eventTime,
+		LastTimestamp:  eventTime,
+		Count:          1,
+		Source: v1.EventSource{
+			Component: eventSourceComponent,
+		},
+	}
+
+	_, err := recorder.cli.CoreV1().Events(recorder.namespace).Create(ctx, event, metav1.CreateOptions{})
+	return err
+}
@@ -0,0 +1,71 @@
+package kubernetes
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/portainer/agent"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Snapshot returns a summary of the containers defined in the pods, the images available on the nodes and the
+// persistent volume claims of all the namespaces of the cluster.
+func (kcl *KubeClient) Snapshot(ctx context.Context) (*agent.PlatformSnapshot, error) {
+	snapshot := &agent.PlatformSnapshot{}
+
+	pods, err := kcl.cli.CoreV1().Pods("").List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, pod := range pods.Items {
+		for _, container := range pod.Spec.Containers {
+			snapshot.Containers = append(snapshot.Containers, agent.SnapshotContainer{
+				ID:    fmt.Sprintf("%s/%s/%s", pod.Namespace, pod.Name, container.Name),
+				Name:  container.Name,
+				Image: container.Image,
+				State: string(pod.Status.Phase),
+			})
+		}
+	}
+
+	nodes, err := kcl.cli.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	seen := map[string]bool{}
+	for _, node := range nodes.Items {
+		for _, image := range node.Status.Images {
+			if len(image.Names) == 0 || seen[image.Names[0]] {
+				continue
+			}
+			seen[image.Names[0]] = true
+
+			snapshot.Images = append(snapshot.Images, agent.SnapshotImage{
+				ID:   image.Names[0],
+				Tags: image.Names,
+				Size: image.SizeBytes,
+			})
+		}
+	}
+
+	claims, err := kcl.cli.CoreV1().PersistentVolumeClaims("").List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, claim := range claims.Items {
+		driver := ""
+		if claim.Spec.StorageClassName != nil {
+			driver = *claim.Spec.StorageClassName
+		}
+
+		snapshot.Volumes = append(snapshot.Volumes, agent.SnapshotVolume{
+			Name:   fmt.Sprintf("%s/%s", claim.Namespace, claim.Name),
+			Driver: driver,
+		})
+	}
+
+	return snapshot, nil
+}
@@ -1,18 +1,50 @@
 package logutils
 
 import (
+	"io"
 	"log"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/hashicorp/logutils"
 )
 
+var (
+	configuredLogLevel string
+	output             io.Writer = os.Stderr
+)
+
 func SetupLogger(logLevel string) {
+	configuredLogLevel = strings.ToUpper(logLevel)
+	setLogLevel(configuredLogLevel)
+}
+
+// SetVerbose enables the debug logs when verbose is true, it restores the configured log level otherwise.
+func SetVerbose(verbose bool) {
+	if verbose {
+		setLogLevel("DEBUG")
+		return
+	}
+
+	setLogLevel(configuredLogLevel)
+}
+
+// SetThrottleWindow coalesces the identical consecutive log lines printed within the window, 0 disables it.
+func SetThrottleWindow(window time.Duration) {
+	output = os.Stderr
+	if window > 0 {
+		output = newThrottledWriter(os.Stderr, window)
+	}
+
+	setLogLevel(configuredLogLevel)
+}
+
+func setLogLevel(logLevel string) {
 	filter := &logutils.LevelFilter{
 		Levels:   []logutils.LogLevel{"DEBUG", "INFO", "WARN", "ERROR"},
-		MinLevel: logutils.LogLevel(strings.ToUpper(logLevel)),
-		Writer:   os.Stderr,
+		MinLevel: logutils.LogLevel(logLevel),
+		Writer:   output,
 	}
 	log.SetOutput(filter)
 }
@@ -0,0 +1,110 @@
+package logutils
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// logTimestampLayout is the layout of the timestamp prepended by the standard logger to each line, it is ignored
+// when comparing the lines.
+const logTimestampLayout = "2006/01/02 15:04:05 "
+
+// throttledWriter coalesces identical consecutive log lines, they are replaced by a single line reporting how many
+// times the previous line was repeated. A repeated line is printed again once the throttle window elapsed so that
+// a persistent failure remains visible. The repetitions are reported when the throttle window expires, even if no
+// other line is written.
+type throttledWriter struct {
+	writer      io.Writer
+	window      time.Duration
+	mu          sync.Mutex
+	last        []byte
+	lastLevel   []byte
+	windowStart time.Time
+	repeated    int
+	timer       *time.Timer
+}
+
+func newThrottledWriter(writer io.Writer, window time.Duration) *throttledWriter {
+	return &throttledWriter{writer: writer, window: window}
+}
+
+func (w *throttledWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	now := time.Now()
+	message := stripTimestamp(p)
+
+	if w.last != nil && bytes.Equal(message, w.last) && now.Sub(w.windowStart) < w.window {
+		w.repeated++
+		if w.timer == nil {
+			w.timer = time.AfterFunc(w.window-now.Sub(w.windowStart), w.expire)
+		}
+		return len(p), nil
+	}
+
+	err := w.flushRepeated(now)
+	if err != nil {
+		return 0, err
+	}
+
+	w.last = append(w.last[:0], message...)
+	w.lastLevel = levelOf(message)
+	w.windowStart = now
+
+	return w.writer.Write(p)
+}
+
+// expire reports the repetitions once the throttle window elapsed, the next occurrence of the line is then printed.
+func (w *throttledWriter) expire() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(w.windowStart) < w.window {
+		return
+	}
+
+	w.flushRepeated(now)
+	w.last = nil
+}
+
+// flushRepeated reports the number of times the previous line was repeated, if any.
+func (w *throttledWriter) flushRepeated(now time.Time) error {
+	if w.timer != nil {
+		w.timer.Stop()
+		w.timer = nil
+	}
+
+	if w.repeated == 0 {
+		return nil
+	}
+
+	_, err := fmt.Fprintf(w.writer, "%s%s [logutils] [message: previous message repeated %d times in the last %s]\n", now.Format(logTimestampLayout), w.lastLevel, w.repeated, now.Sub(w.windowStart).Round(time.Second))
+	w.repeated = 0
+
+	return err
+}
+
+func stripTimestamp(p []byte) []byte {
+	if len(p) < len(logTimestampLayout) {
+		return p
+	}
+
+	return p[len(logTimestampLayout):]
+}
+
+// levelOf returns the level prefix of a log line, e.g. [ERROR], or [INFO] when the line has no level.
+func levelOf(message []byte) []byte {
+	if len(message) > 0 && message[0] == '[' {
+		end := bytes.IndexByte(message, ']')
+		if end > 0 {
+			return append([]byte(nil), message[:end+1]...)
+		}
+	}
+
+	return []byte("[INFO]")
+}
@@ -0,0 +1,42 @@
+package logutils
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestThrottledWriterFlushesRepeatedLinesWhenWindowExpires(t *testing.T) {
+	var buffer bytes.Buffer
+	w := newThrottledWriter(&buffer, 50*time.Millisecond)
+
+	line := []byte("2021/01/01 00:00:00 [ERROR] [edge] [message: unable to poll]\n")
+	for i := 0; i < 3; i++ {
+		w.Write(line)
+	}
+
+	time.Sleep(200 * time.Millisecond)
+
+	w.mu.Lock()
+	output := buffer.String()
+	w.mu.Unlock()
+
+	if strings.Count(output, "unable to poll") != 1 {
+		t.Errorf("expected the line to be written once, got %q", output)
+	}
+
+	if !strings.Contains(output, "[ERROR] [logutils] [message: previous message repeated 2 times") {
+		t.Errorf("expected the repetitions to be reported once the window expired, got %q", output)
+	}
+
+	w.Write(line)
+
+	w.mu.Lock()
+	output = buffer.String()
+	w.mu.Unlock()
+
+	if strings.Count(output, "unable to poll") != 2 {
+		t.Errorf("expected the line to be written again after the window expired, got %q", output)
+	}
+}
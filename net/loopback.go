@@ -0,0 +1,21 @@
+package net
+
+import (
+	"net"
+)
+
+// IsLoopbackAddr returns true when the address, in the HOST:PORT format, is bound to a loopback interface.
+func IsLoopbackAddr(addr string) (bool, error) {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false, err
+	}
+
+	if host == "localhost" {
+		return true, nil
+	}
+
+	ip := net.ParseIP(host)
+
+	return ip != nil && ip.IsLoopback(), nil
+}
@@ -1,7 +1,9 @@
 package os
 
 import (
+	stdos "os"
 	"strconv"
+	"strings"
 
 	"github.com/portainer/agent"
 	kingpin "gopkg.in/alecthomas/kingpin.v2"
@@ -25,6 +27,95 @@ const (
 	EnvKeyEdgeInactivityTimeout = "EDGE_INACTIVITY_TIMEOUT"
 	EnvKeyEdgeInsecurePoll      = "EDGE_INSECURE_POLL"
 	EnvKeyEdgeTunnel            = "EDGE_TUNNEL"
+	EnvKeyEdgeClientRefresh     = "EDGE_CLIENT_REFRESH"
+	EnvKeyEdgePollFrequency     = "EDGE_POLL_FREQUENCY"
+	EnvKeyEdgeHeartbeatInterval = "EDGE_HEARTBEAT_INTERVAL"
+	EnvKeyEdgeStatsdAddr        = "EDGE_STATSD_ADDR"
+	EnvKeyEdgeFastRetryCodes    = "EDGE_FAST_RETRY_CODES"
+	EnvKeyEdgeTunnelReadiness   = "EDGE_TUNNEL_READINESS_CHECK"
+	EnvKeyEdgeSlowPollThreshold = "EDGE_SLOW_POLL_THRESHOLD"
+	EnvKeyEdgeLogsMinDiskFree   = "EDGE_LOGS_MIN_DISK_FREE"
+	EnvKeyEdgeLogsMinMemoryFree = "EDGE_LOGS_MIN_MEMORY_FREE"
+	EnvKeyEdgeDiagnosticsAddr   = "EDGE_DIAGNOSTICS_ADDR"
+	EnvKeyEdgeProfiling         = "EDGE_PROFILING"
+	EnvKeyEdgeTunnelPathPrefix  = "EDGE_TUNNEL_PATH_PREFIX"
+	EnvKeyEdgeTunnelHeaders     = "EDGE_TUNNEL_HEADERS"
+	EnvKeyEdgeConfigProfile     = "EDGE_CONFIG_PROFILE"
+	EnvKeyEdgePollEncoding      = "EDGE_POLL_ENCODING"
+	EnvKeyEdgeClientInitRetries = "EDGE_CLIENT_INIT_RETRIES"
+	EnvKeyEdgePollReplicas      = "EDGE_POLL_REPLICAS"
+	EnvKeyEdgeReplicaSelection  = "EDGE_REPLICA_SELECTION"
+	EnvKeyEdgeTunnelStateSource = "EDGE_TUNNEL_STATE_SOURCE"
+	EnvKeyEdgeTunnelIdleProbe   = "EDGE_TUNNEL_IDLE_PROBE"
+	EnvKeyEdgeTunnelPortRange   = "EDGE_TUNNEL_PORT_RANGE"
+	EnvKeyEdgeStatusReport      = "EDGE_STATUS_REPORT"
+	EnvKeyEdgeReportResync      = "EDGE_REPORT_RESYNC_INTERVAL"
+	EnvKeyEdgeErrorLogLevels    = "EDGE_POLL_ERROR_LOG_LEVELS"
+	EnvKeyEdgeUnexpectedCreds   = "EDGE_UNEXPECTED_CREDENTIALS"
+	EnvKeyEdgeTunnelMaxStagger  = "EDGE_TUNNEL_MAX_STAGGER"
+	EnvKeyEdgeIDFile            = "EDGE_ID_FILE"
+	EnvKeyEdgeMaxConnLifetime   = "EDGE_MAX_CONN_LIFETIME"
+	EnvKeyEdgeScheduleCheck     = "EDGE_SCHEDULE_VALIDATION"
+	EnvKeyEdgeContainerCount    = "EDGE_CONTAINER_COUNT"
+	EnvKeyEdgeRedirectPolicy    = "EDGE_POLL_REDIRECT_POLICY"
+	EnvKeyEdgeMaxRedirects      = "EDGE_POLL_MAX_REDIRECTS"
+	EnvKeyEdgeFingerprintMode   = "EDGE_TUNNEL_FINGERPRINT_MODE"
+	EnvKeyEdgeFingerprintList   = "EDGE_TUNNEL_TRUSTED_FINGERPRINTS"
+	EnvKeyEdgeReconcileDeadline = "EDGE_RECONCILE_DEADLINE"
+	EnvKeyEdgeLivenessFailures  = "EDGE_LOCAL_LIVENESS_FAILURES"
+	EnvKeyEdgePollHistoryFile   = "EDGE_POLL_HISTORY_FILE"
+	EnvKeyEdgePollHistorySize   = "EDGE_POLL_HISTORY_MAX_SIZE"
+	EnvKeyEdgePollHistoryFiles  = "EDGE_POLL_HISTORY_MAX_FILES"
+	EnvKeyEdgeInsecureFallback  = "EDGE_INSECURE_FALLBACK"
+	EnvKeyEdgeTriggerBackoff    = "EDGE_TRIGGER_BACKOFF_MODE"
+	EnvKeyEdgeDiagnosticsSocket = "EDGE_DIAGNOSTICS_SOCKET"
+	EnvKeyEdgeDiagSocketMode    = "EDGE_DIAGNOSTICS_SOCKET_MODE"
+	EnvKeyEdgeSteadyStateAfter  = "EDGE_STEADY_STATE_AFTER"
+	EnvKeyEdgeSteadyStateEvery  = "EDGE_STEADY_STATE_INTERVAL"
+	EnvKeyEdgeDecodeRetry       = "EDGE_POLL_DECODE_RETRY"
+	EnvKeyEdgeStackStrategy     = "EDGE_STACK_STRATEGY"
+	EnvKeyEdgeReportActions     = "EDGE_REPORT_ACTIONS"
+	EnvKeyEdgePollOverlap       = "EDGE_POLL_OVERLAP"
+	EnvKeyEdgeNetworkCheck      = "EDGE_NETWORK_CHECK"
+	EnvKeyEdgeTunnelServerCheck = "EDGE_TUNNEL_SERVER_CHECK_INTERVAL"
+	EnvKeyEdgeDeregistration    = "EDGE_DEREGISTRATION_MODE"
+	EnvKeyEdgeDeregisterMatch   = "EDGE_DEREGISTRATION_MATCH"
+	EnvKeyEdgeSignReports       = "EDGE_SIGN_REPORTS"
+	EnvKeyEdgeOpenTunnelFactor  = "EDGE_TUNNEL_CHECKIN_FACTOR"
+	EnvKeyEdgeTunnelSessions    = "EDGE_TUNNEL_SESSION_HISTORY"
+	EnvKeyEdgePollWarmup        = "EDGE_POLL_WARMUP"
+	EnvKeyEdgeLogsCondition     = "EDGE_LOGS_COLLECTION_CONDITION"
+	EnvKeyEdgeLogsSlowAfter     = "EDGE_LOGS_SLOW_THRESHOLD"
+	EnvKeyEdgePollTransport     = "EDGE_POLL_TRANSPORT"
+	EnvKeyEdgeResponseMaxAge    = "EDGE_RESPONSE_MAX_AGE"
+	EnvKeyEdgeClockSkew         = "EDGE_RESPONSE_CLOCK_SKEW"
+	EnvKeyEdgeDiagnosticsGRPC   = "EDGE_DIAGNOSTICS_GRPC_ADDR"
+	EnvKeyEdgeTunnelOutage      = "EDGE_TUNNEL_POLL_OUTAGE_TIMEOUT"
+	EnvKeyEdgeResetRestarts     = "EDGE_RESET_RESTART_COUNT"
+	EnvKeyEdgeSystemTimeCheck   = "EDGE_SYSTEM_TIME_CHECK"
+	EnvKeyLogThrottleWindow     = "LOG_THROTTLE_WINDOW"
+	EnvKeyEdgeTLSSessionCache   = "EDGE_TLS_SESSION_CACHE_SIZE"
+	EnvKeyEdgeTLSRenegotiation  = "EDGE_TLS_RENEGOTIATION"
+	EnvKeyOperationMode         = "AGENT_OPERATION_MODE"
+	EnvKeyEdgeStackConcurrency  = "EDGE_STACK_CONCURRENCY"
+	EnvKeyEdgeKubernetesEvents  = "EDGE_KUBERNETES_EVENTS"
+	EnvKeyEdgeStackBatchSize    = "EDGE_STACK_BATCH_SIZE"
+	EnvKeyEdgePollJitter        = "EDGE_POLL_JITTER"
+	EnvKeyEdgePollBackoffMax    = "EDGE_POLL_BACKOFF_MAX"
+	EnvKeyEdgeAsyncInterval     = "EDGE_ASYNC_INTERVAL"
+	EnvKeyEdgePollCompression   = "EDGE_POLL_COMPRESSION"
+	EnvKeyEdgePollCAFiles       = "EDGE_POLL_CA_FILES"
+	EnvKeyEdgeClientCert        = "EDGE_CLIENT_CERT"
+	EnvKeyEdgeClientKey         = "EDGE_CLIENT_KEY"
+	EnvKeyEdgeClientCertPass    = "EDGE_CLIENT_CERT_PASSWORD"
+	EnvKeyEdgeClientCertReload  = "EDGE_CLIENT_CERT_RELOAD"
+	EnvKeyEdgeProxyURL          = "EDGE_PROXY_URL"
+	EnvKeyEdgeSOCKS5Addr        = "EDGE_SOCKS5_ADDR"
+	EnvKeyEdgeSOCKS5Username    = "EDGE_SOCKS5_USERNAME"
+	EnvKeyEdgeSOCKS5Password    = "EDGE_SOCKS5_PASSWORD"
+	EnvKeyEdgeFailbackInterval  = "EDGE_FAILBACK_INTERVAL"
+	EnvKeyEdgeReportQueueSize   = "EDGE_REPORT_QUEUE_SIZE"
+	EnvKeyEdgeResponseKeyFile   = "EDGE_RESPONSE_KEY_FILE"
 	EnvKeyLogLevel              = "LOG_LEVEL"
 )
 
@@ -55,8 +146,134 @@ var (
 	fEdgeInactivityTimeout = kingpin.Flag("edge-inactivity", EnvKeyEdgeInactivityTimeout+" timeout used by the agent to close the reverse tunnel after inactivity (default to 5m)").Envar(EnvKeyEdgeInactivityTimeout).Default(agent.DefaultEdgeSleepInterval).String()
 	fEdgeInsecurePoll      = kingpin.Flag("edge-insecurepoll", EnvKeyEdgeInsecurePoll+" enable this option if you need the agent to poll a HTTPS Portainer instance with self-signed certificates. Disabled by default, set to 1 to enable it").Envar(EnvKeyEdgeInsecurePoll).Bool()
 	fEdgeTunnel            = kingpin.Flag("edge-tunnel", EnvKeyEdgeTunnel+" disable this option if you wish to prevent the agent from opening tunnels over websockets").Envar(EnvKeyEdgeTunnel).Default("true").Bool()
+	fEdgeClientRefresh     = kingpin.Flag("edge-client-refresh", EnvKeyEdgeClientRefresh+" interval after which the poll HTTP client is re-created to force a fresh DNS resolution (disabled by default)").Envar(EnvKeyEdgeClientRefresh).Default("0").Duration()
+	fEdgePollFrequency     = kingpin.Flag("edge-poll-frequency", EnvKeyEdgePollFrequency+" interval used to poll the Portainer instance for the full Edge status until the instance specifies its own check-in interval (default to 5s)").Envar(EnvKeyEdgePollFrequency).Default(agent.DefaultEdgePollInterval).String()
+	fEdgeHeartbeatInterval = kingpin.Flag("edge-heartbeat-interval", EnvKeyEdgeHeartbeatInterval+" interval used to send lightweight heartbeats to the Portainer instance in between status polls (disabled by default)").Envar(EnvKeyEdgeHeartbeatInterval).Default(agent.DefaultEdgeHeartbeatInterval).String()
+	fEdgeStatsdAddr        = kingpin.Flag("edge-statsd-addr", EnvKeyEdgeStatsdAddr+" address (in the HOST:PORT format) of a StatsD server where the poll and tunnel metrics will be sent over UDP (disabled by default)").Envar(EnvKeyEdgeStatsdAddr).String()
+	fEdgeFastRetryCodes    = kingpin.Flag("edge-fast-retry-codes", EnvKeyEdgeFastRetryCodes+" comma separated list of 5xx status codes returned by the Portainer instance that trigger an immediate retry of the poll instead of waiting for the next poll interval (e.g. 502,503), 5xx stands for every server error status code (default to 5xx)").Envar(EnvKeyEdgeFastRetryCodes).Default(agent.DefaultEdgeFastRetryCodes).String()
+	fEdgeTunnelReadiness   = kingpin.Flag("edge-tunnel-readiness-check", EnvKeyEdgeTunnelReadiness+" enable this option to ensure that the agent API is reachable before opening a reverse tunnel. Disabled by default, set to 1 to enable it").Envar(EnvKeyEdgeTunnelReadiness).Bool()
+	fEdgeSlowPollThreshold = kingpin.Flag("edge-slow-poll-threshold", EnvKeyEdgeSlowPollThreshold+" duration above which a poll request is logged as slow (default to half of the poll interval)").Envar(EnvKeyEdgeSlowPollThreshold).Default("0").Duration()
+	fEdgeLogsMinDiskFree   = kingpin.Flag("edge-logs-min-disk-free", EnvKeyEdgeLogsMinDiskFree+" minimum amount of disk space in MB that must be available on the host to collect schedule logs (disabled by default)").Envar(EnvKeyEdgeLogsMinDiskFree).Default("0").Uint64()
+	fEdgeLogsMinMemoryFree = kingpin.Flag("edge-logs-min-memory-free", EnvKeyEdgeLogsMinMemoryFree+" minimum amount of memory in MB that must be available on the host to collect schedule logs (disabled by default)").Envar(EnvKeyEdgeLogsMinMemoryFree).Default("0").Uint64()
+	fEdgeDiagnosticsAddr   = kingpin.Flag("edge-diagnostics-addr", EnvKeyEdgeDiagnosticsAddr+" loopback address (in the HOST:PORT format) on which the Edge diagnostics API will be exposed (disabled by default)").Envar(EnvKeyEdgeDiagnosticsAddr).String()
+	fEdgeProfiling         = kingpin.Flag("edge-profiling", EnvKeyEdgeProfiling+" enable this option to allow the capture of goroutine and heap profiles through the Edge diagnostics API. Disabled by default, set to 1 to enable it").Envar(EnvKeyEdgeProfiling).Bool()
+	fEdgeTunnelPathPrefix  = kingpin.Flag("edge-tunnel-path-prefix", EnvKeyEdgeTunnelPathPrefix+" path prefix appended to the tunnel server address, used when the tunnel server is exposed behind a reverse proxy").Envar(EnvKeyEdgeTunnelPathPrefix).String()
+	fEdgeTunnelHeaders     = kingpin.Flag("edge-tunnel-headers", EnvKeyEdgeTunnelHeaders+" comma separated list of headers (in the Name=Value format) sent when connecting to the tunnel server").Envar(EnvKeyEdgeTunnelHeaders).String()
+	fEdgeConfigProfile     = kingpin.Flag("edge-config-profile", EnvKeyEdgeConfigProfile+" name of a configuration profile (dev, staging or prod) used to populate the Edge poll, tunnel and TLS settings and feature flags that are not explicitly configured").Envar(EnvKeyEdgeConfigProfile).String()
+	fEdgePollEncoding      = kingpin.Flag("edge-poll-encoding", EnvKeyEdgePollEncoding+" encoding requested for the poll responses (json or msgpack), the agent falls back to JSON when the Portainer instance does not support the requested encoding").Envar(EnvKeyEdgePollEncoding).Default(agent.DefaultEdgePollEncoding).String()
+	fEdgeClientInitRetries = kingpin.Flag("edge-client-init-retries", EnvKeyEdgeClientInitRetries+" number of attempts made to create the poll HTTP client on startup before giving up (default to 5)").Envar(EnvKeyEdgeClientInitRetries).Default(strconv.Itoa(agent.DefaultEdgeClientInitRetries)).Int()
+	fEdgePollReplicas      = kingpin.Flag("edge-poll-replicas", EnvKeyEdgePollReplicas+" comma separated list of additional Portainer instance URLs serving the same Edge endpoint, used to spread the poll requests").Envar(EnvKeyEdgePollReplicas).String()
+	fEdgeReplicaSelection  = kingpin.Flag("edge-replica-selection", EnvKeyEdgeReplicaSelection+" strategy used to select the replica for each poll request (round-robin or random, default to round-robin)").Envar(EnvKeyEdgeReplicaSelection).Default(agent.DefaultEdgeReplicaSelection).String()
+	fEdgeTunnelStateSource = kingpin.Flag("edge-tunnel-state-source", EnvKeyEdgeTunnelStateSource+" source trusted when the tunnel state tracked by the agent and the state reported by the tunnel client differ (agent or client, default to agent)").Envar(EnvKeyEdgeTunnelStateSource).Default(agent.DefaultEdgeTunnelStateSource).String()
+	fEdgeTunnelIdleProbe   = kingpin.Flag("edge-tunnel-idle-probe", EnvKeyEdgeTunnelIdleProbe+" enable this option to track the traffic going through the tunnel and keep the tunnel open when traffic was registered since the last known activity. Disabled by default, set to 1 to enable it").Envar(EnvKeyEdgeTunnelIdleProbe).Bool()
+	fEdgeTunnelPortRange   = kingpin.Flag("edge-tunnel-port-range", EnvKeyEdgeTunnelPortRange+" expected range (in the MIN-MAX format) of the tunnel ports assigned by the Portainer instance, a warning is logged when an assigned port is outside of this range (disabled by default)").Envar(EnvKeyEdgeTunnelPortRange).String()
+	fEdgeStatusReport      = kingpin.Flag("edge-status-report", EnvKeyEdgeStatusReport+" enable this option to send a status report with each poll request, only the changes are sent when supported by the Portainer instance. Disabled by default, set to 1 to enable it").Envar(EnvKeyEdgeStatusReport).Bool()
+	fEdgeReportResync      = kingpin.Flag("edge-report-resync-interval", EnvKeyEdgeReportResync+" interval at which a full status report is sent to resync with the Portainer instance (default to 10m)").Envar(EnvKeyEdgeReportResync).Default(agent.DefaultEdgeFullReportInterval.String()).Duration()
+	fEdgeErrorLogLevels    = kingpin.Flag("edge-poll-error-log-levels", EnvKeyEdgeErrorLogLevels+" comma separated list of class=LEVEL mappings used to log poll errors (classes: timeout, tls, 4xx, 5xx, decode, other), unmapped classes are logged as ERROR").Envar(EnvKeyEdgeErrorLogLevels).String()
+	fEdgeUnexpectedCreds   = kingpin.Flag("edge-unexpected-credentials", EnvKeyEdgeUnexpectedCreds+" behavior when tunnel credentials are received while the tunnel is not required: ignore, warn or cache them to open the tunnel faster (default to ignore)").Envar(EnvKeyEdgeUnexpectedCreds).Default("ignore").String()
+	fEdgeTunnelMaxStagger  = kingpin.Flag("edge-tunnel-max-stagger", EnvKeyEdgeTunnelMaxStagger+" maximum random delay applied before opening a tunnel requested by the Portainer instance, used to avoid opening many tunnels at once in large fleets (disabled by default)").Envar(EnvKeyEdgeTunnelMaxStagger).Default("0").Duration()
+	fEdgeIDFile            = kingpin.Flag("edge-id-file", EnvKeyEdgeIDFile+" path to a file containing the Edge identifier, used instead of EDGE_ID when the identifier is written by a provisioning process").Envar(EnvKeyEdgeIDFile).String()
+	fEdgeMaxConnLifetime   = kingpin.Flag("edge-max-conn-lifetime", EnvKeyEdgeMaxConnLifetime+" maximum lifetime of the connections used to poll the Portainer instance, connections are recycled once they reach this age (disabled by default)").Envar(EnvKeyEdgeMaxConnLifetime).Default("0").Duration()
+	fEdgeScheduleCheck     = kingpin.Flag("edge-schedule-validation", EnvKeyEdgeScheduleCheck+" validation of the schedules (identifier, script, cron expression and logs collection window) before they are applied: none, reject (reject all the schedules when one is invalid) or skip (only skip the invalid schedules). Default to none").Envar(EnvKeyEdgeScheduleCheck).Default("none").String()
+	fEdgeContainerCount    = kingpin.Flag("edge-container-count", EnvKeyEdgeContainerCount+" enable this option to report the number of containers managed by the container platform with each poll request. Disabled by default, set to 1 to enable it").Envar(EnvKeyEdgeContainerCount).Bool()
+	fEdgeRedirectPolicy    = kingpin.Flag("edge-poll-redirect-policy", EnvKeyEdgeRedirectPolicy+" behavior when the Portainer instance answers a poll request with a redirect: error, follow or limited (default to error)").Envar(EnvKeyEdgeRedirectPolicy).Default(agent.DefaultEdgeRedirectPolicy).String()
+	fEdgeMaxRedirects      = kingpin.Flag("edge-poll-max-redirects", EnvKeyEdgeMaxRedirects+" maximum number of redirects followed when the redirect policy is set to limited (default to 3)").Envar(EnvKeyEdgeMaxRedirects).Default(strconv.Itoa(agent.DefaultEdgeMaxRedirects)).Int()
+	fEdgeFingerprintMode   = kingpin.Flag("edge-tunnel-fingerprint-mode", EnvKeyEdgeFingerprintMode+" behavior when the Portainer instance sends a new tunnel server fingerprint: adopt, trusted (only adopt fingerprints listed in EDGE_TUNNEL_TRUSTED_FINGERPRINTS) or disabled (default to disabled). A fingerprint is never adopted from a response received over an unverified connection, adopt also requires a signed response (EDGE_RESPONSE_KEY_FILE)").Envar(EnvKeyEdgeFingerprintMode).Default(agent.DefaultEdgeFingerprintMode).String()
+	fEdgeFingerprintList   = kingpin.Flag("edge-tunnel-trusted-fingerprints", EnvKeyEdgeFingerprintList+" comma separated list of tunnel server fingerprints that can be adopted when the fingerprint mode is set to trusted").Envar(EnvKeyEdgeFingerprintList).String()
+	fEdgeReconcileDeadline = kingpin.Flag("edge-reconcile-deadline", EnvKeyEdgeReconcileDeadline+" maximum duration of the reconciliation of a poll response (tunnel, schedules, logs and stacks), the remaining work is done during the next poll once exceeded (disabled by default)").Envar(EnvKeyEdgeReconcileDeadline).Default("0").Duration()
+	fEdgeLivenessFailures  = kingpin.Flag("edge-local-liveness-failures", EnvKeyEdgeLivenessFailures+" number of consecutive failed checks of the local address targeted by the tunnel after which an open tunnel is closed, the address is checked during the activity monitoring (disabled by default)").Envar(EnvKeyEdgeLivenessFailures).Default("0").Int()
+	fEdgePollHistoryFile   = kingpin.Flag("edge-poll-history-file", EnvKeyEdgePollHistoryFile+" path of the file where a JSON record of each poll (status, actions, error and timings) is written, separately from the agent logs (disabled by default)").Envar(EnvKeyEdgePollHistoryFile).String()
+	fEdgePollHistorySize   = kingpin.Flag("edge-poll-history-max-size", EnvKeyEdgePollHistorySize+" size in MB above which the poll history file is rotated (default to 10)").Envar(EnvKeyEdgePollHistorySize).Default(strconv.Itoa(agent.DefaultEdgePollHistorySizeMB)).Int()
+	fEdgePollHistoryFiles  = kingpin.Flag("edge-poll-history-max-files", EnvKeyEdgePollHistoryFiles+" number of rotated poll history files that are kept (default to 3)").Envar(EnvKeyEdgePollHistoryFiles).Default(strconv.Itoa(agent.DefaultEdgePollHistoryFiles)).Int()
+	fEdgeInsecureFallback  = kingpin.Flag("edge-insecure-fallback", EnvKeyEdgeInsecureFallback+" enable this option to poll the Portainer instance with a verified TLS connection first and only fall back to an insecure connection when the verification of the certificate fails. Ignored when EDGE_INSECURE_POLL is enabled. Disabled by default, set to 1 to enable it").Envar(EnvKeyEdgeInsecureFallback).Bool()
+	fEdgeTriggerBackoff    = kingpin.Flag("edge-trigger-backoff-mode", EnvKeyEdgeTriggerBackoff+" behavior of the immediate polls triggered while the agent is backing off after the Portainer instance asked to retry later: respect (the trigger is skipped) or ignore (the poll is executed) (default to respect)").Envar(EnvKeyEdgeTriggerBackoff).Default("respect").String()
+	fEdgeDiagnosticsSocket = kingpin.Flag("edge-diagnostics-socket", EnvKeyEdgeDiagnosticsSocket+" path of a Unix socket on which the Edge diagnostics API will be exposed instead of a TCP address, so that only local processes can reach it (disabled by default)").Envar(EnvKeyEdgeDiagnosticsSocket).String()
+	fEdgeDiagSocketMode    = kingpin.Flag("edge-diagnostics-socket-mode", EnvKeyEdgeDiagSocketMode+" file permissions (octal) of the Edge diagnostics Unix socket (default to 0600)").Envar(EnvKeyEdgeDiagSocketMode).Default("0600").String()
+	fEdgeSteadyStateAfter  = kingpin.Flag("edge-steady-state-after", EnvKeyEdgeSteadyStateAfter+" duration after which an event confirming that the agent is healthy is emitted when the state requested by the Portainer instance did not change (disabled by default)").Envar(EnvKeyEdgeSteadyStateAfter).Default("0").Duration()
+	fEdgeSteadyStateEvery  = kingpin.Flag("edge-steady-state-interval", EnvKeyEdgeSteadyStateEvery+" interval between the steady state events while the state requested by the Portainer instance does not change (default to 1h)").Envar(EnvKeyEdgeSteadyStateEvery).Default(agent.DefaultEdgeSteadyStateInterval.String()).Duration()
+	fEdgeDecodeRetry       = kingpin.Flag("edge-poll-decode-retry", EnvKeyEdgeDecodeRetry+" enable this option to poll the Portainer instance again right away, once, when the poll response cannot be read or decoded (e.g. truncated by a connection reset). Disabled by default, set to 1 to enable it").Envar(EnvKeyEdgeDecodeRetry).Bool()
+	fEdgeStackStrategy     = kingpin.Flag("edge-stack-strategy", EnvKeyEdgeStackStrategy+" strategy used to converge the Edge stacks to the version requested by the Portainer instance: in-place or recreate (default to in-place)").Envar(EnvKeyEdgeStackStrategy).Default("in-place").String()
+	fEdgeReportActions     = kingpin.Flag("edge-report-actions", EnvKeyEdgeReportActions+" enable this option to report the outcome of the actions performed in response to a poll (tunnel, schedules, logs and stacks) with the next poll request. Disabled by default, set to 1 to enable it").Envar(EnvKeyEdgeReportActions).Bool()
+	fEdgePollOverlap       = kingpin.Flag("edge-poll-overlap", EnvKeyEdgePollOverlap+" behavior when a poll is requested while a poll is in progress: skip or queue (a single poll is executed once the poll in progress completes) (default to skip)").Envar(EnvKeyEdgePollOverlap).Default("skip").String()
+	fEdgeNetworkCheck      = kingpin.Flag("edge-network-check", EnvKeyEdgeNetworkCheck+" enable this option to skip the polls while no network interface is up or no default route is present, the polls resume when the network is available again. Disabled by default, set to 1 to enable it").Envar(EnvKeyEdgeNetworkCheck).Bool()
+	fEdgeTunnelServerCheck = kingpin.Flag("edge-tunnel-server-check-interval", EnvKeyEdgeTunnelServerCheck+" interval between the reachability checks of the tunnel server, performed independently of the polls (disabled by default)").Envar(EnvKeyEdgeTunnelServerCheck).Default("0").Duration()
+	fEdgeDeregistration    = kingpin.Flag("edge-deregistration-mode", EnvKeyEdgeDeregistration+" behavior when the Portainer instance rejects the Edge ID with a 401 or 403 status code: ignore (keep polling) or stop (stop polling until the agent is provisioned again) (default to ignore)").Envar(EnvKeyEdgeDeregistration).Default("ignore").String()
+	fEdgeDeregisterMatch   = kingpin.Flag("edge-deregistration-match", EnvKeyEdgeDeregisterMatch+" text that the body of a 401 or 403 response must contain to be considered as a rejection of the Edge ID (any 401 or 403 response by default)").Envar(EnvKeyEdgeDeregisterMatch).String()
+	fEdgeSignReports       = kingpin.Flag("edge-sign-reports", EnvKeyEdgeSignReports+" enable this option to sign the data reported with each poll request with a key generated by the agent and persisted in the data folder, so that the Portainer instance can verify that it was sent by this agent. Disabled by default, set to 1 to enable it").Envar(EnvKeyEdgeSignReports).Bool()
+	fEdgeOpenTunnelFactor  = kingpin.Flag("edge-tunnel-checkin-factor", EnvKeyEdgeOpenTunnelFactor+" factor applied to the checkin interval sent by the Portainer instance while the tunnel is open, e.g. 0.5 to poll twice as often while the tunnel is open (default to 1)").Envar(EnvKeyEdgeOpenTunnelFactor).Default("1").Float64()
+	fEdgeTunnelSessions    = kingpin.Flag("edge-tunnel-session-history", EnvKeyEdgeTunnelSessions+" number of recent tunnel sessions (open and close time, close reason, port and traffic when tracked) kept and exposed in the status (default to 10)").Envar(EnvKeyEdgeTunnelSessions).Default(strconv.Itoa(agent.DefaultEdgeTunnelSessionHistory)).Int()
+	fEdgePollWarmup        = kingpin.Flag("edge-poll-warmup", EnvKeyEdgePollWarmup+" duration of the warmup window that follows the start of the polling, the poll failures are logged as warnings and not counted as failures during the window or until the first successful poll (disabled by default)").Envar(EnvKeyEdgePollWarmup).Default("0").Duration()
+	fEdgeLogsCondition     = kingpin.Flag("edge-logs-collection-condition", EnvKeyEdgeLogsCondition+" condition on the last run of a schedule for its logs to be collected: always, on-failure (the script exited with a non-zero code) or on-slow (the run lasted longer than the slow threshold) (default to always)").Envar(EnvKeyEdgeLogsCondition).Default("always").String()
+	fEdgeLogsSlowAfter     = kingpin.Flag("edge-logs-slow-threshold", EnvKeyEdgeLogsSlowAfter+" duration after which the run of a schedule is considered slow when the logs collection condition is on-slow (default to 5m)").Envar(EnvKeyEdgeLogsSlowAfter).Default(agent.DefaultEdgeLogsSlowThreshold.String()).Duration()
+	fEdgePollTransport     = kingpin.Flag("edge-poll-transport", EnvKeyEdgePollTransport+" transport used to retrieve the status of the Edge endpoint from the Portainer instance: http or websocket (default to http)").Envar(EnvKeyEdgePollTransport).Default("http").String()
+	fEdgeResponseMaxAge    = kingpin.Flag("edge-response-max-age", EnvKeyEdgeResponseMaxAge+" maximum age of a poll response based on its Date header, older responses are rejected to avoid acting on replayed or cached responses (disabled by default, 5m when a response signing key is set)").Envar(EnvKeyEdgeResponseMaxAge).Default("0").Duration()
+	fEdgeClockSkew         = kingpin.Flag("edge-response-clock-skew", EnvKeyEdgeClockSkew+" tolerated clock skew between the agent and the Portainer instance when checking the age of a poll response (default to 30s)").Envar(EnvKeyEdgeClockSkew).Default(agent.DefaultEdgeResponseClockSkew.String()).Duration()
+	fEdgeDiagnosticsGRPC   = kingpin.Flag("edge-diagnostics-grpc-addr", EnvKeyEdgeDiagnosticsGRPC+" loopback address (in the HOST:PORT format) or Unix socket (in the unix:///path format) on which the Edge diagnostics gRPC service will be exposed, the messages are encoded in JSON (disabled by default)").Envar(EnvKeyEdgeDiagnosticsGRPC).String()
+	fEdgeTunnelOutage      = kingpin.Flag("edge-tunnel-poll-outage-timeout", EnvKeyEdgeTunnelOutage+" duration after which an open tunnel is shut down when the Portainer instance cannot be polled, 0 keeps the tunnel open during poll outages (default to 0)").Envar(EnvKeyEdgeTunnelOutage).Default("0").Duration()
+	fEdgeResetRestarts     = kingpin.Flag("edge-reset-restart-count", EnvKeyEdgeResetRestarts+" enable this option to reset the restart count and the last exit reason reported to the Portainer instance. Disabled by default, set to 1 to enable it").Envar(EnvKeyEdgeResetRestarts).Bool()
+	fEdgeSystemTimeCheck   = kingpin.Flag("edge-system-time-check", EnvKeyEdgeSystemTimeCheck+" behavior when the system time is clearly wrong at startup, e.g. on devices without a real time clock: off (no check), warn (start polling and log an error) or wait (wait for the clock to be synchronized before polling) (default to off)").Envar(EnvKeyEdgeSystemTimeCheck).Default("off").String()
+	fLogThrottleWindow     = kingpin.Flag("log-throttle-window", EnvKeyLogThrottleWindow+" window during which identical consecutive log lines are coalesced into a single line reporting the number of repetitions (disabled by default)").Envar(EnvKeyLogThrottleWindow).Default("0").Duration()
+	fEdgeTLSSessionCache   = kingpin.Flag("edge-tls-session-cache-size", EnvKeyEdgeTLSSessionCache+" number of TLS sessions cached to resume the sessions across poll requests instead of performing a full handshake, 0 disables the session resumption (default to 64)").Envar(EnvKeyEdgeTLSSessionCache).Default(strconv.Itoa(agent.DefaultEdgeTLSSessionCacheSize)).Int()
+	fEdgeTLSRenegotiation  = kingpin.Flag("edge-tls-renegotiation", EnvKeyEdgeTLSRenegotiation+" TLS renegotiation support of the poll requests, for compatibility with servers requesting it: never, once or freely (default to never)").Envar(EnvKeyEdgeTLSRenegotiation).Default("never").String()
+	fOperationMode         = kingpin.Flag("operation-mode", EnvKeyOperationMode+" overall posture of the Edge agent: full, tunnel-only, stacks-only, observer (poll and report without applying anything) or maintenance (no poll), the individual options can further disable a behavior allowed by the mode (default to full)").Envar(EnvKeyOperationMode).Default(agent.DefaultOperationMode).String()
+	fEdgeStackConcurrency  = kingpin.Flag("edge-stack-concurrency", EnvKeyEdgeStackConcurrency+" maximum number of Edge stacks reconciled concurrently, the other stacks are queued (default to 1)").Envar(EnvKeyEdgeStackConcurrency).Default("1").Int()
+	fEdgeKubernetesEvents  = kingpin.Flag("edge-kubernetes-events", EnvKeyEdgeKubernetesEvents+" report the significant transitions of the poll service and the tunnel as Kubernetes events on the agent pod, only available on Kubernetes").Envar(EnvKeyEdgeKubernetesEvents).Default("false").Bool()
+	fEdgeStackBatchSize    = kingpin.Flag("edge-stack-batch-size", EnvKeyEdgeStackBatchSize+" number of Edge stacks processed at once when the stacks requested by the Portainer instance change, 0 processes all the stacks at once (default to 100)").Envar(EnvKeyEdgeStackBatchSize).Default("100").Int()
+	fEdgePollJitter        = kingpin.Flag("edge-poll-jitter", EnvKeyEdgePollJitter+" ratio of the poll interval used as a random jitter applied to each poll, e.g. 0.1 for plus or minus 10% (disabled by default)").Envar(EnvKeyEdgePollJitter).Default("0").Float64()
+	fEdgePollBackoffMax    = kingpin.Flag("edge-poll-backoff-max", EnvKeyEdgePollBackoffMax+" maximum poll interval reached when the poll interval is doubled after each consecutive poll failure (disabled by default)").Envar(EnvKeyEdgePollBackoffMax).Default("0").Duration()
+	fEdgeAsyncInterval     = kingpin.Flag("edge-async-interval", EnvKeyEdgeAsyncInterval+" enable the async Edge mode, a platform snapshot is pushed to the Portainer instance at this interval and the schedules and Edge stacks are managed through the commands received in return (disabled by default)").Envar(EnvKeyEdgeAsyncInterval).Default("0").Duration()
+	fEdgePollCompression   = kingpin.Flag("edge-poll-compression", EnvKeyEdgePollCompression+" compress the request bodies sent to the Portainer instance with gzip once it advertises support for it (default to false)").Envar(EnvKeyEdgePollCompression).Default(strconv.FormatBool(agent.DefaultEdgePollCompression)).Bool()
+	fEdgePollCAFiles       = kingpin.Flag("edge-poll-ca-files", EnvKeyEdgePollCAFiles+" comma separated list of PEM files containing the certificate authorities trusted in addition to the system trust store when connecting to the Portainer instance").Envar(EnvKeyEdgePollCAFiles).String()
+	fEdgeClientCert        = kingpin.Flag("edge-client-cert", EnvKeyEdgeClientCert+" path to the client certificate presented to the Portainer instance and to the tunnel server, either a PEM certificate or a PKCS#12 bundle (.p12 or .pfx), a PEM copy of a PKCS#12 bundle is written in the data folder for the tunnel").Envar(EnvKeyEdgeClientCert).String()
+	fEdgeClientKey         = kingpin.Flag("edge-client-key", EnvKeyEdgeClientKey+" path to the PEM private key of the client certificate, not used with a PKCS#12 bundle").Envar(EnvKeyEdgeClientKey).String()
+	fEdgeClientCertPass    = kingpin.Flag("edge-client-cert-password", EnvKeyEdgeClientCertPass+" password of the PKCS#12 client certificate bundle").Envar(EnvKeyEdgeClientCertPass).String()
+	fEdgeClientCertReload  = kingpin.Flag("edge-client-cert-reload", EnvKeyEdgeClientCertReload+" reload the client certificate when its files change on disk (default to false)").Envar(EnvKeyEdgeClientCertReload).Default("false").Bool()
+	fEdgeProxyURL          = kingpin.Flag("edge-proxy-url", EnvKeyEdgeProxyURL+" URL of the proxy used to reach the Portainer instance and the tunnel server, credentials for basic authentication can be specified in the URL (default to the HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables)").Envar(EnvKeyEdgeProxyURL).String()
+	fEdgeSOCKS5Addr        = kingpin.Flag("edge-socks5-addr", EnvKeyEdgeSOCKS5Addr+" address of the SOCKS5 proxy used to reach the Portainer instance and the tunnel server, in the host:port format").Envar(EnvKeyEdgeSOCKS5Addr).String()
+	fEdgeSOCKS5Username    = kingpin.Flag("edge-socks5-username", EnvKeyEdgeSOCKS5Username+" username used to authenticate against the SOCKS5 proxy").Envar(EnvKeyEdgeSOCKS5Username).String()
+	fEdgeSOCKS5Password    = kingpin.Flag("edge-socks5-password", EnvKeyEdgeSOCKS5Password+" password used to authenticate against the SOCKS5 proxy").Envar(EnvKeyEdgeSOCKS5Password).String()
+	fEdgeFailbackInterval  = kingpin.Flag("edge-failback-interval", EnvKeyEdgeFailbackInterval+" interval at which a failed Portainer instance listed before the active one in the Edge key is probed to fail back to it").Envar(EnvKeyEdgeFailbackInterval).Default(agent.DefaultEdgeFailbackInterval.String()).Duration()
+	fEdgeReportQueueSize   = kingpin.Flag("edge-report-queue-size", EnvKeyEdgeReportQueueSize+" maximum size in MB of the Edge stack status updates and schedule logs queued on disk while the Portainer instance is unreachable, 0 disables the queue (default to 0)").Envar(EnvKeyEdgeReportQueueSize).Default(strconv.Itoa(agent.DefaultEdgeReportQueueSizeMB)).Int()
+	fEdgeResponseKeyFile   = kingpin.Flag("edge-response-key-file", EnvKeyEdgeResponseKeyFile+" path to a PEM encoded Ed25519 or ECDSA public key used to verify the signature of the poll responses, overrides the key embedded in the Edge key").Envar(EnvKeyEdgeResponseKeyFile).String()
 )
 
+// profileOptions maps the environment variables of the options populated by an Edge configuration profile to
+// the name of their command line flag.
+var profileOptions = map[string]string{
+	EnvKeyEdgePollFrequency:     "edge-poll-frequency",
+	EnvKeyEdgeInactivityTimeout: "edge-inactivity",
+	EnvKeyEdgeTunnelReadiness:   "edge-tunnel-readiness-check",
+	EnvKeyEdgeFastRetryCodes:    "edge-fast-retry-codes",
+	EnvKeyEdgeSlowPollThreshold: "edge-slow-poll-threshold",
+	EnvKeyEdgeTLSSessionCache:   "edge-tls-session-cache-size",
+	EnvKeyEdgeTLSRenegotiation:  "edge-tls-renegotiation",
+	EnvKeyEdgeTunnelIdleProbe:   "edge-tunnel-idle-probe",
+	EnvKeyEdgeNetworkCheck:      "edge-network-check",
+	EnvKeyEdgeDecodeRetry:       "edge-poll-decode-retry",
+	EnvKeyEdgeReportActions:     "edge-report-actions",
+}
+
+// explicitOptions returns the environment variables of the profile options explicitly set by the user, either
+// through the environment or on the command line, even when the value matches the default one.
+func explicitOptions() map[string]bool {
+	explicit := map[string]bool{}
+
+	for envKey, flagName := range profileOptions {
+		if _, ok := stdos.LookupEnv(envKey); ok {
+			explicit[envKey] = true
+			continue
+		}
+
+		for _, arg := range stdos.Args[1:] {
+			if arg == "--"+flagName || arg == "--no-"+flagName || strings.HasPrefix(arg, "--"+flagName+"=") {
+				explicit[envKey] = true
+			}
+		}
+	}
+
+	return explicit
+}
+
 func (parser *EnvOptionParser) Options() (*agent.Options, error) {
 	kingpin.Parse()
 	return &agent.Options{
@@ -77,6 +294,96 @@ func (parser *EnvOptionParser) Options() (*agent.Options, error) {
 		EdgeInactivityTimeout: *fEdgeInactivityTimeout,
 		EdgeInsecurePoll:      *fEdgeInsecurePoll,
 		EdgeTunnel:            *fEdgeTunnel,
+		EdgeClientRefresh:     *fEdgeClientRefresh,
+		EdgePollFrequency:     *fEdgePollFrequency,
+		EdgeHeartbeatInterval: *fEdgeHeartbeatInterval,
+		EdgeStatsdAddr:        *fEdgeStatsdAddr,
+		EdgeFastRetryCodes:    *fEdgeFastRetryCodes,
+		EdgeTunnelReadiness:   *fEdgeTunnelReadiness,
+		EdgeSlowPollThreshold: *fEdgeSlowPollThreshold,
+		EdgeLogsMinDiskFree:   *fEdgeLogsMinDiskFree,
+		EdgeLogsMinMemoryFree: *fEdgeLogsMinMemoryFree,
+		EdgeDiagnosticsAddr:   *fEdgeDiagnosticsAddr,
+		EdgeProfiling:         *fEdgeProfiling,
+		EdgeTunnelPathPrefix:  *fEdgeTunnelPathPrefix,
+		EdgeTunnelHeaders:     *fEdgeTunnelHeaders,
+		EdgeConfigProfile:     *fEdgeConfigProfile,
+		EdgeExplicitOptions:   explicitOptions(),
+		EdgePollEncoding:      *fEdgePollEncoding,
+		EdgeClientInitRetries: *fEdgeClientInitRetries,
+		EdgePollReplicas:      *fEdgePollReplicas,
+		EdgeReplicaSelection:  *fEdgeReplicaSelection,
+		EdgeTunnelStateSource: *fEdgeTunnelStateSource,
+		EdgeTunnelIdleProbe:   *fEdgeTunnelIdleProbe,
+		EdgeTunnelPortRange:   *fEdgeTunnelPortRange,
+		EdgeStatusReport:      *fEdgeStatusReport,
+		EdgeReportResync:      *fEdgeReportResync,
+		EdgeErrorLogLevels:    *fEdgeErrorLogLevels,
+		EdgeUnexpectedCreds:   *fEdgeUnexpectedCreds,
+		EdgeTunnelMaxStagger:  *fEdgeTunnelMaxStagger,
+		EdgeIDFile:            *fEdgeIDFile,
+		EdgeMaxConnLifetime:   *fEdgeMaxConnLifetime,
+		EdgeScheduleCheck:     *fEdgeScheduleCheck,
+		EdgeContainerCount:    *fEdgeContainerCount,
+		EdgeRedirectPolicy:    *fEdgeRedirectPolicy,
+		EdgeMaxRedirects:      *fEdgeMaxRedirects,
+		EdgeFingerprintMode:   *fEdgeFingerprintMode,
+		EdgeFingerprintList:   *fEdgeFingerprintList,
+		EdgeReconcileDeadline: *fEdgeReconcileDeadline,
+		EdgeLivenessFailures:  *fEdgeLivenessFailures,
+		EdgePollHistoryFile:   *fEdgePollHistoryFile,
+		EdgePollHistorySize:   *fEdgePollHistorySize,
+		EdgePollHistoryFiles:  *fEdgePollHistoryFiles,
+		EdgeInsecureFallback:  *fEdgeInsecureFallback,
+		EdgeTriggerBackoff:    *fEdgeTriggerBackoff,
+		EdgeDiagnosticsSocket: *fEdgeDiagnosticsSocket,
+		EdgeDiagSocketMode:    *fEdgeDiagSocketMode,
+		EdgeSteadyStateAfter:  *fEdgeSteadyStateAfter,
+		EdgeSteadyStateEvery:  *fEdgeSteadyStateEvery,
+		EdgeDecodeRetry:       *fEdgeDecodeRetry,
+		EdgeStackStrategy:     *fEdgeStackStrategy,
+		EdgeReportActions:     *fEdgeReportActions,
+		EdgePollOverlap:       *fEdgePollOverlap,
+		EdgeNetworkCheck:      *fEdgeNetworkCheck,
+		EdgeTunnelServerCheck: *fEdgeTunnelServerCheck,
+		EdgeDeregistration:    *fEdgeDeregistration,
+		EdgeDeregisterMatch:   *fEdgeDeregisterMatch,
+		EdgeSignReports:       *fEdgeSignReports,
+		EdgeOpenTunnelFactor:  *fEdgeOpenTunnelFactor,
+		EdgeTunnelSessions:    *fEdgeTunnelSessions,
+		EdgePollWarmup:        *fEdgePollWarmup,
+		EdgeLogsCondition:     *fEdgeLogsCondition,
+		EdgeLogsSlowAfter:     *fEdgeLogsSlowAfter,
+		EdgePollTransport:     *fEdgePollTransport,
+		EdgeResponseMaxAge:    *fEdgeResponseMaxAge,
+		EdgeClockSkew:         *fEdgeClockSkew,
+		EdgeDiagnosticsGRPC:   *fEdgeDiagnosticsGRPC,
+		EdgeTunnelOutage:      *fEdgeTunnelOutage,
+		EdgeResetRestarts:     *fEdgeResetRestarts,
+		EdgeSystemTimeCheck:   *fEdgeSystemTimeCheck,
+		LogThrottleWindow:     *fLogThrottleWindow,
+		EdgeTLSSessionCache:   *fEdgeTLSSessionCache,
+		EdgeTLSRenegotiation:  *fEdgeTLSRenegotiation,
+		OperationMode:         *fOperationMode,
+		EdgeStackConcurrency:  *fEdgeStackConcurrency,
+		EdgeKubernetesEvents:  *fEdgeKubernetesEvents,
+		EdgeStackBatchSize:    *fEdgeStackBatchSize,
+		EdgePollJitter:        *fEdgePollJitter,
+		EdgePollBackoffMax:    *fEdgePollBackoffMax,
+		EdgeAsyncInterval:     *fEdgeAsyncInterval,
+		EdgePollCompression:   *fEdgePollCompression,
+		EdgePollCAFiles:       *fEdgePollCAFiles,
+		EdgeClientCert:        *fEdgeClientCert,
+		EdgeClientKey:         *fEdgeClientKey,
+		EdgeClientCertPass:    *fEdgeClientCertPass,
+		EdgeClientCertReload:  *fEdgeClientCertReload,
+		EdgeProxyURL:          *fEdgeProxyURL,
+		EdgeSOCKS5Addr:        *fEdgeSOCKS5Addr,
+		EdgeSOCKS5Username:    *fEdgeSOCKS5Username,
+		EdgeSOCKS5Password:    *fEdgeSOCKS5Password,
+		EdgeFailbackInterval:  *fEdgeFailbackInterval,
+		EdgeReportQueueSize:   *fEdgeReportQueueSize,
+		EdgeResponseKeyFile:   *fEdgeResponseKeyFile,
 		LogLevel:              *fLogLevel,
 	}, nil
 }
@@ -37,7 +37,8 @@ function build_archive() {
 function build_binary() {
   platform=$1
   arch=$2
-  GOOS="${platform}" GOARCH="${arch}" CGO_ENABLED=0 go build -a --installsuffix cgo --ldflags '-s' "${MAIN}"
+  BUILD_LDFLAGS="-X github.com/portainer/agent.GitCommit=$(git rev-parse --short HEAD 2>/dev/null) -X github.com/portainer/agent.BuildDate=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+  GOOS="${platform}" GOARCH="${arch}" CGO_ENABLED=0 go build -a --installsuffix cgo --ldflags "-s ${BUILD_LDFLAGS}" "${MAIN}"
   mv main "dist/agent"
 }
 